@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// remoteTarget is a connected URL target, ready to spider.
+type remoteTarget struct {
+	FS    spider.FileSystem
+	Host  string // reported as MatchResult.Host
+	Share string // reported as MatchResult.Share
+	Root  string // path to start walking from
+	Close func()
+}
+
+// remoteBackend connects to a URL target (sftp://user@host/path, ...).
+type remoteBackend func(u *url.URL) (*remoteTarget, error)
+
+// remoteBackends maps URL schemes to backends. Anything without a scheme is
+// treated as a local path or an SMB host.
+var remoteBackends = map[string]remoteBackend{
+	"sftp": dialSFTP,
+	"ftp":  dialFTP,
+	"ftps": dialFTP,
+	"s3":   dialS3,
+	"dav":  dialWebDAV,
+	"davs": dialWebDAV,
+	"nfs":  dialNFS,
+}
+
+// isURLTarget reports whether tgt uses scheme:// syntax.
+func isURLTarget(tgt string) bool {
+	return strings.Contains(tgt, "://")
+}
+
+// scanURLTarget spiders a single URL target with the matching backend.
+func scanURLTarget(tgt string, cfg spider.Config, m *matcher.Matcher, dedup *utils.Deduplicator, reporter spider.Reporter) {
+	u, err := url.Parse(tgt)
+	if err != nil {
+		utils.LogError("Invalid target URL %s: %v", tgt, err)
+		return
+	}
+
+	backend, ok := remoteBackends[strings.ToLower(u.Scheme)]
+	if !ok {
+		utils.LogError("Unsupported target scheme %q in %s", u.Scheme, tgt)
+		return
+	}
+
+	utils.LogInfo("Scanning remote target: %s", tgt)
+	rt, err := backend(u)
+	if err != nil {
+		utils.LogError("Failed to connect to %s: %v", tgt, err)
+		return
+	}
+	defer rt.Close()
+
+	cfg.Host = rt.Host
+	cfg.Share = rt.Share
+	cfg.Scheme = strings.ToLower(u.Scheme)
+	s := spider.NewSpider(cfg, m, rt.FS, dedup, reporter)
+	s.Walk(rt.Root)
+}
+
+// urlCredentials returns the user and password for u, falling back to the
+// global --username/--password flags.
+func urlCredentials(u *url.URL) (string, string) {
+	user, pass := username, password
+	if u.User != nil {
+		user = u.User.Username()
+		if p, ok := u.User.Password(); ok {
+			pass = p
+		}
+	}
+	return user, pass
+}
+
+func dialSFTP(u *url.URL) (*remoteTarget, error) {
+	user, pass := urlCredentials(u)
+	if user == "" {
+		return nil, fmt.Errorf("no username (use sftp://user@host/path or -u)")
+	}
+
+	fsys, err := spider.DialSFTP(u.Host, spider.SFTPAuth{
+		User:     user,
+		Password: pass,
+		KeyFile:  sshKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	root := u.Path
+	if root == "" {
+		root = "."
+	}
+	return &remoteTarget{FS: fsys, Host: u.Host, Share: root, Root: root, Close: fsys.Close}, nil
+}
+
+// dialFTP handles ftp:// (plain) and ftps:// (explicit TLS via AUTH TLS).
+// Without credentials the login is anonymous.
+func dialFTP(u *url.URL) (*remoteTarget, error) {
+	user, pass := urlCredentials(u)
+
+	fsys, err := spider.DialFTP(spider.FTPConfig{
+		Addr:        u.Host,
+		User:        user,
+		Password:    pass,
+		ExplicitTLS: strings.EqualFold(u.Scheme, "ftps"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	root := u.Path
+	if root == "" {
+		root = "/"
+	}
+	return &remoteTarget{FS: fsys, Host: u.Host, Share: root, Root: root, Close: fsys.Close}, nil
+}
+
+// dialS3 handles s3://bucket/prefix against --s3-endpoint. Keys come from the
+// --s3-* flags (or the URL userinfo); without them access is anonymous.
+func dialS3(u *url.URL) (*remoteTarget, error) {
+	cfg := spider.S3Config{
+		Endpoint:     s3Endpoint,
+		Region:       s3Region,
+		AccessKey:    s3AccessKey,
+		SecretKey:    s3SecretKey,
+		SessionToken: s3SessionToken,
+	}
+	if u.User != nil {
+		cfg.AccessKey = u.User.Username()
+		cfg.SecretKey, _ = u.User.Password()
+	}
+
+	bucket := u.Host
+	fsys, err := spider.DialS3(cfg, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = spider.DefaultS3Endpoint
+	}
+	host := endpoint
+	if eu, err := url.Parse(endpoint); err == nil && eu.Host != "" {
+		host = eu.Host
+	}
+
+	return &remoteTarget{FS: fsys, Host: host, Share: bucket, Root: u.Path, Close: fsys.Close}, nil
+}
+
+// dialWebDAV handles dav:// (http) and davs:// (https). With -d set, the user
+// is sent as DOMAIN\user so NTLM picks up the domain.
+func dialWebDAV(u *url.URL) (*remoteTarget, error) {
+	user, pass := urlCredentials(u)
+	if user != "" && domain != "" && !strings.ContainsAny(user, `\@`) {
+		user = domain + `\` + user
+	}
+
+	scheme := "http"
+	if strings.EqualFold(u.Scheme, "davs") {
+		scheme = "https"
+	}
+
+	root := u.Path
+	if root == "" {
+		root = "/"
+	}
+
+	fsys, err := spider.DialWebDAV(spider.WebDAVConfig{
+		BaseURL:  scheme + "://" + u.Host,
+		User:     user,
+		Password: pass,
+	}, root)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteTarget{FS: fsys, Host: u.Host, Share: root, Root: root, Close: fsys.Close}, nil
+}
+
+// dialNFS handles nfs://host/export. Without an export, every export the
+// server lists (showmount -e) is mounted. An explicit port (nfs://host:2049)
+// skips the portmapper and talks MOUNT and NFS on that port.
+func dialNFS(u *url.URL) (*remoteTarget, error) {
+	cfg := spider.NFSConfig{
+		Host: u.Hostname(),
+		UID:  nfsUID,
+		GID:  nfsGID,
+	}
+	if p := u.Port(); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", p)
+		}
+		cfg.Port = port
+	}
+
+	export := strings.TrimSuffix(u.Path, "/")
+	fsys, err := spider.DialNFS(cfg, export)
+	if err != nil {
+		return nil, err
+	}
+
+	share := export
+	if share == "" {
+		share = "/"
+	}
+	return &remoteTarget{FS: fsys, Host: u.Host, Share: share, Root: share, Close: fsys.Close}, nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/0xSterny/spuderman/pkg/report"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+var reportHTML bool
+
+var reportCmd = &cobra.Command{
+	Use:   "report --html results.json",
+	Short: "Render the JSON output of a scan as a report",
+	Long: `Render the JSON output of a scan (-o results.json) as a report.
+
+--html writes a single HTML file that works offline: findings grouped by
+host, share and severity, with filters, highlighted snippets, links to the
+downloaded copies and the scan statistics. It is written next to the input
+(results.html) unless -o is given.
+
+Downloads are linked from their recorded loot_path. For scans made before
+it was recorded, pass the loot options the scan used (-l, -S, -x).`,
+	Args: cobra.ExactArgs(1),
+	// Errors are printed once, by Execute
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !reportHTML {
+			return errors.New("no report format given (--html)")
+		}
+
+		in := args[0]
+		f, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		scan, err := report.Load(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", in, err)
+		}
+
+		out := outputFile
+		if out == "" {
+			out = strings.TrimSuffix(in, filepath.Ext(in)) + ".html"
+		}
+		opts := report.Options{
+			Title:     "Spuderman report: " + filepath.Base(in),
+			Generated: time.Now(),
+			Loot:      spider.Config{LootDir: lootDir, Structured: structuredLoot, Delimiter: lootDelimiter},
+			Dir:       filepath.Dir(out),
+		}
+		// Rendered in memory first: no half-written report on failure
+		var buf bytes.Buffer
+		if err := report.HTML(&buf, scan, opts); err != nil {
+			return fmt.Errorf("failed to render the report: %w", err)
+		}
+		if err := os.WriteFile(out, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		utils.LogSuccess("Report of %d findings written to %s", len(scan.Findings), out)
+		return nil
+	},
+}
+
+func init() {
+	reportCmd.Flags().BoolVar(&reportHTML, "html", false, "Write a self-contained HTML report")
+	rootCmd.AddCommand(reportCmd)
+}
@@ -1,12 +1,15 @@
 package cmd
 
 import (
-	"bufio"
+	"cmp"
 	"fmt"
-	"net"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/hirochachacha/go-smb2"
 	"github.com/spf13/cobra"
@@ -27,6 +30,21 @@ var (
 	hash      string
 	ccache    string
 	krbConfig string
+	sshKey    string
+
+	// S3
+	s3Endpoint     string
+	s3Region       string
+	s3AccessKey    string
+	s3SecretKey    string
+	s3SessionToken string
+
+	// NFS (AUTH_SYS)
+	nfsUID uint32
+	nfsGID uint32
+
+	// Targets
+	excludeTargets []string
 
 	// Filters
 	filenames  []string
@@ -39,7 +57,15 @@ var (
 	threads         int
 	concurrentHosts int
 	maxDepth        int
+	maxFileSize     int64
+	fileTimeout     int
 	analyze         bool
+	gitHistory      bool
+	maxGitSize      int64
+	scanImages      bool
+	classify        bool
+	parseConfigs    bool
+	decode          bool
 	lootDir         string
 	lootDelimiter   string
 	noDownload      bool
@@ -49,7 +75,8 @@ var (
 	silent          bool
 
 	// Reporting
-	outputFile string
+	outputFile   string
+	outputFormat string
 
 	// Phase 2
 	presets   []string
@@ -69,9 +96,21 @@ based on filenames, extensions, and content.
 
 Targets can be:
 - Single IP or Hostname (e.g. 192.168.1.1)
-- CIDR Range (e.g. 192.168.1.0/24)
+- IPv6 address, optionally bracketed with a port (e.g. [fe80::1]:445)
+- CIDR Range (e.g. 192.168.1.0/24, network/broadcast skipped)
+- IP Range (e.g. 10.0.0.1-50)
 - File containing targets (one per line)
-- Local Directory`,
+- Local Directory
+- SFTP URL (e.g. sftp://user@host/path)
+- FTP URL (e.g. ftp://host/path, anonymous unless -u is given; ftps:// for explicit TLS)
+- S3 bucket (e.g. s3://bucket/prefix, see --s3-endpoint)
+- WebDAV / SharePoint URL (e.g. davs://portal.corp.local/Shared Documents)
+- NFS export (e.g. nfs://host/export, or nfs://host for every export; see --nfs-uid)
+
+Use --exclude-targets to skip CIDRs, IPs or hostnames (inline or from a file).
+Targets resolving to the same address are only scanned once.`,
+	// Targets, not subcommands (see report)
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
 			cmd.Help()
@@ -112,13 +151,13 @@ Targets can be:
 		// Setup Reporter
 		var reporter spider.Reporter
 		if outputFile != "" {
-			jr, err := spider.NewJSONReporter(outputFile)
+			fr, err := newReporter(outputFormat, outputFile)
 			if err != nil {
 				utils.LogError("Failed to create reporter: %v", err)
 				return
 			}
-			defer jr.Close()
-			reporter = jr
+			defer fr.Close()
+			reporter = fr
 		} else {
 			reporter = &spider.ConsoleReporter{} // Dummy
 		}
@@ -145,14 +184,29 @@ Targets can be:
 			matchEngine.ExcludeRegex = nil // Clear defaults
 		}
 
+		// Files that panicked or timed out, and the per-share counters, for
+		// the final report
+		failures := &spider.Failures{}
+		stats := &spider.Stats{}
+
 		// 2. Setup Spider Config
 		sConfig := spider.Config{
-			MaxDepth:   maxDepth,
-			Threads:    threads,
-			LootDir:    lootDir,
-			NoDownload: noDownload,
-			Structured: structuredLoot,
-			Delimiter:  lootDelimiter,
+			MaxDepth:     maxDepth,
+			Threads:      threads,
+			LootDir:      lootDir,
+			NoDownload:   noDownload,
+			Structured:   structuredLoot,
+			Delimiter:    lootDelimiter,
+			MaxFileSize:  maxFileSize * 1024 * 1024,
+			FileTimeout:  time.Duration(fileTimeout) * time.Second,
+			Failures:     failures,
+			Stats:        stats,
+			GitHistory:   gitHistory,
+			MaxGitSize:   maxGitSize * 1024 * 1024,
+			ScanImages:   scanImages,
+			Classify:     classify,
+			ParseConfigs: parseConfigs,
+			Decode:       decode,
 		}
 
 		// Resume State
@@ -168,53 +222,48 @@ Targets can be:
 		}
 
 		// 3. Process Targets
-		var finalTargets []string
+		exclusions, err := newTargetExclusions(excludeTargets)
+		if err != nil {
+			utils.LogError("Failed to parse target exclusions: %v", err)
+			return
+		}
 
+		var expanded []string
 		for _, arg := range args {
-			// A. Try CIDR
-			_, ipnet, err := net.ParseCIDR(arg)
-			if err == nil {
-				// Expand CIDR
-				utils.LogInfo("Expanding CIDR: %s", arg)
-				for ip := ipnet.IP.Mask(ipnet.Mask); ipnet.Contains(ip); inc(ip) {
-					ipStr := ip.String()
-					if stateMgr != nil && stateMgr.IsCompleted(ipStr) {
-						continue
-					}
-					finalTargets = append(finalTargets, ipStr)
-				}
-				continue
-			}
-
-			// B. Check File
-			fi, err := os.Stat(arg)
-			if err == nil && !fi.IsDir() {
+			// A. File of targets (each line is expanded like an argument)
+			specs := []string{arg}
+			if isTargetFile(arg) {
 				utils.LogInfo("Reading targets from file: %s", arg)
-				file, err := os.Open(arg)
+				lines, err := readTargetFile(arg)
 				if err != nil {
 					utils.LogError("Failed to open target file %s: %v", arg, err)
 					continue
 				}
-				scanner := bufio.NewScanner(file)
-				for scanner.Scan() {
-					t := strings.TrimSpace(scanner.Text())
-					if t != "" {
-						if stateMgr != nil && stateMgr.IsCompleted(t) {
-							continue
-						}
-						finalTargets = append(finalTargets, t)
-					}
+				specs = lines
+			}
+
+			// B. CIDR / range / host[:port]
+			for _, spec := range specs {
+				if strings.Contains(spec, "/") || strings.Contains(spec, "-") {
+					utils.LogDebug("Expanding target: %s", spec)
 				}
-				file.Close()
-				continue
+				tgts, err := expandTarget(spec)
+				if err != nil {
+					utils.LogError("Invalid target %s: %v", spec, err)
+					continue
+				}
+				expanded = append(expanded, tgts...)
 			}
+		}
 
-			// C. Default
-			if stateMgr != nil && stateMgr.IsCompleted(arg) {
-				utils.LogInfo("Skipping completed target: %s", arg)
+		// C. Exclusions + DNS deduplication, then resume filter
+		var finalTargets []string
+		for _, tgt := range filterTargets(expanded, exclusions) {
+			if stateMgr != nil && stateMgr.IsCompleted(tgt) {
+				utils.LogDebug("Skipping completed target: %s", tgt)
 				continue
 			}
-			finalTargets = append(finalTargets, arg)
+			finalTargets = append(finalTargets, tgt)
 		}
 
 		if stateMgr != nil {
@@ -247,6 +296,19 @@ Targets can be:
 						stateMgr.MarkCompleted(tgt)
 					}
 				}()
+				// A panic outside file processing (listing, backend) only
+				// ends this target
+				defer func() {
+					if r := recover(); r != nil {
+						utils.LogError("Scan of %s aborted: panic: %v", tgt, r)
+					}
+				}()
+
+				// URL targets (sftp://...) have their own backends
+				if isURLTarget(tgt) {
+					scanURLTarget(tgt, sConfig, matchEngine, dedup, reporter)
+					return
+				}
 
 				// Check if local
 				if _, err := os.Stat(tgt); err == nil {
@@ -255,7 +317,7 @@ Targets can be:
 					fs := &spider.LocalFS{}
 
 					localCfg := sConfig
-					localCfg.Host = "Local"
+					localCfg.Host = spider.LocalHost
 					localCfg.Share = tgt
 					s := spider.NewSpider(localCfg, matchEngine, fs, dedup, reporter)
 					// Local scan uses own threads logic unless we want to bound it?
@@ -274,6 +336,9 @@ Targets can be:
 					}
 					defer session.Close()
 
+					hostInfo := session.Info
+					utils.LogInfo("Connected to %s (%s)", tgt, hostInfo.String())
+
 					// List Shares (or use provided)
 					var shares []string
 					if len(sharenames) > 0 {
@@ -315,6 +380,11 @@ Targets can be:
 						shareWG.Add(1)
 						go func(sh string, mount *smb2.Share) {
 							defer shareWG.Done()
+							defer func() {
+								if r := recover(); r != nil {
+									utils.LogError("Scan of \\\\%s\\%s aborted: panic: %v", tgt, sh, r)
+								}
+							}()
 							// defer mount.Umount()? Configured in session.
 
 							utils.LogInfo("Scanning share: \\\\%s\\%s", tgt, sh)
@@ -323,6 +393,7 @@ Targets can be:
 							shareCfg := sConfig
 							shareCfg.Host = tgt
 							shareCfg.Share = sh
+							shareCfg.HostInfo = &hostInfo
 
 							s := spider.NewSpider(shareCfg, matchEngine, fs, dedup, reporter)
 							s.Semaphore = hostSem // Inject shared semaphore
@@ -334,9 +405,100 @@ Targets can be:
 			}(target)
 		}
 		targetWG.Wait()
+
+		reportSummary(stats, failures, reporter)
 	},
 }
 
+// newReporter creates the reporter writing the results to path in format.
+func newReporter(format, path string) (spider.Reporter, error) {
+	switch strings.ToLower(format) {
+	case "json":
+		return spider.NewJSONReporter(path)
+	case "sarif":
+		return spider.NewSARIFReporter(path)
+	case "csv":
+		return spider.NewCSVReporter(path)
+	case "markdown", "md":
+		return spider.NewMarkdownReporter(path)
+	}
+	return nil, fmt.Errorf("unknown output format %q (json, sarif, csv, markdown)", format)
+}
+
+// reportSummary prints the end-of-scan report and records it in the
+// output file.
+func reportSummary(stats *spider.Stats, failures *spider.Failures, reporter spider.Reporter) {
+	shares := stats.Shares()
+	total := stats.Total()
+	if len(shares) > 0 {
+		utils.LogInfo("Scan summary:")
+		for _, line := range summaryTable(shares, total) {
+			utils.LogInfo("  %s", line)
+		}
+	}
+
+	files := failures.Files()
+	if len(files) > 0 {
+		utils.LogWarning("Failed files: %d (not fully checked, review by hand)", len(files))
+		for _, f := range files {
+			utils.LogWarning("  [%s] //%s/%s/%s: %s", f.Reason, f.Host, f.Share, f.Path, f.Error)
+		}
+	}
+	if sr, ok := reporter.(spider.SummaryReporter); ok {
+		sr.ReportSummary(spider.Summary{Shares: shares, Total: total, FailedFiles: files})
+	}
+}
+
+// summaryTable renders the counters, one row per share and a total row,
+// followed by the breakdowns of the totals.
+func summaryTable(shares []spider.ShareStats, total spider.ShareStats) []string {
+	var buf strings.Builder
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tSHARE\tDIRS\tFILES\tSKIPPED\tSCANNED\tREAD\tFAILED\tDENIED\tMATCHES\tDOWNLOADS")
+	row := func(host, share string, st spider.ShareStats) {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%d\t%d\t%d\t%d\n", host, share, st.Dirs, st.Files,
+			st.SkipCount(), st.Scanned, utils.FormatBytes(st.BytesRead), st.FailureCount(), st.AccessDenied, st.MatchCount(), st.Downloads)
+	}
+	for _, st := range shares {
+		row(st.Host, st.Share, st)
+	}
+	if len(shares) > 1 {
+		row("TOTAL", "", total)
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	for _, c := range []struct {
+		name   string
+		counts map[string]int64
+	}{
+		{"Skipped", total.Skipped},
+		{"Extraction failures", total.ExtractFailures},
+		{"Matches", total.Matches},
+	} {
+		if len(c.counts) > 0 {
+			lines = append(lines, c.name+": "+formatCounts(c.counts))
+		}
+	}
+	return lines
+}
+
+// formatCounts lists counts by decreasing number: "size 12, extension 3".
+func formatCounts(counts map[string]int64) string {
+	keys := slices.Collect(maps.Keys(counts))
+	slices.SortFunc(keys, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -352,8 +514,23 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&hash, "hash", "H", "", "NTLM hash for authentication")
 	rootCmd.PersistentFlags().StringVarP(&ccache, "ccache", "", "", "Kerberos CCache file path")
 	rootCmd.PersistentFlags().StringVarP(&krbConfig, "krb5-conf", "", "", "Kerberos config file path (krb5.conf)")
+	rootCmd.PersistentFlags().StringVar(&sshKey, "ssh-key", "", "SSH private key for sftp:// targets (password is used as passphrase)")
 	rootCmd.PersistentFlags().BoolVarP(&noPass, "no-pass", "", false, "Do not use a password (force empty)")
 
+	// S3 (keys also read from AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY / AWS_SESSION_TOKEN)
+	rootCmd.PersistentFlags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL for s3:// targets (default AWS, e.g. http://minio:9000)")
+	rootCmd.PersistentFlags().StringVar(&s3Region, "s3-region", "", "S3 region (auto-detected if empty)")
+	rootCmd.PersistentFlags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key (default $AWS_ACCESS_KEY_ID, anonymous if neither is set)")
+	rootCmd.PersistentFlags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key (default $AWS_SECRET_ACCESS_KEY)")
+	rootCmd.PersistentFlags().StringVar(&s3SessionToken, "s3-session-token", "", "S3 session token for temporary credentials (default $AWS_SESSION_TOKEN)")
+
+	// NFS
+	rootCmd.PersistentFlags().Uint32Var(&nfsUID, "nfs-uid", 0, "AUTH_SYS uid to claim on nfs:// targets (spoof a file owner to get past root_squash)")
+	rootCmd.PersistentFlags().Uint32Var(&nfsGID, "nfs-gid", 0, "AUTH_SYS gid to claim on nfs:// targets")
+
+	// Targets
+	rootCmd.PersistentFlags().StringSliceVar(&excludeTargets, "exclude-targets", []string{}, "Targets to skip: CIDRs, IPs, ranges or hostnames (inline or files, one per line)")
+
 	// Filters
 	rootCmd.PersistentFlags().StringSliceVarP(&filenames, "filenames", "f", []string{}, "Filter filenames using regex")
 	rootCmd.PersistentFlags().StringSliceVarP(&extensions, "extensions", "e", []string{}, "Only show filenames with these extensions")
@@ -365,6 +542,14 @@ func init() {
 	rootCmd.PersistentFlags().IntVarP(&threads, "threads", "t", 5, "Concurrent threads (PER HOST)")
 	rootCmd.PersistentFlags().IntVarP(&concurrentHosts, "parallel", "P", 5, "Max concurrent hosts")
 	rootCmd.PersistentFlags().IntVarP(&maxDepth, "maxdepth", "m", 10, "Maximum depth to spider")
+	rootCmd.PersistentFlags().Int64Var(&maxFileSize, "max-filesize", 0, "Skip files larger than this many MB (0 = no limit)")
+	rootCmd.PersistentFlags().IntVar(&fileTimeout, "file-timeout", 300, "Give up on a file after this many seconds of reading and extraction (0 = no limit); listed as failed at the end")
+	rootCmd.PersistentFlags().BoolVar(&gitHistory, "git-history", false, "Scan every commit of .git directories and bare repos (blobs deduplicated by hash)")
+	rootCmd.PersistentFlags().Int64Var(&maxGitSize, "max-git-size", 512, "With --git-history, skip remote repos whose .git directory is larger than this many MB (0 = no limit)")
+	rootCmd.PersistentFlags().BoolVar(&scanImages, "scan-images", false, "Look inside disk images: ISO9660, and NTFS/FAT32 in VHD, VHDX and flat VMDK")
+	rootCmd.PersistentFlags().BoolVar(&classify, "classify", false, "Identify registry hives, NTDS.dit and credential containers (KeePass, PFX, SSH/PuTTY keys, .ovpn, .rdp) by content, whatever their name; flagged with a severity")
+	rootCmd.PersistentFlags().BoolVar(&parseConfigs, "parse-configs", false, "Parse configuration files (web.config, unattend.xml, .env, .ini, scripts, wp-config.php...) and report the credentials in them with their key path")
+	rootCmd.PersistentFlags().BoolVar(&decode, "decode", false, "Also match content inside base64 and hex blobs (PowerShell -EncodedCommand, Kubernetes secrets), decompressing gzip/zlib/deflate")
 	rootCmd.PersistentFlags().BoolVarP(&analyze, "analyze", "A", false, "Analyze mode: No download, Verbose output, Log to file")
 	rootCmd.PersistentFlags().StringVarP(&lootDir, "loot-dir", "l", ".spuderman/loot", "Loot directory")
 	rootCmd.PersistentFlags().StringVarP(&lootDelimiter, "delimiter", "x", "+", "Delimiter between Host/Share/Path in flat loot filenames (filesystem-safe single character recommended)")
@@ -373,6 +558,7 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show debugging messages")
 	rootCmd.PersistentFlags().BoolVar(&silent, "silent", false, "Only show matches and downloads (suppress all other console output and the progress bar)")
 	rootCmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "", "Output file for results (JSON)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "format", "json", "Format of the --output file: json (one finding per line), sarif (SARIF 2.1.0 log), csv, markdown (one table per host)")
 
 	// Phase 2: UX
 	rootCmd.PersistentFlags().StringSliceVar(&presets, "preset", []string{}, "Load secret regex presets (e.g. aws, azure, slack, keys)")
@@ -382,12 +568,3 @@ func init() {
 	// Phase 3
 	rootCmd.PersistentFlags().StringVar(&resumeFile, "resume", "", "Resume state file (JSON)")
 }
-
-func inc(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
-	}
-}
@@ -0,0 +1,352 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// expandTarget turns a single target spec into one or more targets.
+// Supported forms:
+//   - Local directory (passed through untouched)
+//   - URL target for a non-SMB backend, e.g. sftp://user@host/path (untouched)
+//   - IPv4/IPv6 CIDR (IPv4 network and broadcast addresses are skipped)
+//   - IPv4 range, either 10.0.0.1-50 or 10.0.0.1-10.0.0.50
+//   - IPv6 literal, optionally bracketed: [fe80::1] or [fe80::1]:445
+//   - host:port
+//   - Plain IP or hostname
+func expandTarget(arg string) ([]string, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return nil, nil
+	}
+
+	// Local paths and URL targets are never expanded
+	if fi, err := os.Stat(arg); err == nil && fi.IsDir() {
+		return []string{arg}, nil
+	}
+	if isURLTarget(arg) {
+		return []string{arg}, nil
+	}
+
+	// A. CIDR
+	if _, ipnet, err := net.ParseCIDR(arg); err == nil {
+		return expandCIDR(ipnet)
+	}
+
+	// B. IPv4 range
+	if strings.Contains(arg, "-") && !strings.Contains(arg, ":") {
+		if ips, ok, err := expandRange(arg); ok {
+			return ips, err
+		}
+	}
+
+	// C. host:port / [v6]:port / [v6]
+	host, port, err := splitTarget(arg)
+	if err != nil {
+		return nil, err
+	}
+	return []string{joinTarget(host, port)}, nil
+}
+
+// expandCIDR lists every usable host address in ipnet.
+func expandCIDR(ipnet *net.IPNet) ([]string, error) {
+	ones, bits := ipnet.Mask.Size()
+	// Anything wider than a /112 is almost certainly a typo and would never finish
+	if bits == 128 && bits-ones > 16 {
+		return nil, fmt.Errorf("IPv6 range %s is too large (max /112)", ipnet)
+	}
+
+	var ips []string
+	for ip := ipnet.IP.Mask(ipnet.Mask); ipnet.Contains(ip); inc(ip) {
+		ips = append(ips, ip.String())
+	}
+
+	// /31 and /32 have no network or broadcast address (RFC 3021)
+	if bits == 32 && ones < 31 && len(ips) > 2 {
+		ips = ips[1 : len(ips)-1]
+	}
+	return ips, nil
+}
+
+// expandRange handles "10.0.0.1-50" and "10.0.0.1-10.0.0.50". The bool reports
+// whether arg looked like a range at all, so hostnames with dashes fall through.
+func expandRange(arg string) ([]string, bool, error) {
+	startStr, endStr, _ := strings.Cut(arg, "-")
+	start := net.ParseIP(startStr).To4()
+	if start == nil {
+		return nil, false, nil
+	}
+
+	var end net.IP
+	if n, err := strconv.Atoi(endStr); err == nil {
+		if n < 0 || n > 255 {
+			return nil, true, fmt.Errorf("invalid range end in %s", arg)
+		}
+		end = net.IPv4(start[0], start[1], start[2], byte(n)).To4()
+	} else if end = net.ParseIP(endStr).To4(); end == nil {
+		return nil, false, nil
+	}
+
+	if ipToUint(end) < ipToUint(start) {
+		return nil, true, fmt.Errorf("range %s ends before it starts", arg)
+	}
+
+	var ips []string
+	for ip := start; ipToUint(ip) <= ipToUint(end); {
+		ips = append(ips, ip.String())
+		next := make(net.IP, len(ip))
+		copy(next, ip)
+		inc(next)
+		if next.Equal(net.IPv4zero) {
+			break
+		}
+		ip = next
+	}
+	return ips, true, nil
+}
+
+func ipToUint(ip net.IP) uint32 {
+	ip = ip.To4()
+	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+}
+
+// splitTarget separates an optional port from the host. Bare IPv6 literals
+// (more than one colon, no brackets) are returned without a port.
+func splitTarget(arg string) (host, port string, err error) {
+	if strings.HasPrefix(arg, "[") {
+		if strings.HasSuffix(arg, "]") {
+			return strings.Trim(arg, "[]"), "", nil
+		}
+		return net.SplitHostPort(arg)
+	}
+	if strings.Count(arg, ":") == 1 {
+		return net.SplitHostPort(arg)
+	}
+	return arg, "", nil
+}
+
+// joinTarget is the inverse of splitTarget; the result is what gets handed to
+// the SMB client and stored in the resume file.
+func joinTarget(host, port string) string {
+	if port == "" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// readTargetFile returns the non-empty lines of a targets file.
+func readTargetFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		t := strings.TrimSpace(scanner.Text())
+		if t != "" && !strings.HasPrefix(t, "#") {
+			lines = append(lines, t)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// isTargetFile reports whether arg names a regular file of targets.
+func isTargetFile(arg string) bool {
+	fi, err := os.Stat(arg)
+	return err == nil && !fi.IsDir()
+}
+
+// targetExclusions holds everything passed to --exclude-targets.
+type targetExclusions struct {
+	nets  []*net.IPNet
+	ips   map[string]bool
+	names map[string]bool
+}
+
+func newTargetExclusions(entries []string) (*targetExclusions, error) {
+	ex := &targetExclusions{
+		ips:   make(map[string]bool),
+		names: make(map[string]bool),
+	}
+
+	var specs []string
+	for _, e := range entries {
+		if isTargetFile(e) {
+			lines, err := readTargetFile(e)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read exclusion file %s: %v", e, err)
+			}
+			specs = append(specs, lines...)
+			continue
+		}
+		specs = append(specs, e)
+	}
+
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		if _, ipnet, err := net.ParseCIDR(spec); err == nil {
+			ex.nets = append(ex.nets, ipnet)
+			continue
+		}
+		if ips, ok, err := expandRange(spec); ok {
+			if err != nil {
+				return nil, err
+			}
+			for _, ip := range ips {
+				ex.ips[ip] = true
+			}
+			continue
+		}
+
+		host, _, err := splitTarget(spec)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			ex.ips[ip.String()] = true
+			continue
+		}
+
+		// Hostname: exclude the name itself and whatever it resolves to
+		ex.names[strings.ToLower(host)] = true
+		for _, ip := range resolveHost(host) {
+			ex.ips[ip.String()] = true
+		}
+	}
+	return ex, nil
+}
+
+// Excluded reports whether host (or any address it resolves to) is excluded.
+func (ex *targetExclusions) Excluded(host string, addrs []net.IP) bool {
+	if ex == nil {
+		return false
+	}
+	if ex.names[strings.ToLower(host)] {
+		return true
+	}
+	for _, ip := range addrs {
+		if ex.ips[ip.String()] {
+			return true
+		}
+		for _, n := range ex.nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveHost returns the addresses for host. IP literals are returned as-is;
+// lookup failures return nil (the connection attempt will report the error).
+func resolveHost(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		utils.LogDebug("Failed to resolve %s: %v", host, err)
+		return nil
+	}
+	return ips
+}
+
+// filterTargets applies exclusions and removes targets that resolve to an
+// address (and port) already being scanned under another name.
+func filterTargets(targets []string, ex *targetExclusions) []string {
+	seen := make(map[string]string) // ip[:port] -> first target name
+	var out []string
+
+	for _, tgt := range targets {
+		// Local paths are keyed by path only
+		if fi, err := os.Stat(tgt); err == nil && fi.IsDir() {
+			key := "local:" + tgt
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = tgt
+			out = append(out, tgt)
+			continue
+		}
+
+		// The host part of s3:// targets is a bucket name: nothing to
+		// resolve, they are deduplicated on the URL only
+		if u, err := url.Parse(tgt); err == nil && strings.EqualFold(u.Scheme, "s3") {
+			key := "s3:" + tgt
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = tgt
+			out = append(out, tgt)
+			continue
+		}
+
+		var host, port string
+		if isURLTarget(tgt) {
+			// URL targets are deduplicated on the full URL; exclusions
+			// still apply to the host part.
+			u, err := url.Parse(tgt)
+			if err != nil {
+				utils.LogError("Invalid target URL %s: %v", tgt, err)
+				continue
+			}
+			host, port = u.Hostname(), tgt
+		} else {
+			var err error
+			host, port, err = splitTarget(tgt)
+			if err != nil {
+				host, port = tgt, ""
+			}
+		}
+		addrs := resolveHost(host)
+
+		if ex.Excluded(host, addrs) {
+			utils.LogDebug("Excluding target: %s", tgt)
+			continue
+		}
+
+		keys := []string{strings.ToLower(host) + "|" + port}
+		for _, ip := range addrs {
+			keys = append(keys, ip.String()+"|"+port)
+		}
+
+		dup := ""
+		for _, k := range keys {
+			if first, ok := seen[k]; ok {
+				dup = first
+				break
+			}
+		}
+		if dup != "" {
+			utils.LogInfo("Skipping %s: same host as %s", tgt, dup)
+			continue
+		}
+		for _, k := range keys {
+			seen[k] = tgt
+		}
+		out = append(out, tgt)
+	}
+	return out
+}
+
+func inc(ip net.IP) {
+	for j := len(ip) - 1; j >= 0; j-- {
+		ip[j]++
+		if ip[j] > 0 {
+			break
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandTarget(t *testing.T) {
+	cases := map[string][]string{
+		"10.0.0.0/30":         {"10.0.0.1", "10.0.0.2"},
+		"10.0.0.8/31":         {"10.0.0.8", "10.0.0.9"},
+		"10.0.0.1-3":          {"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+		"10.0.0.254-10.0.1.1": {"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"},
+		"fe80::1":             {"fe80::1"},
+		"[fe80::1]":           {"fe80::1"},
+		"[fe80::1]:4455":      {"[fe80::1]:4455"},
+		"fs01:4455":           {"fs01:4455"},
+		"file-server":         {"file-server"},
+		"2001:db8::/127":      {"2001:db8::", "2001:db8::1"},
+	}
+
+	for in, want := range cases {
+		got, err := expandTarget(in)
+		if err != nil {
+			t.Errorf("expandTarget(%q): %v", in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expandTarget(%q) = %v, want %v", in, got, want)
+		}
+	}
+
+	if _, err := expandTarget("2001:db8::/64"); err == nil {
+		t.Error("expected oversized IPv6 range to be rejected")
+	}
+}
+
+func TestFilterTargets(t *testing.T) {
+	ex, err := newTargetExclusions([]string{"10.0.0.0/30", "10.0.0.9", "skipme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := []string{"10.0.0.1", "10.0.0.5", "10.0.0.9", "10.0.0.5", "10.0.0.5:4455", "skipme", "127.0.0.1", "localhost",
+		"s3://backups/db/", "s3://backups/db/", "s3://localhost", "s3://skipme"}
+	// Buckets are not hosts: not resolved, not excluded by host name
+	want := []string{"10.0.0.5", "10.0.0.5:4455", "127.0.0.1", "s3://backups/db/", "s3://localhost", "s3://skipme"}
+
+	got := filterTargets(in, ex)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterTargets = %v, want %v", got, want)
+	}
+}
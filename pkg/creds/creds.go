@@ -0,0 +1,88 @@
+// Package creds reads credentials out of configuration files by parsing
+// their format (XML, INI, dotenv, scripts...), so a password= in a comment
+// or a placeholder is not reported, and each value comes with where it is.
+package creds
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Category is the category of findings made of parsed credentials.
+const Category = "config-credential"
+
+// Credential is a secret found at a known place in a configuration file.
+type Credential struct {
+	// Key is the path to the value in the file: "section/key" for INI,
+	// elements and "@attribute" for XML, "-Password" for script arguments.
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// User is the account the secret goes with, when the file says.
+	User string `json:"user,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+type parser func(data []byte) []Credential
+
+// parserFor returns the parser for a configuration file name, or nil.
+func parserFor(name string) parser {
+	name = strings.ToLower(filepath.Base(name))
+	ext := filepath.Ext(name)
+
+	switch {
+	case name == "unattend.xml", name == "autounattend.xml", name == "sysprep.xml",
+		ext == ".config":
+		return parseXML
+	case name == "sysprep.inf", ext == ".ini", ext == ".inf", name == ".pypirc", name == ".npmrc":
+		return parseINI
+	case name == ".env", strings.HasPrefix(name, ".env."), ext == ".env":
+		return parseEnv
+	case ext == ".ps1", ext == ".psm1", ext == ".bat", ext == ".cmd":
+		return parseScript
+	case ext == ".rdp":
+		return parseRDP
+	case name == "wp-config.php":
+		return parseWPConfig
+	case name == "settings.py", name == "local_settings.py":
+		return parsePySettings
+	case name == ".git-credentials":
+		return parseGitCredentials
+	}
+	return nil
+}
+
+// Known tells whether name is a configuration file Parse understands.
+func Known(name string) bool {
+	return parserFor(name) != nil
+}
+
+// Parse returns the credentials in a configuration file, nil if there are
+// none or the format is not known.
+func Parse(name string, data []byte) []Credential {
+	p := parserFor(name)
+	if p == nil {
+		return nil
+	}
+	return p(data)
+}
+
+var (
+	secretKey = regexp.MustCompile(`(?i)pass(word|wd)?$|pass(word|wd)?[^a-z]|^pwd|[^a-z]pwd|secret|token|api_?key|apikey|access_?key|private_?key|credential|connection_?string|(^|:)_auth$|validationkey|decryptionkey`)
+	// Settings about passwords rather than passwords
+	notSecretKey = regexp.MustCompile(`(?i)length|expir|policy|timeout|reset|hint|required|complex|minimum|history|format|file$|path$|url$|enabled|lifetime|age$`)
+	// *SENSITIVE*DATA*DELETED* is what Windows setup leaves in answer files
+	placeholder = regexp.MustCompile(`^(\*sensitive\*data\*deleted\*|\$\{?\w+\}?|%\w+%|\{\{.*\}\}|<.*>|\*+|x+|null|none|nil|true|false|yes|no)$`)
+)
+
+// isSecretKey tells whether a key name holds a secret.
+func isSecretKey(key string) bool {
+	return secretKey.MatchString(key) && !notSecretKey.MatchString(key)
+}
+
+// isValue tells whether v is an actual value rather than a placeholder or a
+// variable reference.
+func isValue(v string) bool {
+	v = strings.TrimSpace(v)
+	return v != "" && !placeholder.MatchString(strings.ToLower(v))
+}
@@ -0,0 +1,357 @@
+package creds
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// entry is a key/value read from a line-based format, before it is known
+// whether it is a secret.
+type entry struct {
+	key, value string
+	line       int
+}
+
+// secrets keeps the entries whose key is secret-like and whose value is
+// real, and names their user from the other entries (DB_PASSWORD goes with
+// DB_USER, password with username).
+func secrets(entries []entry) []Credential {
+	vals := make(map[string]string)
+	for _, e := range entries {
+		vals[strings.ToLower(e.key)] = e.value
+	}
+	var creds []Credential
+	for _, e := range entries {
+		if isSecretKey(e.key) && isValue(e.value) {
+			creds = append(creds, Credential{Key: e.key, Value: e.value, User: pairedUser(e.key, vals), Line: e.line})
+		}
+	}
+	return creds
+}
+
+var passwordSuffix = regexp.MustCompile(`(?i)(password|passwd|pass|pwd)$`)
+
+func pairedUser(key string, vals map[string]string) string {
+	loc := passwordSuffix.FindStringIndex(key)
+	if loc == nil {
+		return ""
+	}
+	prefix := strings.ToLower(key[:loc[0]])
+	for _, suffix := range []string{"user", "username", "user_name", "login", "userid", "uid"} {
+		if u := vals[prefix+suffix]; u != "" {
+			return u
+		}
+	}
+	// sysprep.inf: DomainAdmin / DomainAdminPassword
+	if p := strings.TrimRight(prefix, "_-./"); p != "" {
+		return vals[p]
+	}
+	return ""
+}
+
+func lines(data []byte) []string {
+	var out []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		out = append(out, sc.Text())
+	}
+	return out
+}
+
+func unquote(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// parseINI reads .ini/.inf files (sysprep.inf), .pypirc and .npmrc: keys are
+// "section/key", users are looked for in the same section.
+func parseINI(data []byte) []Credential {
+	var creds []Credential
+	var section string
+	var entries []entry
+	flush := func() {
+		for _, c := range secrets(entries) {
+			if section != "" {
+				c.Key = section + "/" + c.Key
+			}
+			creds = append(creds, c)
+		}
+		entries = nil
+	}
+
+	for i, l := range lines(data) {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "", l[0] == ';', l[0] == '#':
+			continue
+		case l[0] == '[' && strings.HasSuffix(l, "]"):
+			flush()
+			section = strings.TrimSpace(l[1 : len(l)-1])
+			continue
+		}
+		// "key = value", or "key: value" (.pypirc)
+		sep := strings.IndexByte(l, '=')
+		if sep < 0 {
+			sep = strings.IndexByte(l, ':')
+		}
+		if sep <= 0 {
+			continue
+		}
+		entries = append(entries, entry{strings.TrimSpace(l[:sep]), unquote(l[sep+1:]), i + 1})
+	}
+	flush()
+	return creds
+}
+
+// parseEnv reads dotenv files: KEY=value, optionally exported and quoted.
+func parseEnv(data []byte) []Credential {
+	var entries []entry
+	for i, l := range lines(data) {
+		l = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), "export "))
+		if l == "" || l[0] == '#' {
+			continue
+		}
+		k, v, ok := strings.Cut(l, "=")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if v != "" && v[0] != '"' && v[0] != '\'' {
+			// Unquoted values end at an inline comment
+			if j := strings.Index(v, " #"); j >= 0 {
+				v = v[:j]
+			}
+		}
+		entries = append(entries, entry{strings.TrimSpace(k), unquote(v), i + 1})
+	}
+	return secrets(entries)
+}
+
+var (
+	scriptArg    = regexp.MustCompile(`(?i)(?:^|\s)(-(?:Password|Passwd|Pass|Pwd|AccountPassword|Secret|Token|ApiKey))[\s:]+("[^"]*"|'[^']*'|[^\s;|)]+)`)
+	scriptUser   = regexp.MustCompile(`(?i)(?:^|\s)-(?:UserName|User|Login|Account)[\s:]+("[^"]*"|'[^']*'|[^\s;|)]+)`)
+	secureString = regexp.MustCompile(`(?i)ConvertTo-SecureString\s+(?:-String\s+)?("[^"]*"|'[^']*')`)
+	psAssign     = regexp.MustCompile(`^\$([\w:]+)\s*=\s*("[^"]*"|'[^']*')\s*;?$`)
+	batSet       = regexp.MustCompile(`(?i)^set\s+("?)(\w+)=(.*?)("?)$`)
+)
+
+// parseScript reads PowerShell and batch scripts: -Password arguments,
+// ConvertTo-SecureString literals, net use and variable assignments.
+func parseScript(data []byte) []Credential {
+	var creds []Credential
+	inBlock := false
+	for i, l := range lines(data) {
+		t := strings.TrimSpace(l)
+		lt := strings.ToLower(t)
+		// PowerShell <# block comments #>
+		if inBlock {
+			inBlock = !strings.Contains(t, "#>")
+			continue
+		}
+		if strings.HasPrefix(t, "<#") {
+			inBlock = !strings.Contains(t, "#>")
+			continue
+		}
+		if t == "" || t[0] == '#' || strings.HasPrefix(t, "::") || lt == "rem" || strings.HasPrefix(lt, "rem ") {
+			continue
+		}
+
+		add := func(key, value, user string) {
+			value = unquote(value)
+			if isValue(value) && !strings.HasPrefix(value, "$") && !strings.HasPrefix(value, "(") {
+				creds = append(creds, Credential{Key: key, Value: value, User: unquote(user), Line: i + 1})
+			}
+		}
+
+		var user string
+		if m := scriptUser.FindStringSubmatch(t); m != nil {
+			user = m[1]
+		}
+		for _, m := range scriptArg.FindAllStringSubmatch(t, -1) {
+			add(m[1], m[2], user)
+		}
+		if m := secureString.FindStringSubmatch(t); m != nil {
+			add("ConvertTo-SecureString", m[1], user)
+		}
+		if u, pw, ok := netUse(t); ok {
+			add("net use", pw, u)
+		}
+		// $password = "literal" / set PASSWORD=literal
+		if m := psAssign.FindStringSubmatch(t); m != nil && isSecretKey(m[1]) {
+			add("$"+m[1], m[2], "")
+		}
+		if m := batSet.FindStringSubmatch(t); m != nil && m[1] == m[4] && isSecretKey(m[2]) {
+			add(m[2], m[3], "")
+		}
+	}
+	return creds
+}
+
+// netUse returns the account and password of a "net use [drive:]
+// \\server\share password /user:account" command.
+func netUse(line string) (user, pass string, ok bool) {
+	fields := strings.Fields(line)
+	i := slices.IndexFunc(fields, func(f string) bool { return strings.EqualFold(f, "net") })
+	if i < 0 || i+1 >= len(fields) || !strings.EqualFold(fields[i+1], "use") {
+		return "", "", false
+	}
+	share := false
+	for _, f := range fields[i+2:] {
+		switch {
+		case len(f) > 6 && strings.EqualFold(f[:6], "/user:"):
+			user = f[6:]
+		case strings.HasPrefix(f, "/"):
+		case strings.HasPrefix(f, `\\`):
+			share = true
+		case share && pass == "":
+			pass = f
+		}
+	}
+	return user, pass, pass != ""
+}
+
+// parseRDP reads .rdp files: the DPAPI-encrypted saved password, with the
+// user and the server it is for.
+func parseRDP(data []byte) []Credential {
+	data = utf16ToUTF8(data)
+	fields := make(map[string]string)
+	var pwLine int
+	for i, l := range lines(data) {
+		// name:type:value
+		parts := strings.SplitN(strings.TrimSpace(l), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		name := strings.ToLower(parts[0])
+		fields[name] = parts[2]
+		if name == "password 51" {
+			pwLine = i + 1
+		}
+	}
+	pw := fields["password 51"]
+	if pw == "" {
+		return nil
+	}
+	user := fields["username"]
+	if d := fields["domain"]; d != "" && user != "" && !strings.Contains(user, `\`) {
+		user = d + `\` + user
+	}
+	if addr := fields["full address"]; addr != "" {
+		user += "@" + addr
+	}
+	return []Credential{{Key: "password 51", Value: pw, User: user, Line: pwLine}}
+}
+
+// utf16ToUTF8 converts files saved as UTF-16LE with a BOM, as mstsc does.
+func utf16ToUTF8(data []byte) []byte {
+	if !bytes.HasPrefix(data, []byte{0xff, 0xfe}) {
+		return data
+	}
+	var sb strings.Builder
+	for i := 2; i+1 < len(data); i += 2 {
+		sb.WriteRune(rune(uint16(data[i]) | uint16(data[i+1])<<8))
+	}
+	return []byte(sb.String())
+}
+
+var (
+	blockComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	wpDefine     = regexp.MustCompile(`define\s*\(\s*['"](\w+)['"]\s*,\s*(?:'([^']*)'|"([^"]*)")\s*\)`)
+)
+
+// stripBlockComments blanks /* */ comments, keeping the line numbers.
+func stripBlockComments(data []byte) []byte {
+	return blockComment.ReplaceAllFunc(data, func(c []byte) []byte {
+		return bytes.Repeat([]byte("\n"), bytes.Count(c, []byte("\n")))
+	})
+}
+
+// parseWPConfig reads wp-config.php: database password and the keys and
+// salts defined with define().
+func parseWPConfig(data []byte) []Credential {
+	var entries []entry
+	for i, l := range lines(stripBlockComments(data)) {
+		t := strings.TrimSpace(l)
+		if strings.HasPrefix(t, "//") || strings.HasPrefix(t, "#") {
+			continue
+		}
+		for _, m := range wpDefine.FindAllStringSubmatch(t, -1) {
+			entries = append(entries, entry{m[1], m[2] + m[3], i + 1})
+		}
+	}
+
+	creds := secrets(entries)
+	// Authentication keys and salts
+	for _, e := range entries {
+		if (strings.HasSuffix(e.key, "_KEY") || strings.HasSuffix(e.key, "_SALT")) && !isSecretKey(e.key) && isValue(e.value) &&
+			!strings.Contains(e.value, "put your unique phrase here") {
+			creds = append(creds, Credential{Key: e.key, Value: e.value, Line: e.line})
+		}
+	}
+	return creds
+}
+
+var (
+	pyAssign   = regexp.MustCompile(`^(\w+)\s*=\s*(?:'([^']*)'|"([^"]*)")`)
+	pyDictItem = regexp.MustCompile(`['"](\w+)['"]\s*:\s*(?:'([^']*)'|"([^"]*)")`)
+	pyDictOpen = regexp.MustCompile(`(?:^(\w+)\s*=|['"](\w+)['"]\s*:)\s*\{`)
+)
+
+// parsePySettings reads Django settings: SECRET_KEY-like assignments and
+// the PASSWORD of nested dicts such as DATABASES, keyed by their path
+// ("DATABASES/default/PASSWORD").
+func parsePySettings(data []byte) []Credential {
+	var entries []entry
+	var path []string
+	for i, l := range lines(data) {
+		t := strings.TrimSpace(l)
+		if t == "" || t[0] == '#' {
+			continue
+		}
+		prefix := strings.Join(path, "/")
+		if prefix != "" {
+			prefix += "/"
+		}
+		if m := pyAssign.FindStringSubmatch(t); m != nil && len(path) == 0 {
+			entries = append(entries, entry{m[1], m[2] + m[3], i + 1})
+		}
+		for _, m := range pyDictItem.FindAllStringSubmatch(t, -1) {
+			entries = append(entries, entry{prefix + m[1], m[2] + m[3], i + 1})
+		}
+
+		// Follow the nesting: one level per "NAME = {" or "'key': {"
+		for _, m := range pyDictOpen.FindAllStringSubmatch(t, -1) {
+			path = append(path, m[1]+m[2])
+		}
+		for range strings.Count(t, "}") {
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+	return secrets(entries)
+}
+
+// parseGitCredentials reads the git credential store: one URL with user and
+// password per line.
+func parseGitCredentials(data []byte) []Credential {
+	var creds []Credential
+	for i, l := range lines(data) {
+		u, err := url.Parse(strings.TrimSpace(l))
+		if err != nil || u.User == nil {
+			continue
+		}
+		if pw, ok := u.User.Password(); ok && isValue(pw) {
+			creds = append(creds, Credential{Key: u.Scheme + "://" + u.Host, Value: pw, User: u.User.Username(), Line: i + 1})
+		}
+	}
+	return creds
+}
@@ -0,0 +1,203 @@
+package creds
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"strings"
+	"unicode/utf16"
+)
+
+// XML configuration: web.config/app.config (connectionStrings, appSettings,
+// identity and machineKey attributes...) and unattend.xml answer files.
+
+type xmlFrame struct {
+	name     string
+	path     string
+	line     int
+	text     strings.Builder
+	hasChild bool
+	children map[string]string // Text of the leaf children, by name
+	pending  []Credential      // Secrets of the children, waiting for a user
+}
+
+func parseXML(data []byte) []Credential {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+
+	var creds []Credential
+	var stack []*xmlFrame
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return creds
+		}
+		line, _ := d.InputPos()
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path := t.Name.Local
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.hasChild = true
+				path = parent.path + "/" + path
+			}
+			stack = append(stack, &xmlFrame{name: t.Name.Local, path: path, line: line, children: make(map[string]string)})
+			creds = append(creds, xmlAttributes(path, t.Attr, line)...)
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			var parent *xmlFrame
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+
+			text := strings.TrimSpace(f.text.String())
+			if !f.hasChild && parent != nil {
+				parent.children[f.name] = text
+			}
+
+			if isSecretKey(f.name) {
+				c := Credential{Key: f.path, Value: text, Line: f.line}
+				if v, ok := f.children["Value"]; ok && f.hasChild {
+					// unattend.xml: <Password><Value>..</Value><PlainText>false</PlainText>
+					c.Key, c.Value = f.path+"/Value", v
+					if strings.EqualFold(f.children["PlainText"], "false") {
+						c.Value = unattendPassword(v, f.name)
+					}
+				} else if f.hasChild {
+					c.Value = ""
+				}
+				if isValue(c.Value) {
+					if parent != nil {
+						parent.pending = append(parent.pending, c)
+					} else {
+						creds = append(creds, c)
+					}
+				}
+			}
+
+			// The account is a sibling of the password (AutoLogon, LocalAccount)
+			user := xmlUser(f.children)
+			for _, c := range f.pending {
+				c.User = user
+				creds = append(creds, c)
+			}
+		}
+	}
+}
+
+// xmlUser returns the account named by leaf elements, with its domain.
+func xmlUser(children map[string]string) string {
+	for _, k := range []string{"Username", "UserName", "Name", "User"} {
+		if u := children[k]; u != "" {
+			if dom := children["Domain"]; dom != "" {
+				return dom + `\` + u
+			}
+			return u
+		}
+	}
+	return ""
+}
+
+// xmlAttributes returns the secrets held in attributes: <add> entries of
+// connectionStrings and appSettings, and password-like attributes elsewhere
+// (<identity password=..>, <network password=..>, <machineKey ..>).
+func xmlAttributes(path string, attrs []xml.Attr, line int) []Credential {
+	get := func(name string) string {
+		for _, a := range attrs {
+			if strings.EqualFold(a.Name.Local, name) {
+				return a.Value
+			}
+		}
+		return ""
+	}
+
+	var creds []Credential
+	if cs := get("connectionString"); cs != "" {
+		key := path
+		if name := get("name"); name != "" {
+			key += "[@name='" + name + "']"
+		}
+		creds = append(creds, connectionString(key+"/@connectionString", cs, line)...)
+	}
+	if k, v := get("key"), get("value"); k != "" {
+		key := path + "[@key='" + k + "']/@value"
+		switch {
+		case isConnectionString(v):
+			creds = append(creds, connectionString(key, v, line)...)
+		case isSecretKey(k) && isValue(v):
+			creds = append(creds, Credential{Key: key, Value: v, Line: line})
+		}
+	}
+
+	user := cmp.Or(get("userName"), get("user"), get("username"))
+	for _, a := range attrs {
+		switch strings.ToLower(a.Name.Local) {
+		case "connectionstring", "key", "value":
+			continue
+		case "pass":
+			continue // unattend.xml <settings pass="oobeSystem">
+		}
+		if isSecretKey(a.Name.Local) && isValue(a.Value) {
+			creds = append(creds, Credential{Key: path + "/@" + a.Name.Local, Value: a.Value, User: user, Line: line})
+		}
+	}
+	return creds
+}
+
+func isConnectionString(v string) bool {
+	l := strings.ToLower(v)
+	return strings.Contains(l, "password=") || strings.Contains(l, "pwd=")
+}
+
+// connectionString returns the password of an ADO.NET/ODBC connection
+// string, with its user.
+func connectionString(key, cs string, line int) []Credential {
+	var user, pass, field string
+	for _, part := range strings.Split(cs, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		// Entity Framework nests the provider string in quotes:
+		// provider connection string="data source=..;password=.."
+		k = strings.TrimSpace(k[strings.LastIndexAny(k, `"'`)+1:])
+		v = strings.Trim(strings.TrimSpace(v), `"'`)
+		switch strings.ToLower(k) {
+		case "password", "pwd":
+			pass, field = v, k
+		case "user id", "uid", "user", "username", "user name":
+			user = v
+		}
+	}
+	if !isValue(pass) {
+		return nil
+	}
+	return []Credential{{Key: key + "/" + field, Value: pass, User: user, Line: line}}
+}
+
+// unattendPassword decodes an obfuscated answer file password: base64 of
+// the UTF-16LE password followed by the element name.
+func unattendPassword(v, element string) string {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+	if err != nil || len(b)%2 != 0 {
+		return v
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return strings.TrimSuffix(string(utf16.Decode(u)), element)
+}
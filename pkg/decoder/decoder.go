@@ -0,0 +1,214 @@
+// Package decoder finds encoded blobs in extracted text (base64, hex) and
+// decodes them, through gzip, zlib or deflate compression and UTF-16, so
+// secrets hidden in PowerShell -EncodedCommand arguments, Kubernetes secrets
+// or compressed payloads can be matched like the rest of the text.
+package decoder
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MaxDepth is how many times Decode looks for blobs in decoded text
+// (base64 of base64...).
+const MaxDepth = 3
+
+// maxOutput caps the decoded bytes for one text (compression bombs).
+const maxOutput = 10 * 1024 * 1024
+
+// maxInflate is how many compression layers are undone in a row, so that a
+// stream inflating to itself (a gzip quine) stops.
+const maxInflate = 3
+
+// Runs are decoded from 16 base64 characters (12 bytes, a short Kubernetes
+// secret value) and from 32 hex digits (16 bytes).
+const minHex = 32
+
+var blobRun = regexp.MustCompile(`[A-Za-z0-9+/_-]{16,}={0,2}`)
+
+// Layer is text that was found encoded.
+type Layer struct {
+	// Chain lists the encodings undone, outermost first, e.g.
+	// ["base64", "gzip"] or ["base64", "utf-16le"].
+	Chain []string
+	Text  string
+}
+
+// Decode returns the text decoded from the base64 and hex runs of text, and
+// from the runs found in that text again, up to MaxDepth. Runs that do not
+// decode to text, or to compressed text, are ignored.
+func Decode(text string) []Layer {
+	d := &decoder{budget: maxOutput, seen: make(map[string]bool)}
+	d.text(text, nil, 1)
+	return d.layers
+}
+
+type decoder struct {
+	layers []Layer
+	budget int
+	seen   map[string]bool
+}
+
+func (d *decoder) text(text string, chain []string, depth int) {
+	for _, run := range blobRun.FindAllString(text, -1) {
+		if d.budget <= 0 {
+			return
+		}
+		if d.seen[run] {
+			continue
+		}
+		d.seen[run] = true
+
+		if len(run) >= minHex && len(run)%2 == 0 && isHex(run) {
+			if b, err := hex.DecodeString(run); err == nil && d.data(b, with(chain, "hex"), depth, 0) {
+				continue
+			}
+		}
+		if b, ok := decodeBase64(run); ok {
+			d.data(b, with(chain, "base64"), depth, 0)
+		}
+	}
+}
+
+// data keeps b if it is text, or decompresses it (inflated is the number
+// of layers already undone), and reports whether it held anything.
+func (d *decoder) data(b []byte, chain []string, depth, inflated int) bool {
+	if len(b) < 8 {
+		return false
+	}
+	if inflated < maxInflate {
+		if out, name, ok := d.inflate(b); ok {
+			d.budget -= len(out)
+			return d.data(out, with(chain, name), depth, inflated+1)
+		}
+	}
+	if s, ok := utf16Text(b); ok {
+		chain = with(chain, "utf-16le")
+		b = []byte(s)
+	}
+	if !isText(b) {
+		return false
+	}
+
+	d.budget -= len(b)
+	text := string(b)
+	d.layers = append(d.layers, Layer{Chain: chain, Text: text})
+	if depth < MaxDepth {
+		d.text(text, chain, depth+1)
+	}
+	return true
+}
+
+// inflate decompresses gzip and zlib streams (by their header), and raw
+// deflate streams that inflate to text, as PowerShell's DeflateStream
+// produces.
+func (d *decoder) inflate(b []byte) ([]byte, string, bool) {
+	var r io.Reader
+	var name string
+	switch {
+	case len(b) > 2 && b[0] == 0x1f && b[1] == 0x8b:
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, "", false
+		}
+		r, name = zr, "gzip"
+	case len(b) > 2 && b[0] == 0x78 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0:
+		zr, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, "", false
+		}
+		r, name = zr, "zlib"
+	case !isText(b):
+		r, name = flate.NewReader(bytes.NewReader(b)), "deflate"
+	default:
+		return nil, "", false
+	}
+
+	out, err := io.ReadAll(io.LimitReader(r, int64(d.budget)))
+	if len(out) == 0 || (err != nil && err != io.ErrUnexpectedEOF) {
+		return nil, "", false
+	}
+	if name == "deflate" && !isText(out) {
+		if _, ok := utf16Text(out); !ok {
+			return nil, "", false
+		}
+	}
+	return out, name, true
+}
+
+func with(chain []string, enc string) []string {
+	return append(chain[:len(chain):len(chain)], enc)
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeBase64 decodes standard or URL-safe base64, padded or not.
+func decodeBase64(s string) ([]byte, bool) {
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		if strings.ContainsAny(s, "+/") {
+			return nil, false
+		}
+		enc = base64.URLEncoding
+	}
+	if !strings.HasSuffix(s, "=") {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	b, err := enc.DecodeString(s)
+	return b, err == nil
+}
+
+// utf16Text decodes UTF-16LE text (PowerShell -EncodedCommand): every other
+// byte is zero for ASCII.
+func utf16Text(b []byte) (string, bool) {
+	if len(b) < 4 || len(b)%2 != 0 {
+		return "", false
+	}
+	zeros := 0
+	for i := 1; i < len(b); i += 2 {
+		if b[i] == 0 {
+			zeros++
+		}
+	}
+	if zeros*10 < len(b)/2*9 {
+		return "", false
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+	}
+	s := string(utf16.Decode(u))
+	return s, isText([]byte(s))
+}
+
+// isText tells whether b is UTF-8 text: at least 95% printable characters.
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	total, printable := 0, 0
+	for _, r := range string(b) {
+		total++
+		if r >= 0x20 && r != 0x7f && r != utf8.RuneError || r == '\t' || r == '\n' || r == '\r' {
+			printable++
+		}
+	}
+	return total > 0 && printable*100 >= total*95
+}
@@ -0,0 +1,123 @@
+package decoder_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/0xSterny/spuderman/pkg/decoder"
+)
+
+func utf16LE(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return b
+}
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func deflated(b []byte) []byte {
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.BestCompression)
+	fw.Write(b)
+	fw.Close()
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+	tests := []struct {
+		name  string
+		text  string
+		chain []string
+		want  string
+	}{
+		{"encoded command",
+			"powershell.exe -NoP -EncodedCommand " + b64(utf16LE(`net user svc_backup Backup2024! /add`)),
+			[]string{"base64", "utf-16le"}, "Backup2024!"},
+		{"kubernetes secret",
+			"data:\n  password: " + b64([]byte("hunter2hunter2")) + "\n",
+			[]string{"base64"}, "hunter2hunter2"},
+		{"gzip",
+			`$blob = "` + b64(gzipped("DB_PASSWORD=Gz1pped!")) + `"`,
+			[]string{"base64", "gzip"}, "DB_PASSWORD=Gz1pped!"},
+		{"deflate stream",
+			"IEX (New-Object IO.StreamReader(New-Object IO.Compression.DeflateStream([IO.MemoryStream][Convert]::FromBase64String('" +
+				b64(deflated([]byte("$pass = 'Defl4ted!'"))) + "'),[IO.Compression.CompressionMode]::Decompress))).ReadToEnd()",
+			[]string{"base64", "deflate"}, "Defl4ted!"},
+		{"hex",
+			"key=" + hex.EncodeToString([]byte("aws_secret_access_key=abc")),
+			[]string{"hex"}, "aws_secret_access_key=abc"},
+		{"nested",
+			"payload: " + b64([]byte("inner="+b64([]byte("password=Nested!")))),
+			[]string{"base64", "base64"}, "password=Nested!"},
+		{"url-safe",
+			"token=" + base64.RawURLEncoding.EncodeToString([]byte("user:Pa55w0rd???>>>")),
+			[]string{"base64"}, "user:Pa55w0rd???>>>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layers := decoder.Decode(tt.text)
+			for _, l := range layers {
+				if strings.Contains(l.Text, tt.want) {
+					if !slices.Equal(l.Chain, tt.chain) {
+						t.Errorf("chain: got %v, want %v", l.Chain, tt.chain)
+					}
+					return
+				}
+			}
+			t.Errorf("%q not decoded from %q: %+v", tt.want, tt.text, layers)
+		})
+	}
+}
+
+func TestDecodeIgnoresNonText(t *testing.T) {
+	text := strings.Join([]string{
+		"ConfigurationManagerSettingsProvider",
+		"d41d8cd98f00b204e9800998ecf8427e", // MD5
+		base64.StdEncoding.EncodeToString([]byte{0x00, 0x01, 0xfe, 0xff, 0x10, 0x80, 0x81, 0x02, 0x03, 0x04, 0x9f, 0x00}),
+		"/usr/local/share/applications/something",
+	}, "\n")
+	if layers := decoder.Decode(text); len(layers) != 0 {
+		t.Errorf("got %+v", layers)
+	}
+}
+
+func TestDecodeDepth(t *testing.T) {
+	s := "password=TooDeep!"
+	for range decoder.MaxDepth + 1 {
+		s = base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	for _, l := range decoder.Decode(s) {
+		if strings.Contains(l.Text, "TooDeep") {
+			t.Errorf("decoded past MaxDepth: %+v", l)
+		}
+	}
+}
+
+func TestDecodeInflateLayers(t *testing.T) {
+	// Each layer inflates to another gzip stream, as a gzip quine does
+	// forever
+	b := []byte("password=Squashed!")
+	for range 50 {
+		b = gzipped(string(b))
+	}
+	for _, l := range decoder.Decode(base64.StdEncoding.EncodeToString(b)) {
+		t.Errorf("decoded through 50 compression layers: %v", l.Chain)
+	}
+}
@@ -0,0 +1,387 @@
+package detector
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/pkcs12"
+	"golang.org/x/crypto/ssh"
+)
+
+// Credential containers: password databases, certificate bundles, private
+// keys and client configs with embedded secrets. Severity is critical when
+// the secret is usable as is (no passphrase), high otherwise.
+
+const (
+	maxTextSize = 256 * 1024
+	maxPFXSize  = 1 << 20
+)
+
+var (
+	kdbxMagic = []byte{0x03, 0xd9, 0xa2, 0x9a, 0x67, 0xfb, 0x4b, 0xb5} // KeePass 2.x
+	kdbMagic  = []byte{0x03, 0xd9, 0xa2, 0x9a, 0x65, 0xfb, 0x4b, 0xb5} // KeePass 1.x
+	ppkMagic  = []byte("PuTTY-User-Key-File-")
+	pemBegin  = []byte("-----BEGIN ")
+	utf16BOM  = []byte{0xff, 0xfe}
+
+	// OID 1.2.840.113549.1.7.1 (PKCS#7 data), the authSafe of a PFX
+	pkcs7DataOID = []byte{0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x07, 0x01}
+)
+
+// sniffCredential checks the magic bytes of credential containers. OpenVPN
+// and RDP files have no magic, so their extension is enough to look closer.
+func sniffCredential(name string, head []byte) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".ovpn", ".rdp":
+		return true
+	}
+	return bytes.HasPrefix(head, kdbxMagic) || bytes.HasPrefix(head, kdbMagic) ||
+		bytes.HasPrefix(head, ppkMagic) || isPFX(head) ||
+		bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), pemBegin)
+}
+
+func detectCredential(r io.ReaderAt, head []byte) *Detection {
+	switch {
+	case bytes.HasPrefix(head, kdbxMagic):
+		return detectKDBX(readPrefix(r, 64*1024))
+	case bytes.HasPrefix(head, kdbMagic):
+		return detectKDB(head)
+	case isPFX(head):
+		return detectPFX(readPrefix(r, maxPFXSize))
+	}
+
+	text := readPrefix(r, maxTextSize)
+	if bytes.HasPrefix(text, utf16BOM) {
+		// mstsc saves .rdp files as UTF-16
+		text = []byte(decodeUTF16(text[2:]))
+	}
+	switch {
+	case bytes.HasPrefix(text, ppkMagic):
+		return detectPPK(string(text))
+	case bytes.HasPrefix(bytes.TrimLeft(text, " \t\r\n"), pemBegin):
+		return detectPrivateKey(text)
+	case bytes.Contains(text, []byte("full address:s:")):
+		return detectRDP(string(text))
+	case ovpnRemote.Match(text):
+		return detectOVPN(string(text))
+	}
+	return nil
+}
+
+func readPrefix(r io.ReaderAt, max int) []byte {
+	buf := make([]byte, max)
+	n, _ := r.ReadAt(buf, 0)
+	return buf[:n]
+}
+
+func newCredential(kind string) *Detection {
+	return &Detection{Kind: kind, Category: CategoryCredential, Severity: SeverityHigh}
+}
+
+// protection records whether a secret needs a passphrase, and raises the
+// severity if it does not.
+func (d *Detection) protection(protected bool) {
+	if protected {
+		d.Details = append(d.Details, "passphrase-protected")
+	} else {
+		d.Details = append(d.Details, "no passphrase")
+		d.Severity = SeverityCritical
+	}
+}
+
+// KeePass
+
+var (
+	keepassCiphers = map[string]string{
+		"31c1f2e6bf714350be5805216afc5aff": "AES-256",
+		"d6038a2b8b6f4cb5a524339a31dbb59a": "ChaCha20",
+		"ad68f29f576f4bb9a36ad47af965346c": "Twofish",
+	}
+	keepassKDFs = map[string]string{
+		"c9d9f39a628a4460bf740d08c18a4fea": "AES-KDF",
+		"ef636ddf8c29444b91f7a9a403e30a0c": "Argon2d",
+		"9e298b1956db4773b23dfc3ec6f0a1e6": "Argon2id",
+	}
+)
+
+func detectKDBX(data []byte) *Detection {
+	d := newCredential("KeePass database")
+	if len(data) < 12 {
+		return d
+	}
+	minor := binary.LittleEndian.Uint16(data[8:])
+	major := binary.LittleEndian.Uint16(data[10:])
+	d.Details = append(d.Details, fmt.Sprintf("KDBX %d.%d", major, minor))
+
+	// Header fields: id, length (2 bytes before KDBX 4, 4 from then on), value
+	for off := 12; off < len(data); {
+		id := data[off]
+		var size int
+		if major < 4 {
+			if off+3 > len(data) {
+				break
+			}
+			size, off = int(binary.LittleEndian.Uint16(data[off+1:])), off+3
+		} else {
+			if off+5 > len(data) {
+				break
+			}
+			size, off = int(binary.LittleEndian.Uint32(data[off+1:])), off+5
+		}
+		if id == 0 || size < 0 || off+size > len(data) {
+			break
+		}
+		value := data[off : off+size]
+		off += size
+
+		switch id {
+		case 2: // CipherID
+			if name, ok := keepassCiphers[hex.EncodeToString(value)]; ok {
+				d.Details = append(d.Details, name)
+			}
+		case 6: // TransformRounds (KDBX 3)
+			if len(value) == 8 {
+				d.Details = append(d.Details, fmt.Sprintf("AES-KDF, %d rounds", binary.LittleEndian.Uint64(value)))
+			}
+		case 11: // KdfParameters (KDBX 4)
+			if kdf := keepassKDF(value); kdf != "" {
+				d.Details = append(d.Details, kdf)
+			}
+		}
+	}
+	return d
+}
+
+// keepassKDF describes the KDF parameters of a KDBX 4 file, stored as a
+// VariantDictionary: version, then (type, key, value) entries.
+func keepassKDF(dict []byte) string {
+	params := make(map[string][]byte)
+	for off := 2; off+5 <= len(dict); {
+		if dict[off] == 0 {
+			break
+		}
+		keyLen := int(binary.LittleEndian.Uint32(dict[off+1:]))
+		off += 5
+		if keyLen < 0 || off+keyLen+4 > len(dict) {
+			break
+		}
+		key := string(dict[off : off+keyLen])
+		valLen := int(binary.LittleEndian.Uint32(dict[off+keyLen:]))
+		off += keyLen + 4
+		if valLen < 0 || off+valLen > len(dict) {
+			break
+		}
+		params[key] = dict[off : off+valLen]
+		off += valLen
+	}
+
+	name := keepassKDFs[hex.EncodeToString(params["$UUID"])]
+	switch {
+	case name == "AES-KDF" && len(params["R"]) == 8:
+		return fmt.Sprintf("%s, %d rounds", name, binary.LittleEndian.Uint64(params["R"]))
+	case name != "" && len(params["I"]) == 8 && len(params["M"]) == 8:
+		return fmt.Sprintf("%s, %d iterations, %d MiB", name,
+			binary.LittleEndian.Uint64(params["I"]), binary.LittleEndian.Uint64(params["M"])>>20)
+	}
+	return name
+}
+
+func detectKDB(head []byte) *Detection {
+	d := newCredential("KeePass 1.x database")
+	if len(head) >= 12 {
+		flags := binary.LittleEndian.Uint32(head[8:])
+		switch {
+		case flags&2 != 0:
+			d.Details = append(d.Details, "AES-256")
+		case flags&8 != 0:
+			d.Details = append(d.Details, "Twofish")
+		}
+	}
+	return d
+}
+
+// PKCS#12
+
+// isPFX matches the start of a PFX: SEQUENCE { INTEGER 3, SEQUENCE { OID data ...
+func isPFX(head []byte) bool {
+	if len(head) < 4 || head[0] != 0x30 {
+		return false
+	}
+	off := 2
+	if head[1]&0x80 != 0 {
+		off += int(head[1] & 0x7f)
+	}
+	rest := head[min(off, len(head)):]
+	if !bytes.HasPrefix(rest, []byte{0x02, 0x01, 0x03, 0x30}) || len(rest) < 5 {
+		return false
+	}
+	// Skip the length of the inner SEQUENCE
+	inner := 5
+	if rest[4]&0x80 != 0 {
+		inner += int(rest[4] & 0x7f)
+	}
+	return bytes.HasPrefix(rest[min(inner, len(rest)):], pkcs7DataOID)
+}
+
+func detectPFX(data []byte) *Detection {
+	d := newCredential("PKCS#12 certificate bundle")
+
+	// Exports with "no password" use an empty one
+	blocks, err := pkcs12.ToPEM(data, "")
+	switch {
+	case err == nil:
+		d.protection(false)
+		for _, b := range blocks {
+			if b.Type != "CERTIFICATE" {
+				continue
+			}
+			if cert, err := x509.ParseCertificate(b.Bytes); err == nil {
+				d.Details = append(d.Details, "subject "+cert.Subject.String())
+				break
+			}
+		}
+	case errors.Is(err, pkcs12.ErrIncorrectPassword):
+		d.protection(true)
+	default:
+		// Newer encryption (PBES2/AES) is not supported by the decoder
+		d.Details = append(d.Details, "encryption not checked")
+	}
+	return d
+}
+
+// PuTTY
+
+func detectPPK(text string) *Detection {
+	d := newCredential("PuTTY private key")
+	fields := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		if k, v, ok := strings.Cut(strings.TrimRight(line, "\r"), ": "); ok {
+			fields[k] = v
+		}
+	}
+
+	for k, v := range fields {
+		if strings.HasPrefix(k, string(ppkMagic)) {
+			d.Details = append(d.Details, v)
+		}
+	}
+	if c := fields["Comment"]; c != "" {
+		d.Details = append(d.Details, "comment "+c)
+	}
+	d.protection(fields["Encryption"] != "none")
+	return d
+}
+
+// PEM and OpenSSH private keys (id_rsa, id_ed25519, *.key, ...)
+
+func detectPrivateKey(text []byte) *Detection {
+	text = bytes.TrimLeft(text, " \t\r\n")
+	if first, _, _ := bytes.Cut(text, []byte("\n")); !bytes.Contains(first, []byte("PRIVATE KEY-----")) {
+		return nil // A certificate or public key
+	}
+
+	d := newCredential("Private key")
+	keyType, protected := parsePrivateKey(text)
+	if keyType != "" {
+		d.Details = append(d.Details, keyType)
+	}
+	d.protection(protected)
+	return d
+}
+
+// parsePrivateKey returns the type of the first PEM private key in text and
+// whether it is encrypted.
+func parsePrivateKey(text []byte) (keyType string, protected bool) {
+	key, err := ssh.ParsePrivateKey(text)
+	if err == nil {
+		return key.PublicKey().Type(), false
+	}
+
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		// OpenSSH keys keep the public key in clear
+		if missing.PublicKey != nil {
+			return missing.PublicKey.Type(), true
+		}
+		return "", true
+	}
+	// Unsupported key type: fall back to the PEM headers
+	return "", bytes.Contains(text, []byte("ENCRYPTED"))
+}
+
+// RDP
+
+func detectRDP(text string) *Detection {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		// name:type:value
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(parts) == 3 {
+			fields[strings.ToLower(parts[0])] = parts[2]
+		}
+	}
+	if fields["password 51"] == "" {
+		return nil
+	}
+
+	// The password is a DPAPI blob, only usable with the user's master key
+	d := newCredential("RDP file with saved password")
+	d.Details = append(d.Details, "DPAPI blob")
+	for _, f := range []string{"full address", "username"} {
+		if v := fields[f]; v != "" {
+			d.Details = append(d.Details, f+" "+v)
+		}
+	}
+	return d
+}
+
+// OpenVPN
+
+var (
+	ovpnRemote     = regexp.MustCompile(`(?m)^\s*(remote|client)\b`)
+	ovpnRemoteHost = regexp.MustCompile(`(?m)^\s*remote\s+(\S+)`)
+	ovpnInline     = regexp.MustCompile(`(?s)<(key|pkcs12|tls-auth|tls-crypt|tls-crypt-v2|auth-user-pass)>(.*?)</(key|pkcs12|tls-auth|tls-crypt|tls-crypt-v2|auth-user-pass)>`)
+)
+
+func detectOVPN(text string) *Detection {
+	inline := make(map[string]string)
+	for _, m := range ovpnInline.FindAllStringSubmatch(text, -1) {
+		inline[m[1]] = m[2]
+	}
+	if len(inline) == 0 {
+		return nil
+	}
+
+	d := newCredential("OpenVPN config with inline secrets")
+	if m := ovpnRemoteHost.FindStringSubmatch(text); m != nil {
+		d.Details = append(d.Details, "remote "+m[1])
+	}
+
+	var names []string
+	for _, n := range []string{"key", "pkcs12", "auth-user-pass", "tls-auth", "tls-crypt", "tls-crypt-v2"} {
+		if _, ok := inline[n]; ok {
+			names = append(names, n)
+		}
+	}
+	d.Details = append(d.Details, "inline "+strings.Join(names, ", "))
+
+	switch {
+	case inline["auth-user-pass"] != "":
+		d.Severity = SeverityCritical
+	case inline["key"] != "":
+		keyType, protected := parsePrivateKey([]byte(strings.TrimSpace(inline["key"])))
+		if keyType != "" {
+			d.Details = append(d.Details, keyType)
+		}
+		d.protection(protected)
+	}
+	return d
+}
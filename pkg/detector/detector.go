@@ -0,0 +1,78 @@
+package detector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Severity levels attached to detections.
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+)
+
+// Categories of detected files.
+const (
+	CategoryRegistryHive = "registry-hive"
+	CategoryNTDS         = "ntds"
+	CategoryESEDatabase  = "ese-database"
+	CategoryCredential   = "credential"
+)
+
+// SniffSize is how much of the start of a file Sniff needs.
+const SniffSize = 512
+
+// Detection describes a file identified by its content rather than its name.
+type Detection struct {
+	Kind     string   // e.g. "SAM hive", "NTDS.dit", "KeePass database"
+	Category string   // One of the Category constants
+	Severity string   // One of the Severity constants
+	Details  []string // Parsed metadata, e.g. "last written 2024-05-01T10:00:00Z", "no passphrase"
+}
+
+// Reason formats the detection for MatchResult.Reason.
+func (d *Detection) Reason() string {
+	if len(d.Details) == 0 {
+		return d.Kind
+	}
+	return fmt.Sprintf("%s (%s)", d.Kind, strings.Join(d.Details, "; "))
+}
+
+var (
+	regfMagic = []byte("regf")
+	eseMagic  = []byte{0xef, 0xcd, 0xab, 0x89}
+)
+
+// Sniff reports whether the file name, starting with head (its first
+// SniffSize bytes), looks like a format Detect knows. It is cheap and meant
+// to run on every file.
+func Sniff(name string, head []byte) bool {
+	return bytes.HasPrefix(head, regfMagic) || isESEHeader(head) || sniffCredential(name, head)
+}
+
+// isESEHeader checks for the ESE database header (signature at offset 4,
+// file type 0 = database; 1 would be a streaming file).
+func isESEHeader(head []byte) bool {
+	return len(head) >= 16 && bytes.Equal(head[4:8], eseMagic) && binary.LittleEndian.Uint32(head[12:]) == 0
+}
+
+// Detect identifies and parses the file in r, reading only the parts it
+// needs. It returns nil if the file is not a known format. Parse errors past
+// the magic bytes do not hide the detection; they just mean fewer details.
+func Detect(r io.ReaderAt) *Detection {
+	head := make([]byte, SniffSize)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, regfMagic):
+		return detectHive(r)
+	case isESEHeader(head):
+		return detectESE(r)
+	}
+	return detectCredential(r, head)
+}
@@ -0,0 +1,235 @@
+package detector_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/0xSterny/spuderman/pkg/detector"
+)
+
+// hiveBuilder writes a minimal regf file: base block, one bin, nk cells
+// and lf subkey lists.
+type hiveBuilder struct {
+	bins []byte
+}
+
+func (b *hiveBuilder) cell(data []byte) uint32 {
+	off := uint32(len(b.bins))
+	size := (len(data) + 4 + 7) &^ 7
+	c := make([]byte, size)
+	binary.LittleEndian.PutUint32(c, uint32(-int32(size)))
+	copy(c[4:], data)
+	b.bins = append(b.bins, c...)
+	return off
+}
+
+// key adds a key with the given subkeys and returns its cell offset.
+func (b *hiveBuilder) key(name string, subkeys ...uint32) uint32 {
+	nk := make([]byte, 76+len(name))
+	copy(nk, "nk")
+	binary.LittleEndian.PutUint16(nk[2:], 0x20) // ASCII name
+	binary.LittleEndian.PutUint32(nk[20:], uint32(len(subkeys)))
+	if len(subkeys) > 0 {
+		lf := make([]byte, 4+8*len(subkeys))
+		copy(lf, "lf")
+		binary.LittleEndian.PutUint16(lf[2:], uint16(len(subkeys)))
+		for i, sk := range subkeys {
+			binary.LittleEndian.PutUint32(lf[4+8*i:], sk)
+		}
+		binary.LittleEndian.PutUint32(nk[28:], b.cell(lf))
+	}
+	binary.LittleEndian.PutUint16(nk[72:], uint16(len(name)))
+	copy(nk[76:], name)
+	return b.cell(nk)
+}
+
+func (b *hiveBuilder) bytes(root uint32, fileName string) []byte {
+	base := make([]byte, 4096)
+	copy(base, "regf")
+	binary.LittleEndian.PutUint64(base[12:], 133589952000000000) // 2024-05-01T00:00:00Z
+	binary.LittleEndian.PutUint32(base[36:], root)
+	for i, c := range utf16.Encode([]rune(fileName)) {
+		binary.LittleEndian.PutUint16(base[48+2*i:], c)
+	}
+	return append(base, b.bins...)
+}
+
+func newHiveBuilder() *hiveBuilder {
+	b := &hiveBuilder{bins: make([]byte, 32)}
+	copy(b.bins, "hbin")
+	return b
+}
+
+func TestDetectHive(t *testing.T) {
+	sam := newHiveBuilder()
+	users := sam.key("Users",
+		sam.key("000001F4"),
+		sam.key("Names", sam.key("Administrator"), sam.key("Guest"), sam.key("svc_backup")))
+	root := sam.key("ROOT", sam.key("SAM", sam.key("Domains", sam.key("Account", users), sam.key("Builtin"))))
+	// A renamed copy: the type comes from the keys, not the name
+	samData := sam.bytes(root, `\backup\hklm_sam.save`)
+
+	system := newHiveBuilder()
+	root = system.key("ROOT", system.key("ControlSet001"), system.key("MountedDevices"), system.key("Select"), system.key("Setup"))
+	systemData := system.bytes(root, `emRoot\System32\Config\SYSTEM`)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		kind     string
+		severity string
+		details  []string
+	}{
+		{"sam", samData, "SAM hive", detector.SeverityCritical, []string{
+			"last written 2024-05-01T00:00:00Z",
+			"3 local accounts: Administrator, Guest, svc_backup",
+		}},
+		{"system", systemData, "SYSTEM hive", detector.SeverityCritical, []string{
+			"keys: ControlSet001, MountedDevices, Select, Setup",
+		}},
+		{"corrupt", samData[:4200], "Registry hive", detector.SeverityHigh, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !detector.Sniff("hklm_sam.save", tt.data[:detector.SniffSize]) {
+				t.Fatal("Sniff did not recognise the hive")
+			}
+			d := detector.Detect(bytes.NewReader(tt.data))
+			if d == nil {
+				t.Fatal("no detection")
+			}
+			if d.Kind != tt.kind || d.Severity != tt.severity || d.Category != detector.CategoryRegistryHive {
+				t.Errorf("got %s/%s/%s, want %s/%s/%s", d.Kind, d.Severity, d.Category, tt.kind, tt.severity, detector.CategoryRegistryHive)
+			}
+			reason := d.Reason()
+			for _, want := range tt.details {
+				if !strings.Contains(reason, want) {
+					t.Errorf("reason %q does not contain %q", reason, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectESE(t *testing.T) {
+	const pageSize = 8192
+	ese := func(tables ...string) []byte {
+		data := make([]byte, 8*pageSize)
+		binary.LittleEndian.PutUint32(data[4:], 0x89abcdef)
+		binary.LittleEndian.PutUint32(data[52:], 2) // dirty shutdown
+		binary.LittleEndian.PutUint32(data[236:], pageSize)
+		off := 5 * pageSize // catalog (page 4)
+		for _, table := range tables {
+			off += copy(data[off:], table) + 16
+		}
+		return data
+	}
+
+	d := detector.Detect(bytes.NewReader(ese("MSysObjects", "datatable", "link_table", "sd_table")))
+	if d == nil || d.Kind != "NTDS.dit" || d.Severity != detector.SeverityCritical || d.Category != detector.CategoryNTDS {
+		t.Fatalf("unexpected detection: %+v", d)
+	}
+	if !strings.Contains(d.Reason(), "dirty shutdown") {
+		t.Errorf("reason %q does not mention the database state", d.Reason())
+	}
+
+	d = detector.Detect(bytes.NewReader(ese("MSysObjects", "SruDbIdMapTable")))
+	if d == nil || d.Category != detector.CategoryESEDatabase || d.Severity != detector.SeverityMedium {
+		t.Fatalf("unexpected detection: %+v", d)
+	}
+
+	if detector.Detect(strings.NewReader("password=hunter2")) != nil {
+		t.Error("plain text detected as a database")
+	}
+}
+
+func TestDetectCredential(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(nil)
+	plain, err := ssh.MarshalPrivateKey(priv, "deploy@build01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "deploy@build01", []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// KDBX 4.1 header: cipher (AES-256), then KDF parameters (Argon2id)
+	kdbx := append([]byte{0x03, 0xd9, 0xa2, 0x9a, 0x67, 0xfb, 0x4b, 0xb5}, 1, 0, 4, 0)
+	field := func(id byte, value []byte) {
+		kdbx = append(kdbx, id)
+		kdbx = binary.LittleEndian.AppendUint32(kdbx, uint32(len(value)))
+		kdbx = append(kdbx, value...)
+	}
+	entry := func(dict []byte, typ byte, key string, value []byte) []byte {
+		dict = append(dict, typ)
+		dict = binary.LittleEndian.AppendUint32(dict, uint32(len(key)))
+		dict = append(dict, key...)
+		dict = binary.LittleEndian.AppendUint32(dict, uint32(len(value)))
+		return append(dict, value...)
+	}
+	aes, _ := hex.DecodeString("31c1f2e6bf714350be5805216afc5aff")
+	argon2id, _ := hex.DecodeString("9e298b1956db4773b23dfc3ec6f0a1e6")
+	dict := entry([]byte{0, 1}, 0x42, "$UUID", argon2id)
+	dict = entry(dict, 0x05, "I", binary.LittleEndian.AppendUint64(nil, 10))
+	dict = entry(dict, 0x05, "M", binary.LittleEndian.AppendUint64(nil, 64<<20))
+	field(2, aes)
+	field(11, append(dict, 0))
+	field(0, nil)
+
+	var rdp []byte
+	for _, c := range utf16.Encode([]rune("screen mode id:i:2\r\nfull address:s:ts01.corp.local\r\nusername:s:CORP\\jdoe\r\npassword 51:b:01000000D08C9DDF0115D1118C7A00C04FC297EB\r\n")) {
+		rdp = binary.LittleEndian.AppendUint16(rdp, c)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		kind     string
+		severity string
+		details  string
+	}{
+		{"id_ed25519", pem.EncodeToMemory(plain), "Private key", detector.SeverityCritical, "ssh-ed25519; no passphrase"},
+		{"id_rsa", pem.EncodeToMemory(encrypted), "Private key", detector.SeverityHigh, "ssh-ed25519; passphrase-protected"},
+		{"web.ppk", []byte("PuTTY-User-Key-File-3: ssh-rsa\nEncryption: aes256-cbc\nComment: admin@web\nPublic-Lines: 2\n"), "PuTTY private key", detector.SeverityHigh, "ssh-rsa; comment admin@web; passphrase-protected"},
+		{"Passwords.kdbx", kdbx, "KeePass database", detector.SeverityHigh, "KDBX 4.1; AES-256; Argon2id, 10 iterations, 64 MiB"},
+		{"ts01.rdp", append([]byte{0xff, 0xfe}, rdp...), "RDP file with saved password", detector.SeverityHigh, "DPAPI blob; full address ts01.corp.local; username CORP\\jdoe"},
+		{"corp.ovpn", []byte("client\nremote vpn.corp.com 1194\n<key>\n" + string(pem.EncodeToMemory(plain)) + "</key>\n"), "OpenVPN config with inline secrets", detector.SeverityCritical, "remote vpn.corp.com; inline key; ssh-ed25519; no passphrase"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head := tt.data[:min(len(tt.data), detector.SniffSize)]
+			if !detector.Sniff(tt.name, head) {
+				t.Fatal("Sniff did not recognise the file")
+			}
+			d := detector.Detect(bytes.NewReader(tt.data))
+			if d == nil {
+				t.Fatal("no detection")
+			}
+			if d.Kind != tt.kind || d.Severity != tt.severity || d.Category != detector.CategoryCredential {
+				t.Errorf("got %s/%s/%s, want %s/%s/%s", d.Kind, d.Severity, d.Category, tt.kind, tt.severity, detector.CategoryCredential)
+			}
+			if got := strings.Join(d.Details, "; "); got != tt.details {
+				t.Errorf("details: got %q, want %q", got, tt.details)
+			}
+		})
+	}
+
+	// Nothing to steal: a certificate, an .rdp without a saved password
+	for name, data := range map[string]string{
+		"server.crt": "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
+		"ts02.rdp":   "full address:s:ts02.corp.local\r\n",
+	} {
+		if d := detector.Detect(strings.NewReader(data)); d != nil {
+			t.Errorf("%s: unexpected detection %+v", name, d)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package detector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ESE (JET Blue) databases: NTDS.dit, but also SRUDB.dat, Windows.edb and
+// friends. NTDS.dit is recognised by its table names in the catalog.
+
+const (
+	eseCatalogPage = 4  // Root page of MSysObjects
+	eseScanPages   = 64 // Catalog pages are allocated right after it
+)
+
+var eseStates = map[uint32]string{
+	1: "just created",
+	2: "dirty shutdown",
+	3: "clean shutdown",
+	4: "being converted",
+	5: "force detach",
+}
+
+// ntdsTables are tables every NTDS.dit has and other ESE databases do not.
+var ntdsTables = [][]byte{[]byte("datatable"), []byte("link_table"), []byte("sd_table")}
+
+func detectESE(r io.ReaderAt) *Detection {
+	d := &Detection{Kind: "ESE database", Category: CategoryESEDatabase, Severity: SeverityMedium}
+
+	hdr := make([]byte, 240)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return d
+	}
+	pageSize := int64(binary.LittleEndian.Uint32(hdr[236:]))
+	if state, ok := eseStates[binary.LittleEndian.Uint32(hdr[52:])]; ok {
+		// A dirty database needs "esentutl /r" or "/p" before it can be read
+		d.Details = append(d.Details, state)
+	}
+	if pageSize == 0 || pageSize&(pageSize-1) != 0 || pageSize > 32*1024 {
+		return d
+	}
+	d.Details = append(d.Details, fmt.Sprintf("page size %d", pageSize))
+
+	// Page n is at (n+1)*pageSize: the header and its shadow come first
+	catalog := make([]byte, eseScanPages*pageSize)
+	n, _ := r.ReadAt(catalog, (eseCatalogPage+1)*pageSize)
+	catalog = catalog[:n]
+	for _, table := range ntdsTables {
+		if !bytes.Contains(catalog, table) {
+			return d
+		}
+	}
+
+	d.Kind = "NTDS.dit"
+	d.Category = CategoryNTDS
+	d.Severity = SeverityCritical
+	return d
+}
@@ -0,0 +1,289 @@
+package detector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Registry hive (regf) parsing: the base block, then key (nk) cells and
+// subkey lists, enough to tell which hive this is and what it holds.
+
+const (
+	hiveBinsOffset = 4096
+	maxCellSize    = 1 << 20
+	maxListedKeys  = 10
+	maxListedUsers = 20
+)
+
+type hive struct {
+	r io.ReaderAt
+}
+
+type hiveKey struct {
+	name       string
+	lastWrite  time.Time
+	numSubkeys uint32
+	subkeys    uint32 // Cell offset of the subkey list
+}
+
+func detectHive(r io.ReaderAt) *Detection {
+	d := &Detection{Kind: "Registry hive", Category: CategoryRegistryHive, Severity: SeverityHigh}
+
+	base := make([]byte, 512)
+	if _, err := r.ReadAt(base, 0); err != nil {
+		return d
+	}
+	if name := hiveFileName(base[48:112]); name != "" {
+		d.Details = append(d.Details, "file "+name)
+	}
+	if t := filetime(binary.LittleEndian.Uint64(base[12:])); !t.IsZero() {
+		d.Details = append(d.Details, "last written "+t.Format(time.RFC3339))
+	}
+
+	h := &hive{r: r}
+	root, err := h.key(binary.LittleEndian.Uint32(base[36:]))
+	if err != nil {
+		return d
+	}
+	names, err := h.subkeyNames(root)
+	if err != nil {
+		return d
+	}
+
+	typ := hiveType(names)
+	if typ == "" {
+		typ = hiveTypeFromFileName(base[48:112])
+	}
+	switch typ {
+	case "SAM", "SYSTEM", "SECURITY":
+		d.Severity = SeverityCritical
+	}
+	if typ != "" {
+		d.Kind = typ + " hive"
+	}
+
+	if typ == "SAM" {
+		// SAM\Domains\Account\Users\Names has one subkey per local account
+		if users, err := h.subkeyNamesAt(root, "SAM", "Domains", "Account", "Users", "Names"); err == nil {
+			d.Details = append(d.Details, fmt.Sprintf("%d local accounts: %s", len(users), listNames(users, maxListedUsers)))
+		}
+	}
+	d.Details = append(d.Details, "keys: "+listNames(names, maxListedKeys))
+	return d
+}
+
+// hiveType recognises a hive by its root keys, which unlike the embedded file
+// name survive "reg save" and renames.
+func hiveType(root []string) string {
+	has := make(map[string]bool, len(root))
+	for _, n := range root {
+		has[strings.ToLower(n)] = true
+	}
+	switch {
+	case has["sam"] && len(root) == 1:
+		return "SAM"
+	case has["policy"] && (has["rxact"] || has["cache"]):
+		return "SECURITY"
+	case has["select"] || has["controlset001"]:
+		return "SYSTEM"
+	case has["microsoft"] && has["classes"]:
+		return "SOFTWARE"
+	case has["software"] && (has["environment"] || has["console"]):
+		return "NTUSER.DAT"
+	}
+	return ""
+}
+
+func hiveTypeFromFileName(raw []byte) string {
+	name := strings.ToUpper(hiveFileName(raw))
+	if i := strings.LastIndexAny(name, `\/`); i >= 0 {
+		name = name[i+1:]
+	}
+	switch name {
+	case "SAM", "SYSTEM", "SECURITY", "SOFTWARE", "NTUSER.DAT":
+		return name
+	}
+	return ""
+}
+
+// hiveFileName decodes the UTF-16 file name in the base block (the last
+// characters of the path the hive was loaded from).
+func hiveFileName(raw []byte) string {
+	return strings.TrimSpace(decodeUTF16(raw))
+}
+
+func (h *hive) cell(off uint32) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := h.r.ReadAt(hdr[:], hiveBinsOffset+int64(off)); err != nil {
+		return nil, err
+	}
+	// Allocated cells have a negative size
+	size := -int32(binary.LittleEndian.Uint32(hdr[:]))
+	if size <= 4 || size > maxCellSize {
+		return nil, fmt.Errorf("bad cell at 0x%x", off)
+	}
+	data := make([]byte, size-4)
+	if _, err := h.r.ReadAt(data, hiveBinsOffset+int64(off)+4); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (h *hive) key(off uint32) (*hiveKey, error) {
+	c, err := h.cell(off)
+	if err != nil {
+		return nil, err
+	}
+	if len(c) < 76 || string(c[:2]) != "nk" {
+		return nil, fmt.Errorf("no key cell at 0x%x", off)
+	}
+	nameLen := int(binary.LittleEndian.Uint16(c[72:]))
+	if 76+nameLen > len(c) {
+		return nil, fmt.Errorf("bad key name at 0x%x", off)
+	}
+	name := c[76 : 76+nameLen]
+
+	k := &hiveKey{
+		lastWrite:  filetime(binary.LittleEndian.Uint64(c[4:])),
+		numSubkeys: binary.LittleEndian.Uint32(c[20:]),
+		subkeys:    binary.LittleEndian.Uint32(c[28:]),
+	}
+	if binary.LittleEndian.Uint16(c[2:])&0x20 != 0 {
+		k.name = string(name) // KEY_COMP_NAME: ASCII
+	} else {
+		k.name = decodeUTF16(name)
+	}
+	return k, nil
+}
+
+// subkeyOffsets follows a subkey list (lf, lh, li, or ri of those).
+func (h *hive) subkeyOffsets(off uint32, depth int) ([]uint32, error) {
+	if depth > 2 {
+		return nil, errors.New("subkey lists nested too deep")
+	}
+	c, err := h.cell(off)
+	if err != nil {
+		return nil, err
+	}
+	if len(c) < 4 {
+		return nil, errors.New("short subkey list")
+	}
+	count := int(binary.LittleEndian.Uint16(c[2:]))
+
+	stride := 4
+	switch string(c[:2]) {
+	case "lf", "lh":
+		stride = 8 // offset + name hash
+	case "li", "ri":
+	default:
+		return nil, fmt.Errorf("unknown subkey list %q", c[:2])
+	}
+	if 4+count*stride > len(c) {
+		return nil, errors.New("truncated subkey list")
+	}
+
+	var offs []uint32
+	for i := 0; i < count; i++ {
+		o := binary.LittleEndian.Uint32(c[4+i*stride:])
+		if string(c[:2]) == "ri" {
+			sub, err := h.subkeyOffsets(o, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			offs = append(offs, sub...)
+		} else {
+			offs = append(offs, o)
+		}
+	}
+	return offs, nil
+}
+
+func (h *hive) subkeys(k *hiveKey) ([]*hiveKey, error) {
+	if k.numSubkeys == 0 {
+		return nil, nil
+	}
+	offs, err := h.subkeyOffsets(k.subkeys, 0)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*hiveKey, 0, len(offs))
+	for _, o := range offs {
+		sk, err := h.key(o)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, sk)
+	}
+	return keys, nil
+}
+
+func (h *hive) subkeyNames(k *hiveKey) ([]string, error) {
+	keys, err := h.subkeys(k)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(keys))
+	for i, sk := range keys {
+		names[i] = sk.name
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// subkeyNamesAt lists the subkeys of the key at path below k.
+func (h *hive) subkeyNamesAt(k *hiveKey, path ...string) ([]string, error) {
+	for _, name := range path {
+		keys, err := h.subkeys(k)
+		if err != nil {
+			return nil, err
+		}
+		k = nil
+		for _, sk := range keys {
+			if strings.EqualFold(sk.name, name) {
+				k = sk
+				break
+			}
+		}
+		if k == nil {
+			return nil, fmt.Errorf("key %s not found", name)
+		}
+	}
+	return h.subkeyNames(k)
+}
+
+func listNames(names []string, max int) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	if len(names) > max {
+		return fmt.Sprintf("%s, ... (%d more)", strings.Join(names[:max], ", "), len(names)-max)
+	}
+	return strings.Join(names, ", ")
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
+
+// filetime converts a Windows FILETIME (100ns ticks since 1601) to UTC.
+func filetime(ft uint64) time.Time {
+	const epochDiff = 116444736000000000 // 1601-01-01 to 1970-01-01 in ticks
+	if ft <= epochDiff {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ft-epochDiff)*100).UTC()
+}
@@ -0,0 +1,78 @@
+package extractor
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+var (
+	jetMagic = []byte("\x00\x01\x00\x00Standard Jet DB")
+	aceMagic = []byte("\x00\x01\x00\x00Standard ACE DB")
+)
+
+// AccessExtractor for Access databases (.mdb, .accdb). The Jet/ACE page
+// format is not parsed: this is a strings reader for both the 8-bit text and
+// the UTF-16 text Jet 4 and later store, so it finds values but not which
+// table or column they belong to.
+type AccessExtractor struct{}
+
+func (e *AccessExtractor) Extract(r io.Reader, filename string) (string, error) {
+	doc, err := e.ExtractDocument(r, filename)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text, nil
+}
+
+func (e *AccessExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Metadata: make(map[string]string)}
+	switch {
+	case bytes.HasPrefix(b, aceMagic):
+		doc.Metadata["format"] = "ACE"
+	case bytes.HasPrefix(b, jetMagic) && len(b) > 0x14 && b[0x14] == 0:
+		doc.Metadata["format"] = "Jet 3"
+	case bytes.HasPrefix(b, jetMagic):
+		doc.Metadata["format"] = "Jet 4"
+	}
+
+	text, err := (&StringsExtractor{MinLength: 4}).Extract(bytes.NewReader(b), filename)
+	if err != nil {
+		return nil, err
+	}
+	doc.Text = text + utf16Strings(b, 4)
+	return doc, nil
+}
+
+// utf16Strings finds runs of at least min printable ASCII characters stored
+// as UTF-16LE, at even and odd offsets.
+func utf16Strings(b []byte, min int) string {
+	var sb strings.Builder
+	for align := 0; align < 2; align++ {
+		start, n := align, 0
+		for i := align; i+1 < len(b); i += 2 {
+			if b[i] >= 32 && b[i] < 127 && b[i+1] == 0 {
+				if n == 0 {
+					start = i
+				}
+				n++
+				continue
+			}
+			if n >= min {
+				sb.WriteString(string(decodeUTF16LE(b[start:i])))
+				sb.WriteByte('\n')
+			}
+			n = 0
+		}
+		if n >= min {
+			sb.WriteString(string(decodeUTF16LE(b[start : start+2*n])))
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,296 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// maxEmailDepth bounds attached messages inside messages.
+const maxEmailDepth = 4
+
+// EmlExtractor for RFC 822 messages (.eml): headers, decoded MIME bodies and
+// the text of attachments, read with the extractor for their type.
+type EmlExtractor struct{}
+
+func (e *EmlExtractor) Extract(r io.Reader, filename string) (string, error) {
+	doc, err := e.ExtractDocument(r, filename)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text, nil
+}
+
+func (e *EmlExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return emailDocument(b, 0)
+}
+
+// MboxExtractor for mbox mailboxes: every message, one after the other.
+type MboxExtractor struct{}
+
+func (e *MboxExtractor) Extract(r io.Reader, filename string) (string, error) {
+	doc, err := e.ExtractDocument(r, filename)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text, nil
+}
+
+func (e *MboxExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
+	var (
+		sb   strings.Builder
+		msgs []*Document
+		cur  bytes.Buffer
+	)
+	flush := func() {
+		if cur.Len() == 0 {
+			return
+		}
+		// The blank line before the next envelope is not part of the message
+		msg := append(bytes.TrimRight(cur.Bytes(), "\r\n"), '\n')
+		if doc, err := emailDocument(msg, 0); err == nil {
+			if sb.Len() > 0 {
+				sb.WriteString("\n\n")
+			}
+			sb.WriteString(doc.Text)
+			msgs = append(msgs, doc)
+		}
+		cur.Reset()
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if bytes.HasPrefix(line, []byte("From ")) {
+			// Envelope line: a new message starts
+			flush()
+		} else if len(line) > 0 {
+			// ">From " is an escaped body line
+			if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+				line = line[1:]
+			}
+			cur.Write(line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	flush()
+
+	doc := &Document{Text: sb.String(), Metadata: map[string]string{"messages": strconv.Itoa(len(msgs))}}
+	if len(msgs) == 1 {
+		doc.Metadata = msgs[0].Metadata
+	}
+	return doc, nil
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// emailDocument reads one message. The headers that say who sent it and what
+// about are kept in the text too, as they often hold the secret's context.
+func emailDocument(b []byte, depth int) (*Document, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Metadata: make(map[string]string)}
+	var sb strings.Builder
+	for _, h := range []string{"Subject", "From", "To", "Cc", "Date"} {
+		v := msg.Header.Get(h)
+		if v == "" {
+			continue
+		}
+		if d, err := wordDecoder.DecodeHeader(v); err == nil {
+			v = d
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", h, v)
+		switch h {
+		case "Subject", "From":
+			doc.Metadata[strings.ToLower(h)] = v
+		case "Date":
+			if t, err := mail.ParseDate(v); err == nil {
+				v = t.UTC().Format(time.RFC3339)
+			}
+			doc.Metadata["date"] = v
+		}
+	}
+	sb.WriteByte('\n')
+
+	var attachments []string
+	emailPart(&sb, &attachments, textproto.MIMEHeader(msg.Header), msg.Body, depth)
+	if len(attachments) > 0 {
+		doc.Metadata["attachments"] = strings.Join(attachments, ", ")
+	}
+	doc.Text = sb.String()
+	return doc, nil
+}
+
+// emailPart writes the text of a MIME entity, recursing into multiparts,
+// attached messages and attachments.
+func emailPart(sb *strings.Builder, attachments *[]string, h textproto.MIMEHeader, body io.Reader, depth int) {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: body})
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	body = io.LimitReader(body, MaxInputSize)
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			// Raw parts: the transfer encoding is handled here
+			p, err := mr.NextRawPart()
+			if err != nil {
+				return
+			}
+			emailPart(sb, attachments, p.Header, p, depth)
+		}
+	}
+
+	_, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	name := dparams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if name != "" {
+		if d, err := wordDecoder.DecodeHeader(name); err == nil {
+			name = d
+		}
+	}
+
+	if mediaType == "message/rfc822" {
+		if depth >= maxEmailDepth {
+			return
+		}
+		data, _ := io.ReadAll(body)
+		if doc, err := emailDocument(data, depth+1); err == nil {
+			sb.WriteString("\n[attached message]\n")
+			sb.WriteString(doc.Text)
+		}
+		return
+	}
+
+	if name != "" {
+		*attachments = append(*attachments, name)
+		data, _ := io.ReadAll(body)
+		writeAttachment(sb, name, data, depth)
+		return
+	}
+
+	switch mediaType {
+	case "text/plain", "text/html":
+		if cs := params["charset"]; cs != "" {
+			if cr, err := charset.NewReaderLabel(cs, body); err == nil {
+				body = cr
+			}
+		}
+		data, _ := io.ReadAll(body)
+		if mediaType == "text/html" {
+			sb.WriteString(htmlText(data))
+		} else {
+			sb.Write(data)
+		}
+		sb.WriteByte('\n')
+	}
+}
+
+// writeAttachment writes the text of an attached file, read with the
+// extractor its name and signature call for.
+func writeAttachment(sb *strings.Builder, name string, data []byte, depth int) {
+	fmt.Fprintf(sb, "\n[attachment: %s]\n", name)
+	if depth >= maxEmailDepth {
+		return
+	}
+
+	var doc *Document
+	var err error
+	switch e := GetExtractorFor(name, data[:min(len(data), SniffSize)]); e.(type) {
+	case *EmlExtractor:
+		doc, err = emailDocument(data, depth+1)
+	case *MsgExtractor:
+		doc, err = msgDocument(data, depth+1)
+	default:
+		doc, err = ExtractDocument(e, bytes.NewReader(data), name)
+	}
+	if err == nil {
+		sb.WriteString(doc.Text)
+		sb.WriteByte('\n')
+	}
+}
+
+// base64Cleaner drops what the decoder chokes on in the wild (spaces, stray
+// characters at line ends).
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	j := 0
+	for _, b := range p[:n] {
+		if b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '+' || b == '/' || b == '=' {
+			p[j] = b
+			j++
+		}
+	}
+	return j, err
+}
+
+// htmlText is the text of an HTML body, without scripts and styles.
+func htmlText(b []byte) string {
+	var sb strings.Builder
+	z := html.NewTokenizer(bytes.NewReader(b))
+	skip := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return sb.String()
+		case html.TextToken:
+			if skip == 0 {
+				sb.Write(z.Text())
+			}
+		case html.StartTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style":
+				if tt == html.StartTagToken {
+					skip++
+				} else if skip > 0 {
+					skip--
+				}
+			case "br", "p", "div", "tr", "li":
+				sb.WriteByte('\n')
+			case "td", "th":
+				sb.WriteByte('\t')
+			}
+		}
+	}
+}
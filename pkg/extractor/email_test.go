@@ -0,0 +1,122 @@
+package extractor_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/0xSterny/spuderman/pkg/extractor"
+)
+
+func TestEmailExtractors(t *testing.T) {
+	pptx := zipFile(t, map[string]string{
+		"ppt/presentation.xml":  `<p:presentation/>`,
+		"ppt/slides/slide1.xml": `<p:sld><a:p><a:r><a:t>sa / Winter2024!</a:t></a:r></a:p></p:sld>`,
+	})
+	eml := strings.ReplaceAll(`From: "IT Support" <it@corp.local>
+To: jdoe@corp.local
+Subject: =?UTF-8?Q?Zugangsdaten_f=C3=BCr_den_Server?=
+Date: Tue, 14 May 2024 09:30:00 +0200
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain; charset=iso-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+Passw=F6rt f=FCr db01: Sommer2024=21
+--inner
+Content-Type: text/html; charset=utf-8
+
+<html><style>p{}</style><p>ignored alternative</p></html>
+--inner--
+
+--outer
+Content-Type: application/octet-stream; name="creds.pptx"
+Content-Disposition: attachment; filename="creds.pptx"
+Content-Transfer-Encoding: base64
+
+`+base64.StdEncoding.EncodeToString(pptx)+`
+--outer--
+`, "\n", "\r\n")
+
+	doc, err := extractor.ExtractDocument(extractor.GetExtractor("mail.eml"), strings.NewReader(eml), "mail.eml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Subject: Zugangsdaten für den Server\n",
+		"Passwört für db01: Sommer2024!",
+		"ignored alternative",
+		"[attachment: creds.pptx]\nsa / Winter2024!",
+	} {
+		if !strings.Contains(doc.Text, want) {
+			t.Errorf("eml text %q does not contain %q", doc.Text, want)
+		}
+	}
+	if strings.Contains(doc.Text, "p{}") {
+		t.Errorf("eml text %q contains the style sheet", doc.Text)
+	}
+	wantMeta := map[string]string{
+		"subject":     "Zugangsdaten für den Server",
+		"from":        `"IT Support" <it@corp.local>`,
+		"date":        "2024-05-14T07:30:00Z",
+		"attachments": "creds.pptx",
+	}
+	for k, v := range wantMeta {
+		if doc.Metadata[k] != v {
+			t.Errorf("eml metadata[%s] = %q, want %q", k, doc.Metadata[k], v)
+		}
+	}
+
+	mbox := "From it@corp.local Tue May 14 09:30:00 2024\nSubject: one\n\nfirst body\n>From the archive\n\n" +
+		"From jdoe@corp.local Wed May 15 10:00:00 2024\nSubject: two\n\nsecond body\n"
+	e := extractor.GetExtractorFor("Inbox", head([]byte(mbox)))
+	doc, err = extractor.ExtractDocument(e, strings.NewReader(mbox), "Inbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Subject: one\n\nfirst body\nFrom the archive\n\n\n\nSubject: two\n\nsecond body\n\n"; doc.Text != want {
+		t.Errorf("mbox text = %q, want %q", doc.Text, want)
+	}
+	if doc.Metadata["messages"] != "2" {
+		t.Errorf("mbox metadata = %v", doc.Metadata)
+	}
+}
+
+func TestMsgExtractor(t *testing.T) {
+	utf16le := func(s string) []byte {
+		return u16(utf16.Encode([]rune(s))...)
+	}
+	// Top-level property stream: 32 byte header, then a PtypTime submit time
+	props := append(make([]byte, 32), u16(0x0040, 0x0039)...)
+	props = append(props, u32(0)...)
+	props = append(props, u32(0x87254c00, 0x01daa5d0)...) // 2024-05-14T07:30:00Z
+
+	msg := cfbFile(
+		cfbStream{"__substg1.0_0037001F", utf16le("VPN access")},
+		cfbStream{"__substg1.0_0C1A001F", utf16le("IT Support")},
+		cfbStream{"__substg1.0_5D01001F", utf16le("it@corp.local")},
+		cfbStream{"__substg1.0_1000001F", utf16le("Your password is Welcome1!")},
+		cfbStream{"__properties_version1.0", props},
+	)
+
+	for _, name := range []string{"vpn.msg", "vpn.bak"} {
+		e := extractor.GetExtractorFor(name, head(msg))
+		doc, err := extractor.ExtractDocument(e, bytes.NewReader(msg), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "Subject: VPN access\nFrom: IT Support <it@corp.local>\nDate: 2024-05-14T07:30:00Z\n\nYour password is Welcome1!\n"; doc.Text != want {
+			t.Errorf("%s: text = %q, want %q", name, doc.Text, want)
+		}
+		if doc.Metadata["subject"] != "VPN access" || doc.Metadata["from"] != "IT Support <it@corp.local>" {
+			t.Errorf("%s: metadata = %v", name, doc.Metadata)
+		}
+	}
+}
@@ -1,37 +1,239 @@
 package extractor
 
 import (
+	"bytes"
+	"errors"
 	"io"
+	"net/http"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 )
 
+// MaxInputSize is how much of a file is read for extraction, to prevent OOM.
+const MaxInputSize = 10 * 1024 * 1024
+
+// maxUncompressedSize caps what is inflated from a ZIP-based document
+// (zip bombs), across all its parts.
+const maxUncompressedSize = 5 * MaxInputSize
+
+// SniffSize is how much of the start of a file GetExtractorFor looks at (all
+// http.DetectContentType considers).
+const SniffSize = 512
+
 type Extractor interface {
 	Extract(r io.Reader, filename string) (string, error)
 }
 
-// GetExtractor returns the appropriate extractor for the filename
+// Document is the text of a file and, for formats that have them, its
+// properties (author, last saved by...).
+type Document struct {
+	Text     string
+	Metadata map[string]string
+}
+
+// DocumentExtractor is implemented by extractors that also read properties.
+type DocumentExtractor interface {
+	ExtractDocument(r io.Reader, filename string) (*Document, error)
+}
+
+// ErrNoExtractor is returned by ExtractDocument for files GetExtractorFor
+// found nothing to extract from, and by extractors for files that turn out
+// not to be their format (a plain ZIP archive).
+var ErrNoExtractor = errors.New("no text to extract")
+
+// Reasons an extraction failed, for review of the files that could not be
+// read.
+const (
+	ReasonEncrypted = "encrypted"
+	ReasonMalformed = "malformed"
+	ReasonPanic     = "panic"
+	ReasonTimeout   = "timeout"
+)
+
+// ExtractError is an extraction failure with its reason code. Extractors
+// that still got part of the text return it instead, with the reason in the
+// "extract_error" metadata.
+type ExtractError struct {
+	Reason string
+	Err    error
+}
+
+func (e *ExtractError) Error() string {
+	return e.Reason + ": " + e.Err.Error()
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+// ExtractDocument runs e, with properties when e reads them.
+func ExtractDocument(e Extractor, r io.Reader, filename string) (*Document, error) {
+	if e == nil {
+		return nil, ErrNoExtractor
+	}
+	if de, ok := e.(DocumentExtractor); ok {
+		return de.ExtractDocument(r, filename)
+	}
+	text, err := e.Extract(r, filename)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Text: text}, nil
+}
+
+// Format is a file type an extractor handles, recognised by extension,
+// signature or sniffed MIME type.
+type Format struct {
+	Extensions []string // Lower case, with the dot: ".docx"
+	Magic      [][]byte // Leading bytes
+	// Detect recognises signatures that are more than leading bytes. Optional.
+	Detect func(filename string, head []byte) bool
+	// MIMETypes as sniffed by http.DetectContentType, without parameters
+	// ("text/html"), for files no extension or signature claims.
+	MIMETypes []string
+	// Text formats are only read as such when the content is not binary (a
+	// PNG renamed .txt is not text).
+	Text bool
+	New  func() Extractor
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   []*Format
+)
+
+// Register adds a format. Formats registered later take precedence, so
+// library users can override the built-in extractors. A format whose
+// extension and signature both match wins over a signature-only one (a .docx
+// over any ZIP file).
+func Register(f Format) {
+	for i, ext := range f.Extensions {
+		f.Extensions[i] = strings.ToLower(ext)
+	}
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats = append(formats, &f)
+}
+
+// lookup returns the most recently registered format accepted by match.
+func lookup(match func(*Format) bool) *Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for i := len(formats) - 1; i >= 0; i-- {
+		if match(formats[i]) {
+			return formats[i]
+		}
+	}
+	return nil
+}
+
+func (f *Format) sniff(filename string, head []byte) bool {
+	for _, m := range f.Magic {
+		if bytes.HasPrefix(head, m) {
+			return true
+		}
+	}
+	return f.Detect != nil && f.Detect(filename, head)
+}
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	pdfMagic  = []byte("%PDF-")
+	rtfMagic  = []byte(`{\rtf`)
+	oleMagic  = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}
+	mboxMagic = []byte("From ")
+)
+
+func init() {
+	Register(Format{Extensions: []string{".txt", ".md", ".ini", ".cfg", ".config", ".ps1", ".sh", ".json", ".xml", ".yaml", ".yml"},
+		MIMETypes: []string{"text/plain", "text/xml"}, Text: true, New: func() Extractor { return &TextExtractor{} }})
+	Register(Format{Extensions: []string{".html", ".htm"}, MIMETypes: []string{"text/html"}, Text: true,
+		New: func() Extractor { return &HtmlExtractor{} }})
+	Register(Format{Extensions: []string{".pdf"}, Magic: [][]byte{pdfMagic}, New: func() Extractor { return &PdfExtractor{} }})
+	Register(Format{Extensions: []string{".rtf"}, Magic: [][]byte{rtfMagic}, New: func() Extractor { return &RtfExtractor{} }})
+
+	// ZIP-based. When the extension does not say which document it is, the
+	// parts inside do: OfficeExtractor comes last so it takes those files.
+	Register(Format{Extensions: []string{".docx"}, Magic: [][]byte{zipMagic}, New: func() Extractor { return &DocxExtractor{} }})
+	Register(Format{Extensions: []string{".xlsx"}, Magic: [][]byte{zipMagic}, New: func() Extractor { return &XlsxExtractor{} }})
+	Register(Format{Extensions: []string{".pptx"}, Magic: [][]byte{zipMagic}, New: func() Extractor { return &PptxExtractor{} }})
+	Register(Format{Extensions: []string{".odt", ".ods", ".odp"}, Magic: [][]byte{zipMagic}, New: func() Extractor { return &OdfExtractor{} }})
+	Register(Format{Magic: [][]byte{zipMagic}, New: func() Extractor { return &OfficeExtractor{} }})
+
+	// OLE: likewise, OleExtractor recognises renamed messages too
+	Register(Format{Extensions: []string{".msg"}, Magic: [][]byte{oleMagic}, New: func() Extractor { return &MsgExtractor{} }})
+	Register(Format{Extensions: []string{".doc", ".dot", ".xls", ".xlt", ".ppt", ".pps", ".pot"}, Magic: [][]byte{oleMagic},
+		New: func() Extractor { return &OleExtractor{} }})
+
+	Register(Format{Extensions: []string{".sqlite", ".sqlite3", ".db3"}, Magic: [][]byte{sqliteMagic},
+		New: func() Extractor { return &SqliteExtractor{} }})
+	Register(Format{Extensions: []string{".mdb", ".accdb", ".mde", ".accde"}, Magic: [][]byte{jetMagic, aceMagic},
+		New: func() Extractor { return &AccessExtractor{} }})
+
+	Register(Format{Extensions: []string{".eml"}, New: func() Extractor { return &EmlExtractor{} }})
+	Register(Format{Extensions: []string{".mbox", ".mbx"}, New: func() Extractor { return &MboxExtractor{} },
+		Detect: func(filename string, head []byte) bool {
+			// Mail clients keep mailboxes in files without an extension ("Inbox")
+			return bytes.HasPrefix(head, mboxMagic) && filepath.Ext(filename) == ""
+		}})
+}
+
+// skippedTypes are binary types with no text worth extracting.
+var skippedTypes = []string{"image/", "audio/", "video/", "font/", "application/x-gzip", "application/x-rar-compressed",
+	"application/wasm", "application/ogg", "application/vnd.ms-fontobject", "application/zip"}
+
+// GetExtractor returns the extractor registered for the file extension. Without
+// the content to sniff, unknown extensions are read as text; GetExtractorFor
+// is the better choice when the start of the file is at hand.
 func GetExtractor(filename string) Extractor {
 	ext := strings.ToLower(filepath.Ext(filename))
+	if f := lookup(func(f *Format) bool { return slices.Contains(f.Extensions, ext) }); f != nil {
+		return f.New()
+	}
+	return &TextExtractor{}
+}
 
-	switch ext {
-	case ".docx":
-		return &DocxExtractor{}
-	case ".xlsx":
-		return &XlsxExtractor{}
-	case ".pdf":
-		return &PdfExtractor{}
-	case ".doc", ".xls":
-		return &StringsExtractor{MinLength: 4}
-	case ".txt", ".md", ".ini", ".cfg", ".config", ".ps1", ".sh", ".json", ".xml", ".yaml", ".yml":
-		return &TextExtractor{}
-	default:
-		// Default to TextExtractor for unknown, or Strings?
-		// "Other related non-binary files" implies text.
-		// Safe bet: Strings Extractor for unknown might be cleaner than dumping binary garbage.
-		// But if User says "read specific file types... non-binary", they imply text-like.
-		// Let's us TextExtractor for now, assuming user filters mostly text.
-		// Actually, let's use Strings for fallback to avoid cluttering matched content with binary noise.
+// GetExtractorFor picks the extractor for a file from head (its first
+// SniffSize bytes) and name: signatures first, so renamed documents are still
+// read, then the extension, then the sniffed MIME type. Unknown binary
+// content gets the StringsExtractor, or nil when it is a type with no text
+// (images, archives, media).
+func GetExtractorFor(filename string, head []byte) Extractor {
+	ext := strings.ToLower(filepath.Ext(filename))
+	byName := lookup(func(f *Format) bool { return slices.Contains(f.Extensions, ext) })
+
+	if byName != nil && byName.sniff(filename, head) {
+		return byName.New()
+	}
+	if f := lookup(func(f *Format) bool { return f.sniff(filename, head) }); f != nil {
+		return f.New()
+	}
+	if byName != nil && !byName.Text {
+		return byName.New()
+	}
+
+	mediaType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	byType := lookup(func(f *Format) bool { return slices.Contains(f.MIMETypes, mediaType) })
+	if strings.HasPrefix(mediaType, "text/") {
+		switch {
+		case byName != nil:
+			return byName.New()
+		case byType != nil:
+			return byType.New()
+		}
 		return &TextExtractor{}
 	}
+
+	// Binary
+	if byType != nil {
+		return byType.New()
+	}
+	for _, t := range skippedTypes {
+		if strings.HasPrefix(mediaType, t) {
+			return nil
+		}
+	}
+	return &StringsExtractor{MinLength: 4}
 }
@@ -0,0 +1,192 @@
+package extractor_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/0xSterny/spuderman/pkg/extractor"
+	"github.com/xuri/excelize/v2"
+)
+
+// head is what callers pass to GetExtractorFor: the start of the file.
+func head(b []byte) []byte {
+	return b[:min(len(b), extractor.SniffSize)]
+}
+
+func zipFile(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractors(t *testing.T) {
+	pptx := zipFile(t, map[string]string{
+		"ppt/presentation.xml":              `<p:presentation/>`,
+		"ppt/slides/slide2.xml":             `<p:sld><a:p><a:r><a:t>Second slide</a:t></a:r></a:p></p:sld>`,
+		"ppt/slides/slide10.xml":            `<p:sld><a:p><a:r><a:t>Tenth</a:t></a:r></a:p></p:sld>`,
+		"ppt/slides/slide1.xml":             `<p:sld><a:p><a:r><a:t>VPN password: </a:t></a:r><a:r><a:t>Winter2024!</a:t></a:r></a:p></p:sld>`,
+		"ppt/notesSlides/notesSlide1.xml":   `<p:notes><a:p><a:r><a:t>Do not share</a:t></a:r></a:p></p:notes>`,
+		"ppt/slideLayouts/slideLayout1.xml": `<p:sldLayout><a:t>Click to edit</a:t></p:sldLayout>`,
+	})
+	odt := zipFile(t, map[string]string{
+		"mimetype":    "application/vnd.oasis.opendocument.text",
+		"content.xml": `<office:document-content><office:body><office:text><text:h>Accounts</text:h><text:p>admin<text:tab/>P@ssw0rd</text:p></office:text></office:body></office:document-content>`,
+	})
+	ods := zipFile(t, map[string]string{
+		"mimetype":    "application/vnd.oasis.opendocument.spreadsheet",
+		"content.xml": `<office:document-content><office:body><table:table><table:table-row><table:table-cell><text:p>user</text:p></table:table-cell><table:table-cell><text:p>pass</text:p></table:table-cell></table:table-row></table:table></office:body></office:document-content>`,
+	})
+	rtf := `{\rtf1\ansi\ansicpg1252{\fonttbl{\f0 Arial;}}{\*\generator Riched20;}{\info{\author jdoe}}` +
+		`\pard\f0 Server:\tab db01\par Passw\'f6rd: Sommer\u8364?2024\par {\pict\pngblip 89504e47}\{done\}}`
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"deck.pptx", pptx, "VPN password: Winter2024!\n\nSecond slide\n\nTenth\n\nDo not share\n\n"},
+		{"notes.odt", odt, "Accounts\nadmin\tP@ssw0rd\n"},
+		{"creds.ods", ods, "user\n\tpass\n\t\n"},
+		{"memo.rtf", []byte(rtf), "Server:\tdb01\nPasswörd: Sommer€2024\n{done}"},
+		// Renamed copies are picked by signature
+		{"deck.bak", pptx, "VPN password: Winter2024!\n\nSecond slide\n\nTenth\n\nDo not share\n\n"},
+		{"notes.old", odt, "Accounts\nadmin\tP@ssw0rd\n"},
+		{"memo.txt", []byte(rtf), "Server:\tdb01\nPasswörd: Sommer€2024\n{done}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := extractor.GetExtractorFor(tt.name, head(tt.data))
+			got, err := e.Extract(bytes.NewReader(tt.data), tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOfficeHiddenContent(t *testing.T) {
+	docx := zipFile(t, map[string]string{
+		"word/document.xml": `<w:document><w:body><w:p><w:r><w:t>Body</w:t></w:r><w:del><w:r><w:delText>pw: Autumn2023</w:delText></w:r></w:del></w:p>` +
+			`<w:p><w:r><w:instrText> INCLUDEPICTURE "\\fs01\img\logo.png" </w:instrText></w:r></w:p></w:body></w:document>`,
+		"word/header1.xml":    `<w:hdr><w:p><w:r><w:t>CONFIDENTIAL</w:t></w:r></w:p></w:hdr>`,
+		"word/footer1.xml":    `<w:ftr><w:p><w:r><w:t>Page</w:t></w:r></w:p></w:ftr>`,
+		"word/comments.xml":   `<w:comments><w:comment w:author="jdoe"><w:p><w:r><w:t>use svc_sql / Sql2024!</w:t></w:r></w:p></w:comment></w:comments>`,
+		"docProps/core.xml":   `<cp:coreProperties><dc:creator>jdoe</dc:creator><cp:lastModifiedBy>CORP\admin</cp:lastModifiedBy></cp:coreProperties>`,
+		"docProps/app.xml":    `<Properties><Template>Normal.dotm</Template><Company>Corp Ltd</Company></Properties>`,
+		"docProps/custom.xml": `<Properties><property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="Owner"><vt:lpwstr>IT</vt:lpwstr></property></Properties>`,
+		"word/_rels/settings.xml.rels": `<Relationships><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/attachedTemplate" ` +
+			`Target="file:///\\fs01\templates\corp.dotm" TargetMode="External"/></Relationships>`,
+	})
+
+	doc, err := extractor.ExtractDocument(&extractor.DocxExtractor{}, bytes.NewReader(docx), "memo.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Bodypw: Autumn2023\n INCLUDEPICTURE \"\\\\fs01\\img\\logo.png\" \nCONFIDENTIAL\nPage\nuse svc_sql / Sql2024!\n\nfile:///\\\\fs01\\templates\\corp.dotm\n"; doc.Text != want {
+		t.Errorf("docx text = %q, want %q", doc.Text, want)
+	}
+	wantMeta := map[string]string{
+		"author":        "jdoe",
+		"last_saved_by": `CORP\admin`,
+		"company":       "Corp Ltd",
+		"template":      `file:///\\fs01\templates\corp.dotm`,
+		"unc_paths":     `\\fs01\img\logo.png, file:///\\fs01\templates\corp.dotm`,
+		"custom:Owner":  "IT",
+	}
+	if !reflect.DeepEqual(doc.Metadata, wantMeta) {
+		t.Errorf("docx metadata = %v, want %v", doc.Metadata, wantMeta)
+	}
+
+	f := excelize.NewFile()
+	f.SetCellValue("Sheet1", "A1", "host")
+	f.NewSheet("Secrets")
+	f.SetCellValue("Secrets", "A1", "root")
+	f.SetSheetVisible("Secrets", false)
+	f.AddComment("Sheet1", excelize.Comment{Cell: "A1", Author: "jdoe", Text: "ssh key on fs01"})
+	f.SetDefinedName(&excelize.DefinedName{Name: "DbPassword", RefersTo: `"Pa55word"`})
+	f.SetDocProps(&excelize.DocProperties{Creator: "jdoe"})
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err = extractor.ExtractDocument(&extractor.XlsxExtractor{}, bytes.NewReader(buf.Bytes()), "hosts.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "host \nroot \nComment Sheet1!A1 (jdoe): ssh key on fs01\nName DbPassword: \"Pa55word\"\n"; doc.Text != want {
+		t.Errorf("xlsx text = %q, want %q", doc.Text, want)
+	}
+	if doc.Metadata["hidden_sheets"] != "Secrets" || doc.Metadata["author"] != "jdoe" {
+		t.Errorf("xlsx metadata = %v", doc.Metadata)
+	}
+}
+
+type upperExtractor struct{}
+
+func (upperExtractor) Extract(r io.Reader, filename string) (string, error) {
+	b, err := io.ReadAll(r)
+	return strings.ToUpper(string(b)), err
+}
+
+func TestGetExtractorFor(t *testing.T) {
+	extractor.Register(extractor.Format{
+		Extensions: []string{".Vault"},
+		Magic:      [][]byte{[]byte("VLT1")},
+		New:        func() extractor.Extractor { return upperExtractor{} },
+	})
+
+	png := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), make([]byte, 32)...)
+	exe := append([]byte("MZ\x90\x00\x03\x00\x00\x00"), []byte("\x00\x00password=hunter2\x00")...)
+
+	tests := []struct {
+		name string
+		data string
+		want extractor.Extractor // nil: nothing to extract
+	}{
+		{"notes.txt", "user: admin", &extractor.TextExtractor{}},
+		{"README", "plain text without extension", &extractor.TextExtractor{}},
+		{"page", "<!DOCTYPE html><html><body>hi</body></html>", &extractor.HtmlExtractor{}},
+		{"logo.png", string(png), nil},
+		{"logo.txt", string(png), nil}, // Renamed binary is not text
+		{"tool.exe", string(exe), &extractor.StringsExtractor{MinLength: 4}},
+		{"dump.bin", string(exe), &extractor.StringsExtractor{MinLength: 4}},
+		{"report.pdf", "%PDF-1.7", &extractor.PdfExtractor{}},
+		{"secrets.vault", "VLT1 data", upperExtractor{}},
+		{"secrets.bak", "VLT1 data", upperExtractor{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractor.GetExtractorFor(tt.name, head([]byte(tt.data)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %T %+v, want %T %+v", got, got, tt.want, tt.want)
+			}
+		})
+	}
+
+	if _, err := extractor.ExtractDocument(nil, strings.NewReader(string(png)), "logo.png"); err != extractor.ErrNoExtractor {
+		t.Errorf("ExtractDocument(nil) error = %v, want ErrNoExtractor", err)
+	}
+	archive := zipFile(t, map[string]string{"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\n"})
+	if _, err := extractor.ExtractDocument(extractor.GetExtractorFor("app.jar", head(archive)), bytes.NewReader(archive), "app.jar"); err != extractor.ErrNoExtractor {
+		t.Errorf("ExtractDocument(app.jar) error = %v, want ErrNoExtractor", err)
+	}
+}
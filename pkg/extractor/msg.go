@@ -0,0 +1,184 @@
+package extractor
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// MsgExtractor for Outlook .msg files (MS-OXMSG): an OLE compound file with
+// one stream per message property, and a storage per attachment.
+type MsgExtractor struct{}
+
+func (e *MsgExtractor) Extract(r io.Reader, filename string) (string, error) {
+	doc, err := e.ExtractDocument(r, filename)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text, nil
+}
+
+func (e *MsgExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return msgDocument(b, 0)
+}
+
+const (
+	msgSubstg     = "__substg1.0_"
+	msgProperties = "__properties_version1.0"
+	msgAttach     = "__attach_version1.0_#"
+	msgEmbedded   = "__substg1.0_3701000D/" // Attached message storage
+)
+
+func msgDocument(b []byte, depth int) (*Document, error) {
+	cfb, err := mscfb.New(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	// Streams by path, e.g. "__attach_version1.0_#00000000/__substg1.0_37010102"
+	streams := make(map[string][]byte)
+	for f, err := cfb.Next(); err == nil; f, err = cfb.Next() {
+		if !strings.HasPrefix(f.Name, msgSubstg) && f.Name != msgProperties {
+			continue
+		}
+		key := strings.Join(append(slices.Clone(f.Path), f.Name), "/")
+		streams[key], _ = io.ReadAll(io.LimitReader(f, MaxInputSize))
+	}
+	if len(streams) == 0 {
+		return nil, errors.New("no message properties")
+	}
+	return msgMessage(streams, "", depth), nil
+}
+
+// msgMessage reads the message whose streams are under prefix ("" for the
+// top-level one), the same way emailDocument does for RFC 822.
+func msgMessage(streams map[string][]byte, prefix string, depth int) *Document {
+	doc := &Document{Metadata: make(map[string]string)}
+	prop := func(id string) string { return msgString(streams, prefix, id) }
+
+	from := prop("0C1A") // Sender name
+	if addr := cmp.Or(prop("5D01"), prop("0C1F")); addr != "" && addr != from {
+		from = strings.TrimSpace(from + " <" + addr + ">")
+	}
+	var date string
+	if t, ok := msgTime(streams[prefix+msgProperties], prefix == "", 0x0039); ok { // Submit time
+		date = t.UTC().Format(time.RFC3339)
+	}
+
+	var sb strings.Builder
+	for _, h := range []struct{ name, value string }{
+		{"Subject", prop("0037")},
+		{"From", from},
+		{"To", prop("0E04")},
+		{"Cc", prop("0E03")},
+		{"Date", date},
+	} {
+		if h.value == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", h.name, h.value)
+		if h.name == "Subject" || h.name == "From" || h.name == "Date" {
+			doc.Metadata[strings.ToLower(h.name)] = h.value
+		}
+	}
+	sb.WriteByte('\n')
+
+	if body := prop("1000"); body != "" {
+		sb.WriteString(body)
+	} else if h := streams[prefix+msgSubstg+"10130102"]; h != nil {
+		sb.WriteString(htmlText(h))
+	}
+	sb.WriteByte('\n')
+
+	var attachments []string
+	for _, att := range msgAttachments(streams, prefix) {
+		apre := prefix + att + "/"
+		if hasStreamUnder(streams, apre+msgEmbedded) {
+			if depth < maxEmailDepth {
+				sb.WriteString("\n[attached message]\n")
+				sb.WriteString(msgMessage(streams, apre+msgEmbedded, depth+1).Text)
+			}
+			continue
+		}
+		name := cmp.Or(msgString(streams, apre, "3707"), msgString(streams, apre, "3704"), att)
+		attachments = append(attachments, name)
+		writeAttachment(&sb, name, streams[apre+msgSubstg+"37010102"], depth)
+	}
+	if len(attachments) > 0 {
+		doc.Metadata["attachments"] = strings.Join(attachments, ", ")
+	}
+	doc.Text = sb.String()
+	return doc
+}
+
+// msgString is a string property, stored as UTF-16 (001F) or 8-bit (001E).
+func msgString(streams map[string][]byte, prefix, id string) string {
+	if b, ok := streams[prefix+msgSubstg+id+"001F"]; ok {
+		return strings.TrimRight(string(decodeUTF16LE(b)), "\x00")
+	}
+	if b, ok := streams[prefix+msgSubstg+id+"001E"]; ok {
+		return strings.TrimRight(latin1(b), "\x00")
+	}
+	return ""
+}
+
+// msgTime finds a PtypTime property in a property stream. Fixed-size values
+// live there, after a header that is longer for the top-level message.
+func msgTime(props []byte, top bool, id uint16) (time.Time, bool) {
+	off := 24
+	if top {
+		off = 32
+	}
+	for ; off+16 <= len(props); off += 16 {
+		typ := binary.LittleEndian.Uint16(props[off:])
+		if typ == 0x0040 && binary.LittleEndian.Uint16(props[off+2:]) == id {
+			ft := binary.LittleEndian.Uint64(props[off+8:])
+			if ft == 0 {
+				return time.Time{}, false
+			}
+			// 100ns intervals since 1601
+			return time.Unix(0, 0).Add(time.Duration(ft-116444736000000000) * 100), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// msgAttachments lists the attachment storages directly under prefix.
+func msgAttachments(streams map[string][]byte, prefix string) []string {
+	seen := make(map[string]bool)
+	var atts []string
+	for key := range streams {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || !strings.HasPrefix(rest, msgAttach) {
+			continue
+		}
+		att, _, _ := strings.Cut(rest, "/")
+		if !seen[att] {
+			seen[att] = true
+			atts = append(atts, att)
+		}
+	}
+	slices.Sort(atts)
+	return atts
+}
+
+func hasStreamUnder(streams map[string][]byte, prefix string) bool {
+	for key := range streams {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
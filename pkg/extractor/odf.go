@@ -0,0 +1,46 @@
+package extractor
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+// OdfExtractor for OpenDocument files (.odt, .ods, .odp)
+type OdfExtractor struct{}
+
+func (e *OdfExtractor) Extract(r io.Reader, filename string) (string, error) {
+	zr, err := openZip(r)
+	if err != nil {
+		return "", err
+	}
+	return extractODF(zr)
+}
+
+// odfSeparators are written after ODF elements to keep words and cells apart.
+var odfSeparators = map[string]string{
+	"p":          "\n", // text:p
+	"h":          "\n", // text:h
+	"line-break": "\n",
+	"tab":        "\t",
+	"s":          " ",
+	"table-cell": "\t",
+	"table-row":  "\n",
+}
+
+func extractODF(zr *zip.Reader) (string, error) {
+	budget := int64(maxUncompressedSize)
+	for _, f := range zr.File {
+		// Text, sheets and slides all live in content.xml
+		if f.Name != "content.xml" {
+			continue
+		}
+		data, err := readZipPart(f, &budget)
+		if err != nil {
+			return "", err
+		}
+		return xmlText(data, nil, func(n xml.Name) string { return odfSeparators[n.Local] }), nil
+	}
+	return "", errors.New("no content.xml in OpenDocument file")
+}
@@ -1,54 +1,119 @@
 package extractor
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
-	"github.com/nguyenthenguyen/docx"
 	"github.com/xuri/excelize/v2"
 )
 
-// DocxExtractor for .docx files
+// DocxExtractor for .docx files: the body, then headers, footers, notes and
+// comments. Deleted text of tracked changes and field codes are kept too.
 type DocxExtractor struct{}
 
 func (e *DocxExtractor) Extract(r io.Reader, filename string) (string, error) {
-	// docx library needs a ReadAt/Seeker usually?
-	// github.com/nguyenthenguyen/docx ReadDocxFromMemory takes *bytes.Reader or io.ReaderAt.
-	// We have io.Reader from SMB. We need to buffer it.
-
-	b, err := io.ReadAll(r)
+	doc, err := e.ExtractDocument(r, filename)
 	if err != nil {
 		return "", err
 	}
-	rdr := strings.NewReader(string(b)) // Convert to ReaderAt compliant
+	return doc.Text, nil
+}
 
-	d, err := docx.ReadDocxFromMemory(rdr, rdr.Size())
+func (e *DocxExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
+	zr, err := openZip(r)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return extractDocx(zr)
+}
+
+var docxParts = []*regexp.Regexp{
+	regexp.MustCompile(`^word/document()\.xml$`),
+	regexp.MustCompile(`^word/header(\d*)\.xml$`),
+	regexp.MustCompile(`^word/footer(\d*)\.xml$`),
+	regexp.MustCompile(`^word/footnotes()\.xml$`),
+	regexp.MustCompile(`^word/endnotes()\.xml$`),
+	regexp.MustCompile(`^word/comments()\.xml$`),
+}
+
+func extractDocx(zr *zip.Reader) (*Document, error) {
+	var sb strings.Builder
+	budget := int64(maxUncompressedSize)
+	found := false
+
+	for _, re := range docxParts {
+		for _, f := range numberedParts(zr, re) {
+			found = true
+			data, err := readZipPart(f, &budget)
+			if err != nil {
+				return nil, err
+			}
+			// WordprocessingML: runs <w:t>, deletions <w:delText>, fields <w:instrText>
+			sb.WriteString(xmlText(data, func(n xml.Name) bool {
+				return n.Local == "t" || n.Local == "delText" || n.Local == "instrText"
+			}, func(n xml.Name) string {
+				switch n.Local {
+				case "p", "br", "cr":
+					return "\n"
+				case "tc":
+					return "\t"
+				}
+				return ""
+			}))
+		}
+	}
+	if !found {
+		return nil, errors.New("not a Word document")
 	}
-	defer d.Close()
 
-	return d.Editable().GetContent(), nil
+	return ooxmlDocument(zr, &budget, sb.String()), nil
 }
 
-// XlsxExtractor for .xlsx files
+// XlsxExtractor for .xlsx files: cells of every sheet (hidden ones too), then
+// cell comments, defined names and external link paths.
 type XlsxExtractor struct{}
 
 func (e *XlsxExtractor) Extract(r io.Reader, filename string) (string, error) {
-	// excelize.OpenReader works with io.Reader directly?
-	// "OpenReader read the spreadsheet from an io.Reader."
-	// checking docs: func OpenReader(r io.Reader, opts ...Options) (*File, error)
-
-	f, err := excelize.OpenReader(r)
+	doc, err := e.ExtractDocument(r, filename)
 	if err != nil {
-		// Sometimes it might fail if seek is needed, but let's try.
-		// If fails, we might need buffering.
 		return "", err
 	}
+	return doc.Text, nil
+}
+
+func (e *XlsxExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := excelize.OpenReader(bytes.NewReader(b), excelize.Options{
+		UnzipSizeLimit:    maxUncompressedSize,
+		UnzipXMLSizeLimit: maxUncompressedSize,
+	})
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close()
 
 	var sb strings.Builder
+	var hidden []string
 	for _, sheet := range f.GetSheetList() {
+		if visible, err := f.GetSheetVisible(sheet); err == nil && !visible {
+			hidden = append(hidden, sheet)
+		}
 		rows, err := f.GetRows(sheet)
 		if err != nil {
 			continue
@@ -61,5 +126,201 @@ func (e *XlsxExtractor) Extract(r io.Reader, filename string) (string, error) {
 			sb.WriteString("\n")
 		}
 	}
+
+	for _, sheet := range f.GetSheetList() {
+		comments, _ := f.GetComments(sheet)
+		for _, c := range comments {
+			text := c.Text
+			for _, run := range c.Paragraph {
+				text += run.Text
+			}
+			fmt.Fprintf(&sb, "Comment %s!%s (%s): %s\n", sheet, c.Cell, c.Author, text)
+		}
+	}
+	for _, dn := range f.GetDefinedName() {
+		fmt.Fprintf(&sb, "Name %s: %s\n", dn.Name, dn.RefersTo)
+	}
+
+	budget := int64(maxUncompressedSize)
+	doc := ooxmlDocument(zr, &budget, sb.String())
+	if len(hidden) > 0 {
+		doc.Metadata["hidden_sheets"] = strings.Join(hidden, ", ")
+	}
+	return doc, nil
+}
+
+// PptxExtractor for .pptx files: slide text, then speaker notes
+type PptxExtractor struct{}
+
+func (e *PptxExtractor) Extract(r io.Reader, filename string) (string, error) {
+	zr, err := openZip(r)
+	if err != nil {
+		return "", err
+	}
+	return extractPptx(zr)
+}
+
+func (e *PptxExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
+	zr, err := openZip(r)
+	if err != nil {
+		return nil, err
+	}
+	text, err := extractPptx(zr)
+	if err != nil {
+		return nil, err
+	}
+	budget := int64(maxUncompressedSize)
+	return ooxmlDocument(zr, &budget, text), nil
+}
+
+var (
+	pptxSlide = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+	pptxNotes = regexp.MustCompile(`^ppt/notesSlides/notesSlide(\d+)\.xml$`)
+)
+
+func extractPptx(zr *zip.Reader) (string, error) {
+	var sb strings.Builder
+	budget := int64(maxUncompressedSize)
+
+	for _, re := range []*regexp.Regexp{pptxSlide, pptxNotes} {
+		for _, f := range numberedParts(zr, re) {
+			data, err := readZipPart(f, &budget)
+			if err != nil {
+				return sb.String(), err
+			}
+			// DrawingML: text runs are <a:t>, paragraphs <a:p>
+			sb.WriteString(xmlText(data, func(n xml.Name) bool { return n.Local == "t" }, func(n xml.Name) string {
+				if n.Local == "p" || n.Local == "br" {
+					return "\n"
+				}
+				return ""
+			}))
+			sb.WriteString("\n")
+		}
+	}
 	return sb.String(), nil
 }
+
+// numberedParts returns the members matching re (with the number as first
+// group), in numeric order.
+func numberedParts(zr *zip.Reader, re *regexp.Regexp) []*zip.File {
+	type part struct {
+		n int
+		f *zip.File
+	}
+	var parts []part
+	for _, f := range zr.File {
+		if m := re.FindStringSubmatch(f.Name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			parts = append(parts, part{n, f})
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].n < parts[j].n })
+
+	files := make([]*zip.File, len(parts))
+	for i, p := range parts {
+		files[i] = p.f
+	}
+	return files
+}
+
+// OfficeExtractor reads a ZIP-based document whose extension says nothing
+// (renamed, or a backup copy): the parts inside tell which format it is.
+type OfficeExtractor struct{}
+
+func (e *OfficeExtractor) Extract(r io.Reader, filename string) (string, error) {
+	doc, err := e.ExtractDocument(r, filename)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text, nil
+}
+
+func (e *OfficeExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			return extractDocx(zr)
+		case "xl/workbook.xml":
+			return (&XlsxExtractor{}).ExtractDocument(bytes.NewReader(b), filename)
+		case "ppt/presentation.xml":
+			return (&PptxExtractor{}).ExtractDocument(bytes.NewReader(b), filename)
+		case "mimetype":
+			text, err := extractODF(zr)
+			if err != nil {
+				return nil, err
+			}
+			return &Document{Text: text}, nil
+		}
+	}
+	// A plain archive: nothing to extract, not a failure
+	return nil, ErrNoExtractor
+}
+
+// openZip buffers r and opens it as a ZIP archive.
+func openZip(r io.Reader) (*zip.Reader, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(b), int64(len(b)))
+}
+
+// readZipPart inflates a member, charging it to budget (the uncompressed
+// bytes left for the whole document).
+func readZipPart(f *zip.File, budget *int64) ([]byte, error) {
+	if *budget <= 0 {
+		return nil, errors.New("uncompressed size limit reached")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, *budget))
+	*budget -= int64(len(data))
+	return data, err
+}
+
+// xmlText returns the character data of an XML part. If keep is set, only
+// text inside elements it accepts is kept. sep gives what to write after an
+// element closes (line breaks after paragraphs, tabs after cells...).
+func xmlText(data []byte, keep func(xml.Name) bool, sep func(xml.Name) string) string {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+
+	var sb strings.Builder
+	depth := 0 // Nesting level of kept elements
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if keep != nil && keep(t.Name) {
+				depth++
+			}
+		case xml.EndElement:
+			if keep != nil && keep(t.Name) {
+				depth--
+			}
+			sb.WriteString(sep(t.Name))
+		case xml.CharData:
+			if keep == nil || depth > 0 {
+				sb.Write(t)
+			}
+		}
+	}
+	return sb.String()
+}
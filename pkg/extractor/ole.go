@@ -0,0 +1,572 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/msoleps"
+	"github.com/richardlehane/msoleps/types"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// OleExtractor for legacy Office files (.doc, .xls, .ppt): OLE compound
+// files holding a Word, BIFF8 or PowerPoint binary stream, plus the summary
+// property sets (author, last saved by...).
+type OleExtractor struct{}
+
+func (e *OleExtractor) Extract(r io.Reader, filename string) (string, error) {
+	doc, err := e.ExtractDocument(r, filename)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text, nil
+}
+
+// oleProperties maps summary property names to metadata keys.
+var oleProperties = map[string]string{
+	"Title":        "title",
+	"Subject":      "subject",
+	"Author":       "author",
+	"LastAuthor":   "last_saved_by",
+	"Keywords":     "keywords",
+	"Comments":     "comments",
+	"Company":      "company",
+	"Manager":      "manager",
+	"CreateTime":   "created",
+	"LastSaveTime": "modified",
+}
+
+func (e *OleExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfb, err := mscfb.New(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Metadata: make(map[string]string)}
+	streams := make(map[string][]byte)
+	isMsg := false
+	for f, err := cfb.Next(); err == nil; f, err = cfb.Next() {
+		// Embedded objects live in sub-storages; only read the document itself
+		if len(f.Path) > 0 {
+			continue
+		}
+		if msoleps.IsMSOLEPS(f.Initial) {
+			props := msoleps.New()
+			if err := props.Reset(f); err != nil {
+				continue
+			}
+			for _, p := range props.Property {
+				if key, ok := oleProperties[p.Name]; ok {
+					if v := olePropertyValue(p); v != "" {
+						doc.Metadata[key] = v
+					}
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(f.Name, msgSubstg) {
+			isMsg = true
+		}
+		switch f.Name {
+		case "WordDocument", "0Table", "1Table", "Workbook", "Book", "PowerPoint Document":
+			streams[f.Name], _ = io.ReadAll(io.LimitReader(f, maxUncompressedSize))
+		}
+	}
+
+	var encrypted bool
+	switch {
+	case streams["WordDocument"] != nil:
+		doc.Text, encrypted, err = wordText(streams["WordDocument"], streams["0Table"], streams["1Table"])
+	case streams["Workbook"] != nil:
+		doc.Text, encrypted = biffText(streams["Workbook"])
+	case streams["Book"] != nil:
+		doc.Text, encrypted = biffText(streams["Book"]) // Excel 5/95
+	case streams["PowerPoint Document"] != nil:
+		doc.Text = pptText(streams["PowerPoint Document"])
+	case isMsg:
+		// Outlook message saved under another name
+		return msgDocument(b, 0)
+	default:
+		// Some other OLE file: no worse than before
+		doc.Text, err = (&StringsExtractor{MinLength: 4}).Extract(bytes.NewReader(b), filename)
+	}
+	if encrypted {
+		doc.Metadata["encrypted"] = "true"
+	}
+	return doc, err
+}
+
+func olePropertyValue(p *msoleps.Property) string {
+	if ft, ok := p.T.(types.FileTime); ok {
+		t := ft.Time()
+		if t.Unix() <= 0 {
+			return "" // Unset
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return strings.TrimSpace(p.String())
+}
+
+// Word 97-2003 (MS-DOC). The text is a list of pieces, each either 8-bit
+// (cp1252) or UTF-16, described by the piece table in the table stream.
+
+func wordText(wd, table0, table1 []byte) (text string, encrypted bool, err error) {
+	if len(wd) < 34 || binary.LittleEndian.Uint16(wd) != 0xa5ec {
+		return "", false, errors.New("bad Word FIB")
+	}
+	flags := binary.LittleEndian.Uint16(wd[0x0a:])
+	if flags&0x0100 != 0 {
+		return "", true, nil
+	}
+	table := table0
+	if flags&0x0200 != 0 {
+		table = table1
+	}
+
+	// FIB: base, then variable-size arrays; fcClx/lcbClx is pair 33 of the last
+	off := 32
+	off += 2 + 2*int(binary.LittleEndian.Uint16(wd[off:]))
+	if off+2 > len(wd) {
+		return "", false, errors.New("truncated Word FIB")
+	}
+	off += 2 + 4*int(binary.LittleEndian.Uint16(wd[off:]))
+	if off+2 > len(wd) {
+		return "", false, errors.New("truncated Word FIB")
+	}
+	pairs := int(binary.LittleEndian.Uint16(wd[off:]))
+	blob := off + 2
+	if pairs <= 33 || blob+34*8 > len(wd) {
+		return wordTextNoPieces(wd), false, nil
+	}
+	fcClx := int(binary.LittleEndian.Uint32(wd[blob+33*8:]))
+	lcbClx := int(binary.LittleEndian.Uint32(wd[blob+33*8+4:]))
+	if lcbClx == 0 || fcClx+lcbClx > len(table) {
+		return wordTextNoPieces(wd), false, nil
+	}
+
+	plc, err := wordPieceTable(table[fcClx : fcClx+lcbClx])
+	if err != nil {
+		return "", false, err
+	}
+	n := (len(plc) - 4) / 12
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		cpStart := int(binary.LittleEndian.Uint32(plc[4*i:]))
+		cpEnd := int(binary.LittleEndian.Uint32(plc[4*(i+1):]))
+		pcd := plc[4*(n+1)+8*i:]
+		fc := binary.LittleEndian.Uint32(pcd[2:])
+		count := cpEnd - cpStart
+		if count <= 0 {
+			continue
+		}
+
+		if fc&0x40000000 != 0 {
+			// Compressed: 8-bit characters at fc/2
+			start := int(fc&0x3fffffff) / 2
+			if start+count > len(wd) {
+				continue
+			}
+			for _, c := range wd[start : start+count] {
+				writeWordRune(&sb, charmap.Windows1252.DecodeByte(c))
+			}
+		} else {
+			start := int(fc & 0x3fffffff)
+			if start+2*count > len(wd) {
+				continue
+			}
+			for _, r := range decodeUTF16LE(wd[start : start+2*count]) {
+				writeWordRune(&sb, r)
+			}
+		}
+	}
+	return sb.String(), false, nil
+}
+
+// wordPieceTable finds the PlcPcd in the Clx: Prc entries (0x01) come first,
+// then the Pcdt (0x02).
+func wordPieceTable(clx []byte) ([]byte, error) {
+	for pos := 0; pos < len(clx); {
+		switch clx[pos] {
+		case 0x01:
+			if pos+3 > len(clx) {
+				return nil, errors.New("truncated Word Clx")
+			}
+			pos += 3 + int(int16(binary.LittleEndian.Uint16(clx[pos+1:])))
+		case 0x02:
+			if pos+5 > len(clx) {
+				return nil, errors.New("truncated Word Clx")
+			}
+			lcb := int(binary.LittleEndian.Uint32(clx[pos+1:]))
+			if lcb < 16 || pos+5+lcb > len(clx) {
+				return nil, errors.New("bad Word piece table")
+			}
+			return clx[pos+5 : pos+5+lcb], nil
+		default:
+			return nil, errors.New("bad Word Clx")
+		}
+	}
+	return nil, errors.New("no Word piece table")
+}
+
+// wordTextNoPieces reads files without a piece table (Word 6/95 style):
+// 8-bit text between fcMin and fcMac.
+func wordTextNoPieces(wd []byte) string {
+	fcMin := int(binary.LittleEndian.Uint32(wd[0x18:]))
+	fcMac := int(binary.LittleEndian.Uint32(wd[0x1c:]))
+	if fcMin >= fcMac || fcMac > len(wd) {
+		return ""
+	}
+	var sb strings.Builder
+	for _, c := range wd[fcMin:fcMac] {
+		writeWordRune(&sb, charmap.Windows1252.DecodeByte(c))
+	}
+	return sb.String()
+}
+
+// writeWordRune maps Word's special characters: paragraph and cell marks,
+// breaks, field delimiters (dropped, the field code is kept) and anchors.
+func writeWordRune(sb *strings.Builder, r rune) {
+	switch r {
+	case '\r', 0x0b, 0x0c:
+		sb.WriteByte('\n')
+	case 0x07:
+		sb.WriteByte('\t')
+	case 0x1e:
+		sb.WriteByte('-')
+	case '\t', '\n':
+		sb.WriteRune(r)
+	default:
+		if r >= 0x20 {
+			sb.WriteRune(r)
+		}
+	}
+}
+
+func decodeUTF16LE(b []byte) []rune {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return utf16.Decode(u)
+}
+
+// Excel 97-2003 (BIFF8). Cells come as records, with strings in a shared
+// string table (SST) that may be split over CONTINUE records.
+
+const (
+	biffFormula    = 0x0006
+	biffEOF        = 0x000a
+	biffFilePass   = 0x002f
+	biffContinue   = 0x003c
+	biffBoundSheet = 0x0085
+	biffMulRK      = 0x00bd
+	biffSST        = 0x00fc
+	biffLabelSST   = 0x00fd
+	biffNumber     = 0x0203
+	biffLabel      = 0x0204
+	biffString     = 0x0207
+	biffRK         = 0x027e
+	biffBOF        = 0x0809
+)
+
+type biffRecord struct {
+	typ  uint16
+	pos  int // Offset of the record in the stream
+	data []byte
+}
+
+func biffRecords(s []byte) []biffRecord {
+	var recs []biffRecord
+	for pos := 0; pos+4 <= len(s); {
+		typ := binary.LittleEndian.Uint16(s[pos:])
+		size := int(binary.LittleEndian.Uint16(s[pos+2:]))
+		if pos+4+size > len(s) {
+			break
+		}
+		recs = append(recs, biffRecord{typ: typ, pos: pos, data: s[pos+4 : pos+4+size]})
+		pos += 4 + size
+	}
+	return recs
+}
+
+func biffText(s []byte) (text string, encrypted bool) {
+	recs := biffRecords(s)
+	sheets := make(map[int]string) // BOF offset -> sheet name
+	var sst []string
+	var sb strings.Builder
+	row := -1
+
+	cell := func(data []byte, value string) {
+		if len(data) < 4 {
+			return
+		}
+		r := int(binary.LittleEndian.Uint16(data))
+		if r != row {
+			if row >= 0 {
+				sb.WriteByte('\n')
+			}
+			row = r
+		} else {
+			sb.WriteByte('\t')
+		}
+		sb.WriteString(value)
+	}
+
+	for i, rec := range recs {
+		d := rec.data
+		switch rec.typ {
+		case biffFilePass:
+			return sb.String(), true
+		case biffBoundSheet:
+			if len(d) >= 8 {
+				sheets[int(binary.LittleEndian.Uint32(d))] = biffShortString(d[6:])
+			}
+		case biffBOF:
+			if name, ok := sheets[rec.pos]; ok {
+				if sb.Len() > 0 {
+					sb.WriteString("\n\n")
+				}
+				sb.WriteString("[" + name + "]\n")
+				row = -1
+			}
+		case biffSST:
+			chunks := [][]byte{d}
+			for _, c := range recs[i+1:] {
+				if c.typ != biffContinue {
+					break
+				}
+				chunks = append(chunks, c.data)
+			}
+			sst = biffSSTStrings(chunks)
+		case biffLabelSST:
+			if len(d) >= 10 {
+				if idx := int(binary.LittleEndian.Uint32(d[6:])); idx < len(sst) {
+					cell(d, sst[idx])
+				}
+			}
+		case biffLabel:
+			if len(d) > 6 {
+				r := &biffChunkReader{chunks: [][]byte{d[6:]}}
+				if str, ok := r.unicodeString(false); ok {
+					cell(d, str)
+				}
+			}
+		case biffNumber:
+			if len(d) >= 14 {
+				cell(d, formatNumber(math.Float64frombits(binary.LittleEndian.Uint64(d[6:]))))
+			}
+		case biffRK:
+			if len(d) >= 10 {
+				cell(d, formatNumber(rkValue(binary.LittleEndian.Uint32(d[6:]))))
+			}
+		case biffMulRK:
+			for off := 4; off+6 <= len(d)-2; off += 6 {
+				cell(d, formatNumber(rkValue(binary.LittleEndian.Uint32(d[off+2:]))))
+			}
+		case biffFormula:
+			// Cached result; 0xFFFF in the top bytes means not a number
+			if len(d) >= 14 && binary.LittleEndian.Uint16(d[12:]) != 0xffff {
+				cell(d, formatNumber(math.Float64frombits(binary.LittleEndian.Uint64(d[6:]))))
+			}
+		case biffString:
+			// String result of the previous FORMULA, same row
+			r := &biffChunkReader{chunks: [][]byte{d}}
+			if str, ok := r.unicodeString(false); ok {
+				sb.WriteByte('\t')
+				sb.WriteString(str)
+			}
+		case biffEOF:
+			row = -1
+		}
+	}
+	return sb.String(), false
+}
+
+func biffSSTStrings(chunks [][]byte) []string {
+	r := &biffChunkReader{chunks: chunks}
+	hdr, ok := r.read(8)
+	if !ok {
+		return nil
+	}
+	unique := int(binary.LittleEndian.Uint32(hdr[4:]))
+	strs := make([]string, 0, min(unique, 1<<16))
+	for i := 0; i < unique; i++ {
+		s, ok := r.unicodeString(true)
+		if !ok {
+			break
+		}
+		strs = append(strs, s)
+	}
+	return strs
+}
+
+// biffShortString reads a ShortXLUnicodeString (8-bit length).
+func biffShortString(d []byte) string {
+	if len(d) < 2 {
+		return ""
+	}
+	n, high := int(d[0]), d[1]&1 != 0
+	d = d[2:]
+	if high {
+		return string(decodeUTF16LE(d[:min(2*n, len(d)&^1)]))
+	}
+	return latin1(d[:min(n, len(d))])
+}
+
+// biffChunkReader reads across a record and its CONTINUE records.
+type biffChunkReader struct {
+	chunks [][]byte
+	c, off int
+}
+
+func (r *biffChunkReader) read(n int) ([]byte, bool) {
+	var out []byte
+	for n > 0 {
+		if r.c >= len(r.chunks) {
+			return nil, false
+		}
+		chunk := r.chunks[r.c]
+		if r.off >= len(chunk) {
+			r.c, r.off = r.c+1, 0
+			continue
+		}
+		k := min(n, len(chunk)-r.off)
+		out = append(out, chunk[r.off:r.off+k]...)
+		r.off += k
+		n -= k
+	}
+	return out, true
+}
+
+// unicodeString reads an XLUnicodeString, or with rich set an
+// XLUnicodeRichExtendedString (as in the SST). Where the characters are
+// split by a CONTINUE, the new record starts with a fresh flags byte.
+func (r *biffChunkReader) unicodeString(rich bool) (string, bool) {
+	hdr, ok := r.read(3)
+	if !ok {
+		return "", false
+	}
+	cch, flags := int(binary.LittleEndian.Uint16(hdr)), hdr[2]
+	skip := 0
+	if rich && flags&0x08 != 0 {
+		b, ok := r.read(2)
+		if !ok {
+			return "", false
+		}
+		skip += 4 * int(binary.LittleEndian.Uint16(b)) // Formatting runs
+	}
+	if rich && flags&0x04 != 0 {
+		b, ok := r.read(4)
+		if !ok {
+			return "", false
+		}
+		skip += int(binary.LittleEndian.Uint32(b)) // Phonetic data
+	}
+
+	high := flags&1 != 0
+	u := make([]uint16, 0, cch)
+	for len(u) < cch {
+		if r.c >= len(r.chunks) {
+			return "", false
+		}
+		chunk := r.chunks[r.c]
+		if r.off >= len(chunk) {
+			r.c, r.off = r.c+1, 0
+			if r.c >= len(r.chunks) || len(r.chunks[r.c]) == 0 {
+				return "", false
+			}
+			high = r.chunks[r.c][0]&1 != 0
+			r.off = 1
+			continue
+		}
+		if high {
+			for ; len(u) < cch && r.off+1 < len(chunk); r.off += 2 {
+				u = append(u, binary.LittleEndian.Uint16(chunk[r.off:]))
+			}
+			if r.off+1 == len(chunk) {
+				return "", false // Odd byte left: corrupt
+			}
+		} else {
+			for ; len(u) < cch && r.off < len(chunk); r.off++ {
+				u = append(u, uint16(chunk[r.off]))
+			}
+		}
+	}
+	if _, ok := r.read(skip); !ok && skip > 0 {
+		return "", false
+	}
+	return string(utf16.Decode(u)), true
+}
+
+// rkValue decodes an RK number: a truncated double or a 30-bit integer,
+// optionally divided by 100.
+func rkValue(rk uint32) float64 {
+	var v float64
+	if rk&0x02 != 0 {
+		v = float64(int32(rk) >> 2)
+	} else {
+		v = math.Float64frombits(uint64(rk&0xfffffffc) << 32)
+	}
+	if rk&0x01 != 0 {
+		v /= 100
+	}
+	return v
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// PowerPoint 97-2003: text lives in TextCharsAtom (UTF-16) and TextBytesAtom
+// (8-bit) records, nested in containers.
+
+func pptText(s []byte) string {
+	var sb strings.Builder
+	for pos := 0; pos+8 <= len(s); {
+		verInst := binary.LittleEndian.Uint16(s[pos:])
+		typ := binary.LittleEndian.Uint16(s[pos+2:])
+		size := int(binary.LittleEndian.Uint32(s[pos+4:]))
+		pos += 8
+		if verInst&0x0f == 0x0f {
+			continue // Container: its records follow
+		}
+		if size < 0 || pos+size > len(s) {
+			break
+		}
+		body := s[pos : pos+size]
+		pos += size
+
+		var text string
+		switch typ {
+		case 0x0fa0: // TextCharsAtom
+			text = string(decodeUTF16LE(body))
+		case 0x0fa8: // TextBytesAtom
+			text = latin1(body)
+		default:
+			continue
+		}
+		sb.WriteString(strings.NewReplacer("\r", "\n", "\v", "\n").Replace(text))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -0,0 +1,211 @@
+package extractor_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/0xSterny/spuderman/pkg/extractor"
+)
+
+type cfbStream struct {
+	name string
+	data []byte
+}
+
+// cfbFile builds a version 3 compound file: a FAT sector, the directory
+// sectors and the streams, each padded past the mini stream cutoff.
+func cfbFile(streams ...cfbStream) []byte {
+	const (
+		sector     = 512
+		endOfChain = 0xfffffffe
+		noStream   = 0xffffffff
+	)
+	le := binary.LittleEndian
+
+	head := make([]byte, sector)
+	copy(head, []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1})
+	le.PutUint16(head[24:], 0x3e)
+	le.PutUint16(head[26:], 3)
+	le.PutUint16(head[28:], 0xfffe)
+	le.PutUint16(head[30:], 9)
+	le.PutUint16(head[32:], 6)
+	le.PutUint32(head[44:], 1)    // FAT sectors
+	le.PutUint32(head[48:], 1)    // First directory sector
+	le.PutUint32(head[56:], 4096) // Mini stream cutoff
+	le.PutUint32(head[60:], endOfChain)
+	le.PutUint32(head[68:], endOfChain)
+	for i := 76; i < sector; i += 4 {
+		le.PutUint32(head[i:], noStream)
+	}
+	le.PutUint32(head[76:], 0) // The FAT is sector 0
+
+	fat := make([]byte, sector)
+	for i := 0; i < sector; i += 4 {
+		le.PutUint32(fat[i:], noStream)
+	}
+	le.PutUint32(fat[0:], 0xfffffffd)
+	dirSectors := uint32(len(streams)/4 + 1) // 4 entries each, with the root
+	for i := uint32(1); i <= dirSectors; i++ {
+		le.PutUint32(fat[i*4:], i+1)
+	}
+	le.PutUint32(fat[dirSectors*4:], endOfChain)
+
+	dir := make([]byte, dirSectors*sector)
+	entry := func(i int, name string, typ byte, start, size uint32) {
+		e := dir[i*128 : (i+1)*128]
+		u := utf16.Encode([]rune(name))
+		for j, c := range u {
+			le.PutUint16(e[j*2:], c)
+		}
+		le.PutUint16(e[64:], uint16(len(u)+1)*2)
+		e[66], e[67] = typ, 1
+		le.PutUint32(e[68:], noStream)
+		le.PutUint32(e[72:], noStream)
+		le.PutUint32(e[76:], noStream)
+		le.PutUint32(e[116:], start)
+		le.PutUint32(e[120:], size)
+	}
+	entry(0, "Root Entry", 5, endOfChain, 0)
+	le.PutUint32(dir[76:], 1) // Root child
+
+	var data []byte
+	next := 1 + dirSectors
+	for i, s := range streams {
+		b := append([]byte(nil), s.data...)
+		for len(b) < 4096 || len(b)%sector != 0 {
+			b = append(b, 0)
+		}
+		entry(i+1, s.name, 2, next, uint32(len(b)))
+		if i+1 < len(streams) {
+			le.PutUint32(dir[(i+1)*128+72:], uint32(i+2)) // Right sibling
+		}
+		n := uint32(len(b) / sector)
+		for j := uint32(0); j < n; j++ {
+			v := next + j + 1
+			if j == n-1 {
+				v = endOfChain
+			}
+			le.PutUint32(fat[(next+j)*4:], v)
+		}
+		next += n
+		data = append(data, b...)
+	}
+
+	out := append(head, fat...)
+	out = append(out, dir...)
+	return append(out, data...)
+}
+
+func record(typ uint16, data ...[]byte) []byte {
+	body := bytes.Join(data, nil)
+	b := binary.LittleEndian.AppendUint16(nil, typ)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(body)))
+	return append(b, body...)
+}
+
+func u16(v ...uint16) []byte {
+	var b []byte
+	for _, x := range v {
+		b = binary.LittleEndian.AppendUint16(b, x)
+	}
+	return b
+}
+
+func u32(v ...uint32) []byte {
+	var b []byte
+	for _, x := range v {
+		b = binary.LittleEndian.AppendUint32(b, x)
+	}
+	return b
+}
+
+// summaryInformation builds a property set with the author properties.
+func summaryInformation(author, lastAuthor string) []byte {
+	fmtid := []byte{0xe0, 0x85, 0x9f, 0xf2, 0xf9, 0x4f, 0x68, 0x10, 0xab, 0x91, 0x08, 0x00, 0x2b, 0x27, 0xb3, 0xd9}
+	lpstr := func(s string) []byte {
+		b := append([]byte(s), 0)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return append(u32(0x1e, uint32(len(s)+1)), b...)
+	}
+	props := [][]byte{u16(2, 0, 1252, 0), lpstr(author), lpstr(lastAuthor)} // VT_I2 codepage
+	ids := []uint32{1, 4, 8}
+
+	off := 8 + 8*len(props)
+	var index, values []byte
+	for i, p := range props {
+		index = append(index, u32(ids[i], uint32(off+len(values)))...)
+		values = append(values, p...)
+	}
+	section := append(u32(uint32(off+len(values)), uint32(len(props))), index...)
+	section = append(section, values...)
+
+	b := append(u16(0xfffe, 0), u32(0x00020006)...)
+	b = append(b, make([]byte, 16)...) // CLSID
+	b = append(b, u32(1)...)
+	b = append(b, fmtid...)
+	b = append(b, u32(48)...)
+	return append(b, section...)
+}
+
+func TestOleExtractor(t *testing.T) {
+	summary := cfbStream{"\x05SummaryInformation", summaryInformation("jdoe", "admin.backup")}
+
+	// Workbook: globals with the sheet list and shared strings, then the sheet
+	bof := record(0x0809, u16(0x0600, 0x0005), make([]byte, 12))
+	sst := append(u32(2, 2), append(u16(5), 0)...)
+	sst = append(sst, "sa_db"...)
+	sst = append(sst, u16(8)...)
+	sst = append(sst, 1) // UTF-16
+	sst = append(sst, u16('P', 0xe4, 's', 's', 'w', '0', 'r', 'd')...)
+	globalsAt := func(sheetAt uint32) []byte {
+		b := bytes.Clone(bof)
+		b = append(b, record(0x0085, u32(sheetAt), u16(0), []byte{5, 0}, []byte("Creds"))...)
+		b = append(b, record(0x00fc, sst)...)
+		return append(b, record(0x000a)...)
+	}
+	globals := globalsAt(uint32(len(globalsAt(0))))
+	sheet := bytes.Clone(bof)
+	sheet = append(sheet, record(0x00fd, u16(0, 0, 0), u32(0))...)
+	sheet = append(sheet, record(0x00fd, u16(0, 1, 0), u32(1))...)
+	sheet = append(sheet, record(0x027e, u16(1, 0, 0), u32(1433<<2|2))...) // RK integer
+	sheet = append(sheet, record(0x000a)...)
+	xls := cfbFile(summary, cfbStream{"Workbook", append(globals, sheet...)})
+
+	atom := func(typ uint16, body []byte) []byte {
+		return append(append(u16(0, typ), u32(uint32(len(body)))...), body...)
+	}
+	slide := atom(0x0fa0, u16('V', 'P', 'N', ':', '\r', 'v', 'p', 'n', '0', '1'))
+	slide = append(slide, atom(0x0fa8, []byte("Key: Caf\xe9"))...)
+	container := append(u16(0x000f, 0x03ee), u32(uint32(len(slide)))...)
+	ppt := cfbFile(summary, cfbStream{"PowerPoint Document", append(container, slide...)})
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"accounts.xls", xls, "[Creds]\nsa_db\tPässw0rd\n1433"},
+		{"deck.ppt", ppt, "VPN:\nvpn01\nKey: Café\n"},
+		// Renamed copies are picked by signature
+		{"accounts.bak", xls, "[Creds]\nsa_db\tPässw0rd\n1433"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := extractor.GetExtractorFor(tt.name, head(tt.data))
+			doc, err := extractor.ExtractDocument(e, bytes.NewReader(tt.data), tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Text != tt.want {
+				t.Errorf("text = %q, want %q", doc.Text, tt.want)
+			}
+			if doc.Metadata["author"] != "jdoe" || doc.Metadata["last_saved_by"] != "admin.backup" {
+				t.Errorf("metadata = %v", doc.Metadata)
+			}
+		})
+	}
+}
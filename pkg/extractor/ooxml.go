@@ -0,0 +1,177 @@
+package extractor
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Office Open XML packages (.docx, .xlsx, .pptx) keep their properties in
+// docProps/ and the links to other files in the _rels/ parts.
+
+// ooxmlCoreProperties maps docProps/core.xml and app.xml elements to the
+// metadata keys OleExtractor uses for the same properties.
+var ooxmlCoreProperties = map[string]string{
+	"title":          "title",
+	"subject":        "subject",
+	"creator":        "author",
+	"lastModifiedBy": "last_saved_by",
+	"keywords":       "keywords",
+	"description":    "comments",
+	"created":        "created",
+	"modified":       "modified",
+	"Company":        "company",
+	"Manager":        "manager",
+	"Template":       "template",
+}
+
+type ooxmlRelationships struct {
+	Rels []struct {
+		Type   string `xml:"Type,attr"`
+		Target string `xml:"Target,attr"`
+		Mode   string `xml:"TargetMode,attr"`
+	} `xml:"Relationship"`
+}
+
+type ooxmlCustomProperties struct {
+	Props []struct {
+		Name   string `xml:"name,attr"`
+		Values []struct {
+			Text string `xml:",chardata"`
+		} `xml:",any"`
+	} `xml:"property"`
+}
+
+// uncPath finds share paths written in the document itself (field codes
+// such as INCLUDEPICTURE, hyperlinks typed as text).
+var uncPath = regexp.MustCompile(`\\\\[\w.$-]+\\[^\s"<>|*?]+`)
+
+// ooxmlDocument completes the text extracted from a package with its
+// properties and the external targets of its relationships: attached
+// template, linked workbooks, UNC paths. The targets are added to the text.
+func ooxmlDocument(zr *zip.Reader, budget *int64, text string) *Document {
+	meta := make(map[string]string)
+	var template string
+	var links, uncs, external []string
+
+	for _, f := range zr.File {
+		switch {
+		case f.Name == "docProps/core.xml", f.Name == "docProps/app.xml":
+			data, err := readZipPart(f, budget)
+			if err != nil {
+				continue
+			}
+			for name, v := range xmlFields(data) {
+				if key, ok := ooxmlCoreProperties[name]; ok && v != "" {
+					meta[key] = v
+				}
+			}
+		case f.Name == "docProps/custom.xml":
+			data, err := readZipPart(f, budget)
+			if err != nil {
+				continue
+			}
+			var custom ooxmlCustomProperties
+			if xml.Unmarshal(data, &custom) != nil {
+				continue
+			}
+			for _, p := range custom.Props {
+				if len(p.Values) > 0 && p.Name != "" {
+					meta["custom:"+p.Name] = strings.TrimSpace(p.Values[0].Text)
+				}
+			}
+		case path.Ext(f.Name) == ".rels":
+			data, err := readZipPart(f, budget)
+			if err != nil {
+				continue
+			}
+			var rels ooxmlRelationships
+			if xml.Unmarshal(data, &rels) != nil {
+				continue
+			}
+			for _, rel := range rels.Rels {
+				if rel.Mode != "External" {
+					continue
+				}
+				t := rel.Target
+				switch path.Base(rel.Type) {
+				case "attachedTemplate":
+					template = t
+				case "externalLinkPath":
+					external = append(external, t)
+				case "hyperlink":
+					// Web links are ordinary content, UNC ones are not
+					if !isUNC(t) {
+						continue
+					}
+				}
+				if isUNC(t) {
+					uncs = append(uncs, t)
+				}
+				if !slices.Contains(links, t) {
+					links = append(links, t)
+				}
+			}
+		}
+	}
+
+	// The attached template path says more than the name in app.xml
+	if template != "" {
+		meta["template"] = template
+	}
+	uncs = append(uncs, uncPath.FindAllString(text, -1)...)
+	if len(uncs) > 0 {
+		slices.Sort(uncs)
+		meta["unc_paths"] = strings.Join(slices.Compact(uncs), ", ")
+	}
+	if len(external) > 0 {
+		meta["external_links"] = strings.Join(external, ", ")
+	}
+	if len(links) > 0 {
+		text += "\n" + strings.Join(links, "\n") + "\n"
+	}
+	return &Document{Text: text, Metadata: meta}
+}
+
+// isUNC tells whether a relationship target points at a file share.
+func isUNC(target string) bool {
+	return strings.HasPrefix(target, `\\`) || strings.HasPrefix(strings.ToLower(target), "file://")
+}
+
+// xmlFields returns the text of the children of the root element, by local
+// name (flat property parts).
+func xmlFields(data []byte) map[string]string {
+	fields := make(map[string]string)
+	d := xml.NewDecoder(strings.NewReader(string(data)))
+	d.Strict = false
+
+	depth := 0
+	var name string
+	var text strings.Builder
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return fields
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				name = t.Name.Local
+				text.Reset()
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				fields[name] = strings.TrimSpace(text.String())
+			}
+			depth--
+		case xml.CharData:
+			if depth >= 2 {
+				text.Write(t)
+			}
+		}
+	}
+}
@@ -2,45 +2,298 @@ package extractor
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ledongthuc/pdf"
 )
 
+const (
+	// pdfTimeout bounds the time spent on one PDF.
+	pdfTimeout = 30 * time.Second
+	// pdfMaxPages and pdfMaxPageText bound what is read from the page tree
+	// and from each page.
+	pdfMaxPages    = 5000
+	pdfMaxPageText = 1024 * 1024
+	// pdfMaxDepth bounds the nesting of page and form field trees (cycles).
+	pdfMaxDepth = 32
+)
+
+// pdfInfo maps document information entries to the metadata keys the
+// other extractors use.
+var pdfInfo = map[string]string{
+	"Title":        "title",
+	"Subject":      "subject",
+	"Author":       "author",
+	"Keywords":     "keywords",
+	"Creator":      "creator",
+	"Producer":     "producer",
+	"CreationDate": "created",
+	"ModDate":      "modified",
+}
+
+// PdfExtractor reads the text of the pages, the values of the form fields
+// and the document information. Encrypted files open when the user password
+// is empty (permissions-only protection) and the encryption is RC4 or
+// AES-128. The parser panics on some malformed files and can loop on
+// others: extraction runs under recover and a time budget, and what was read
+// until then is kept, with the reason in the "extract_error" metadata.
 type PdfExtractor struct{}
 
 func (e *PdfExtractor) Extract(r io.Reader, filename string) (string, error) {
+	doc, err := e.ExtractDocument(r, filename)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text, nil
+}
+
+func (e *PdfExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
 	// ledongthuc/pdf needs io.ReaderAt and size.
 	// We have to buffer it.
 	b, err := io.ReadAll(r)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	rdr := bytes.NewReader(b)
+	x := &pdfExtraction{meta: make(map[string]string)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if v := recover(); v != nil {
+				x.fail(ReasonPanic, fmt.Errorf("%v", v))
+			}
+		}()
+		x.run(pdfRepair(b))
+	}()
+
+	timer := time.NewTimer(pdfTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		// The parser cannot be interrupted: it is left to finish on its own
+		// and what it adds from now on is dropped
+		x.fail(ReasonTimeout, fmt.Errorf("no result after %s", pdfTimeout))
+	}
+	return x.result()
+}
 
+// pdfExtraction collects the output of the parsing goroutine.
+type pdfExtraction struct {
+	mu     sync.Mutex
+	text   strings.Builder
+	meta   map[string]string
+	err    *ExtractError
+	closed bool
+}
+
+func (x *pdfExtraction) write(s string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.closed || x.text.Len() >= MaxInputSize {
+		return false
+	}
+	x.text.WriteString(s)
+	return true
+}
+
+func (x *pdfExtraction) set(key, value string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.closed && value != "" {
+		x.meta[key] = value
+	}
+}
+
+// fail records the first failure and stops the collection.
+func (x *pdfExtraction) fail(reason string, err error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.err == nil {
+		x.err = &ExtractError{Reason: reason, Err: err}
+	}
+	x.closed = true
+}
+
+func (x *pdfExtraction) result() (*Document, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.closed = true
+	if x.err != nil {
+		if x.text.Len() == 0 {
+			return nil, x.err
+		}
+		x.meta["extract_error"] = x.err.Reason
+	}
+	return &Document{Text: x.text.String(), Metadata: x.meta}, nil
+}
+
+func (x *pdfExtraction) run(b []byte) {
+	rdr := bytes.NewReader(b)
 	f, err := pdf.NewReader(rdr, rdr.Size())
 	if err != nil {
-		return "", err
+		reason := ReasonMalformed
+		if errors.Is(err, pdf.ErrInvalidPassword) || bytes.Contains(b, []byte("/Encrypt")) {
+			// A user password is set, or AES-256 (not supported by the
+			// parser)
+			reason = ReasonEncrypted
+		}
+		x.fail(reason, err)
+		return
 	}
 
-	var buf bytes.Buffer
-	// Get total pages
-	totalPage := f.NumPage()
-
-	for pageIndex := 1; pageIndex <= totalPage; pageIndex++ {
-		p := f.Page(pageIndex)
-		if p.V.IsNull() {
-			continue
+	trailer := f.Trailer()
+	if enc := trailer.Key("Encrypt"); !enc.IsNull() {
+		x.set("encryption", pdfEncryption(enc))
+	}
+	info := trailer.Key("Info")
+	for key, name := range pdfInfo {
+		v := info.Key(key).Text()
+		if strings.HasSuffix(key, "Date") {
+			v = pdfDate(v)
 		}
+		x.set(name, strings.TrimSpace(v))
+	}
 
+	pages := 0
+	pdfPages(trailer.Key("Root").Key("Pages"), 0, func(p pdf.Page) bool {
+		pages++
+		// Pages that fail to parse are skipped
 		s, err := p.GetPlainText(nil)
-		if err != nil {
-			continue
+		if err == nil && s != "" {
+			if len(s) > pdfMaxPageText {
+				s = s[:pdfMaxPageText]
+			}
+			if !x.write(s + "\n") {
+				return false
+			}
 		}
-		buf.WriteString(s)
-		buf.WriteString("\n")
+		return pages < pdfMaxPages
+	})
+
+	var fields []string
+	pdfFields(trailer.Key("Root").Key("AcroForm").Key("Fields"), "", 0, func(name, value string) {
+		fields = append(fields, name+": "+value+"\n")
+	})
+	if len(fields) > 0 {
+		x.write(strings.Join(fields, ""))
+		x.set("form_fields", fmt.Sprint(len(fields)))
+	}
+}
+
+// pdfPages walks the page tree in order, without the page count (which a
+// malformed file can set to anything), until fn returns false.
+func pdfPages(node pdf.Value, depth int, fn func(pdf.Page) bool) bool {
+	if depth > pdfMaxDepth {
+		return true
+	}
+	if node.Key("Type").Name() == "Page" {
+		return fn(pdf.Page{V: node})
+	}
+	kids := node.Key("Kids")
+	for i := 0; i < kids.Len(); i++ {
+		if !pdfPages(kids.Index(i), depth+1, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// pdfFields calls fn with the fully qualified name and the value of each
+// filled-in form field.
+func pdfFields(fields pdf.Value, parent string, depth int, fn func(name, value string)) {
+	if depth > pdfMaxDepth {
+		return
+	}
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Index(i)
+		name := parent
+		if t := field.Key("T").Text(); t != "" {
+			if name != "" {
+				name += "."
+			}
+			name += t
+		}
+		if v := pdfFieldValue(field.Key("V")); v != "" {
+			fn(name, v)
+		}
+		pdfFields(field.Key("Kids"), name, depth+1, fn)
+	}
+}
+
+func pdfFieldValue(v pdf.Value) string {
+	switch v.Kind() {
+	case pdf.String:
+		return v.Text()
+	case pdf.Name:
+		// Check boxes and radio buttons: "Off" is unset
+		if v.Name() == "Off" {
+			return ""
+		}
+		return v.Name()
+	case pdf.Array:
+		var values []string
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, v.Index(i).Text())
+		}
+		return strings.Join(values, ", ")
 	}
+	return ""
+}
 
-	return buf.String(), nil
+// pdfEncryption describes the encryption of a file opened without password.
+func pdfEncryption(enc pdf.Value) string {
+	bits := enc.Key("Length").Int64()
+	if bits == 0 {
+		bits = 40
+	}
+	if enc.Key("V").Int64() == 4 {
+		if enc.Key("CF").Key("StdCF").Key("CFM").Name() == "AESV2" {
+			return "AES-128, no user password"
+		}
+		bits = 128
+	}
+	return fmt.Sprintf("RC4-%d, no user password", bits)
+}
+
+// pdfDate converts a PDF date (D:20240501103000+02'00') to RFC 3339.
+func pdfDate(s string) string {
+	d := strings.TrimPrefix(strings.TrimSpace(s), "D:")
+	if len(d) < 14 {
+		return s
+	}
+	zone := strings.ReplaceAll(strings.TrimSuffix(d[14:], "'"), "'", ":")
+	layout := "20060102150405Z07:00"
+	if zone == "" {
+		layout = "20060102150405"
+	}
+	t, err := time.Parse(layout, d[:14]+zone)
+	if err != nil {
+		return s
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// pdfRepair fixes what makes the parser reject files other readers open:
+// a PDF 2.0 or space-terminated header, and data after the last %%EOF. b is
+// a private copy, changed in place.
+func pdfRepair(b []byte) []byte {
+	if len(b) > 9 && bytes.HasPrefix(b, pdfMagic) {
+		if b[5] != '1' || b[6] != '.' || b[7] < '0' || b[7] > '7' {
+			copy(b[5:8], "1.7")
+		}
+		if b[8] != '\r' && b[8] != '\n' {
+			b[8] = '\n'
+		}
+	}
+	if i := bytes.LastIndex(b, []byte("%%EOF")); i >= 0 {
+		b = b[:i+len("%%EOF")]
+	}
+	return b
 }
@@ -0,0 +1,179 @@
+package extractor_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/0xSterny/spuderman/pkg/extractor"
+)
+
+var pdfPad = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
+const pdfID = "0123456789abcdef"
+
+func padded(pw string) []byte {
+	return append([]byte(pw), pdfPad[:32-len(pw)]...)
+}
+
+func rc4Iterations(key, data []byte) []byte {
+	out := bytes.Clone(data)
+	for i := 0; i <= 19; i++ {
+		k := bytes.Clone(key)
+		for j := range k {
+			k[j] ^= byte(i)
+		}
+		c, _ := rc4.NewCipher(k)
+		c.XORKeyStream(out, out)
+	}
+	return out
+}
+
+// pdfEncryption returns the RC4 128-bit (R3) file key and Encrypt
+// dictionary for a user password, owner password "owner".
+func pdfEncryption(user string) ([]byte, string) {
+	h := md5.Sum(padded("owner"))
+	for range 50 {
+		h = md5.Sum(h[:])
+	}
+	o := rc4Iterations(h[:], padded(user))
+
+	p := int32(-4)
+	m := md5.New()
+	m.Write(padded(user))
+	m.Write(o)
+	m.Write([]byte{byte(p), byte(p >> 8), byte(p >> 16), byte(p >> 24)})
+	m.Write([]byte(pdfID))
+	key := m.Sum(nil)
+	for range 50 {
+		k := md5.Sum(key)
+		key = k[:]
+	}
+
+	m.Reset()
+	m.Write(pdfPad)
+	m.Write([]byte(pdfID))
+	u := append(rc4Iterations(key, m.Sum(nil)), make([]byte, 16)...)
+	return key, fmt.Sprintf("<< /Filter /Standard /V 2 /R 3 /Length 128 /P %d /O <%x> /U <%x> >>", p, o, u)
+}
+
+// pdfFile writes a one-page PDF with a document information dictionary and
+// a form field, encrypted with key when set.
+func pdfFile(header string, key []byte, encrypt string) []byte {
+	crypt := func(n int, s string) []byte {
+		if key == nil {
+			return []byte(s)
+		}
+		k := md5.Sum(append(bytes.Clone(key), byte(n), byte(n>>8), byte(n>>16), 0, 0))
+		c, _ := rc4.NewCipher(k[:])
+		out := make([]byte, len(s))
+		c.XORKeyStream(out, []byte(s))
+		return out
+	}
+	str := func(n int, s string) string { return "<" + hex.EncodeToString(crypt(n, s)) + ">" }
+	content := crypt(4, "BT /F1 12 Tf 72 712 Td (password=Pl41n) Tj ET")
+
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [7 0 R] >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Author %s /Producer %s /CreationDate %s >>", str(6, "J. Doe"), str(6, "Microsoft Word"), str(6, "D:20240501103000+02'00'")),
+		fmt.Sprintf("<< /T %s /Kids [<< /T %s /V %s >>] >>", str(7, "login"), str(7, "password"), str(7, "F0rmSecret")),
+	}
+	trailer := "/Root 1 0 R /Info 6 0 R"
+	if encrypt != "" {
+		objs = append(objs, encrypt)
+		trailer += fmt.Sprintf(" /Encrypt 8 0 R /ID [<%x> <%x>]", pdfID, pdfID)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header + "\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, trailer, xref)
+	return buf.Bytes()
+}
+
+func TestPdfExtractor(t *testing.T) {
+	key, encrypt := pdfEncryption("")
+	tests := []struct {
+		name string
+		data []byte
+		meta map[string]string
+	}{
+		{"plain", pdfFile("%PDF-1.7", nil, ""), nil},
+		{"empty user password", pdfFile("%PDF-1.6", key, encrypt), map[string]string{"encryption": "RC4-128, no user password"}},
+		{"pdf 2.0, data after EOF", append(pdfFile("%PDF-2.0", nil, ""), "\x00\x00\r\n"...), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := extractor.ExtractDocument(&extractor.PdfExtractor{}, bytes.NewReader(tt.data), "form.pdf")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{"password=Pl41n", "login.password: F0rmSecret"} {
+				if !strings.Contains(doc.Text, want) {
+					t.Errorf("text %q does not contain %q", doc.Text, want)
+				}
+			}
+			meta := map[string]string{
+				"author":      "J. Doe",
+				"producer":    "Microsoft Word",
+				"created":     "2024-05-01T08:30:00Z",
+				"form_fields": "1",
+			}
+			for k, v := range tt.meta {
+				meta[k] = v
+			}
+			for k, v := range meta {
+				if doc.Metadata[k] != v {
+					t.Errorf("%s: got %q, want %q", k, doc.Metadata[k], v)
+				}
+			}
+			if _, ok := doc.Metadata["extract_error"]; ok {
+				t.Errorf("unexpected extract_error %q", doc.Metadata["extract_error"])
+			}
+		})
+	}
+}
+
+func TestPdfExtractorFailures(t *testing.T) {
+	key, encrypt := pdfEncryption("user")
+	tests := []struct {
+		name   string
+		data   []byte
+		reason string
+	}{
+		{"user password", pdfFile("%PDF-1.6", key, encrypt), extractor.ReasonEncrypted},
+		{"malformed", []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog\n%%EOF\n"), extractor.ReasonMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := extractor.ExtractDocument(&extractor.PdfExtractor{}, bytes.NewReader(tt.data), "x.pdf")
+			var xerr *extractor.ExtractError
+			if !errors.As(err, &xerr) || xerr.Reason != tt.reason {
+				t.Errorf("got %v, want a %q ExtractError", err, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package extractor
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// RtfExtractor for .rtf files: strips control words and groups that hold no
+// document text (font tables, pictures, embedded objects...).
+type RtfExtractor struct{}
+
+func (e *RtfExtractor) Extract(r io.Reader, filename string) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return rtfText(b), nil
+}
+
+// rtfSkipped are destinations whose content is not text.
+var rtfSkipped = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true,
+	"pict": true, "object": true, "objdata": true, "themedata": true,
+	"colorschememapping": true, "datastore": true, "listtable": true,
+	"listoverridetable": true, "rsidtbl": true, "generator": true,
+	"xmlnstbl": true, "latentstyles": true, "filetbl": true, "revtbl": true,
+	"pgdsctbl": true,
+}
+
+var rtfCodepages = map[int]*charmap.Charmap{
+	437:  charmap.CodePage437,
+	850:  charmap.CodePage850,
+	1250: charmap.Windows1250,
+	1251: charmap.Windows1251,
+	1252: charmap.Windows1252,
+	1253: charmap.Windows1253,
+	1254: charmap.Windows1254,
+	1255: charmap.Windows1255,
+	1256: charmap.Windows1256,
+	1257: charmap.Windows1257,
+}
+
+type rtfGroup struct {
+	skip bool // Inside an ignored destination
+	uc   int  // Fallback characters after \u
+}
+
+func rtfText(b []byte) string {
+	var sb strings.Builder
+	cp := charmap.Windows1252
+	state := rtfGroup{uc: 1}
+	var stack []rtfGroup
+	pending := 0 // Fallback characters still to drop after \u
+
+	emit := func(s string) {
+		if state.skip {
+			return
+		}
+		if pending > 0 {
+			pending--
+			return
+		}
+		sb.WriteString(s)
+	}
+
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; c {
+		case '{':
+			stack = append(stack, state)
+			pending = 0
+		case '}':
+			if len(stack) > 0 {
+				state, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+			pending = 0
+		case '\r', '\n':
+		case '\\':
+			if i+1 >= len(b) {
+				break
+			}
+			i++
+			c = b[i]
+			if !isRTFLetter(c) {
+				// Control symbol
+				switch c {
+				case '\\', '{', '}':
+					emit(string(c))
+				case '~':
+					emit(" ")
+				case '_':
+					emit("-")
+				case '*':
+					// Unknown destinations are marked \*: skip them
+					state.skip = true
+				case '\'':
+					if i+2 < len(b) {
+						if v, err := strconv.ParseUint(string(b[i+1:i+3]), 16, 8); err == nil {
+							emit(string(cp.DecodeByte(byte(v))))
+						}
+						i += 2
+					}
+				case '\r', '\n':
+					emit("\n")
+				}
+				break
+			}
+
+			// Control word: letters, optional signed number, optional space
+			start := i
+			for i < len(b) && isRTFLetter(b[i]) {
+				i++
+			}
+			word := string(b[start:i])
+			numStart := i
+			if i < len(b) && b[i] == '-' {
+				i++
+			}
+			for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+				i++
+			}
+			param, hasParam := 0, i > numStart
+			if hasParam {
+				param, _ = strconv.Atoi(string(b[numStart:i]))
+			}
+			if i >= len(b) || b[i] != ' ' {
+				i-- // The delimiter is part of the text
+			}
+
+			switch word {
+			case "par", "line", "row", "sect", "page":
+				emit("\n")
+			case "tab", "cell":
+				emit("\t")
+			case "u":
+				if param < 0 {
+					param += 65536
+				}
+				emit(string(rune(param)))
+				pending = state.uc
+			case "uc":
+				state.uc = param
+			case "bin":
+				// Raw binary data follows
+				if param > 0 {
+					i += param
+				}
+			case "ansicpg":
+				if m, ok := rtfCodepages[param]; ok {
+					cp = m
+				}
+			default:
+				if rtfSkipped[word] {
+					state.skip = true
+				}
+			}
+		default:
+			emit(string(cp.DecodeByte(c)))
+		}
+	}
+	return sb.String()
+}
+
+func isRTFLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,423 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// sqliteMaxRows bounds the rows dumped per table.
+const sqliteMaxRows = 10000
+
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// SqliteExtractor for SQLite databases. The file format is parsed directly
+// from a private in-memory copy, without a driver: the original is never
+// opened by SQLite, so no lock, journal or WAL file is touched on the share.
+// Each text value comes out as "table.column: value", row by row.
+type SqliteExtractor struct{}
+
+func (e *SqliteExtractor) Extract(r io.Reader, filename string) (string, error) {
+	doc, err := e.ExtractDocument(r, filename)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text, nil
+}
+
+func (e *SqliteExtractor) ExtractDocument(r io.Reader, filename string) (*Document, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openSqlite(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	var tables []string
+	for _, t := range db.tables() {
+		tables = append(tables, t.name)
+		if sb.Len() >= MaxInputSize {
+			continue
+		}
+		rows := 0
+		db.walk(t.root, func(values []any) bool {
+			for i, v := range values {
+				s, ok := sqliteText(v)
+				if !ok {
+					continue
+				}
+				// WITHOUT ROWID rows start with the primary key, not in
+				// declaration order
+				col := "col" + strconv.Itoa(i)
+				if i < len(t.columns) && !t.withoutRowid {
+					col = t.columns[i]
+				}
+				fmt.Fprintf(&sb, "%s.%s: %s\n", t.name, col, s)
+			}
+			rows++
+			return rows < sqliteMaxRows && sb.Len() < MaxInputSize
+		})
+	}
+	return &Document{Text: sb.String(), Metadata: map[string]string{"tables": strings.Join(tables, ", ")}}, nil
+}
+
+// sqliteText keeps text values, and blobs that are printable text.
+func sqliteText(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, v != ""
+	case []byte:
+		if len(v) == 0 || !utf8.Valid(v) {
+			return "", false
+		}
+		for _, r := range string(v) {
+			if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+				return "", false
+			}
+		}
+		return string(v), true
+	}
+	return "", false
+}
+
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	usable   int
+	encoding uint32 // 1 UTF-8, 2 UTF-16LE, 3 UTF-16BE
+	visited  map[int]bool
+}
+
+type sqliteTable struct {
+	name         string
+	root         int
+	columns      []string
+	withoutRowid bool
+}
+
+func openSqlite(b []byte) (*sqliteDB, error) {
+	if len(b) < 100 || !bytes.HasPrefix(b, sqliteMagic) {
+		return nil, errors.New("not a SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(b[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, errors.New("bad SQLite page size")
+	}
+	return &sqliteDB{
+		data:     b,
+		pageSize: pageSize,
+		usable:   pageSize - int(b[20]),
+		encoding: binary.BigEndian.Uint32(b[56:]),
+	}, nil
+}
+
+// tables lists the tables from the schema (sqlite_master, rooted at page 1).
+func (db *sqliteDB) tables() []sqliteTable {
+	var tables []sqliteTable
+	db.walk(1, func(v []any) bool {
+		if len(v) < 5 {
+			return true
+		}
+		typ, _ := v[0].(string)
+		name, _ := v[1].(string)
+		root, _ := v[3].(int64)
+		sql, _ := v[4].(string)
+		if typ == "table" && root > 0 && !strings.HasPrefix(name, "sqlite_") {
+			tables = append(tables, sqliteTable{
+				name:         name,
+				root:         int(root),
+				columns:      sqliteColumns(sql),
+				withoutRowid: strings.Contains(strings.ToUpper(sql), "WITHOUT ROWID"),
+			})
+		}
+		return true
+	})
+	return tables
+}
+
+// page returns page n (1-based), or nil past the end of the copy.
+func (db *sqliteDB) page(n int) []byte {
+	off := (n - 1) * db.pageSize
+	if n < 1 || off+db.pageSize > len(db.data) {
+		return nil
+	}
+	return db.data[off : off+db.pageSize]
+}
+
+// walk calls fn with the records of the b-tree rooted at root, in order,
+// until fn returns false. Table b-trees hold the rows of rowid tables, index
+// b-trees those of WITHOUT ROWID tables.
+func (db *sqliteDB) walk(root int, fn func([]any) bool) bool {
+	db.visited = make(map[int]bool)
+	return db.walkPage(root, fn)
+}
+
+func (db *sqliteDB) walkPage(n int, fn func([]any) bool) bool {
+	p := db.page(n)
+	if p == nil || db.visited[n] {
+		return true // Truncated copy or corrupt: best effort
+	}
+	db.visited[n] = true
+
+	hdr := 0
+	if n == 1 {
+		hdr = 100 // Database header
+	}
+	if hdr+8 > len(p) {
+		return true
+	}
+	typ := p[hdr]
+	cells := int(binary.BigEndian.Uint16(p[hdr+3:]))
+	interior := typ == 0x02 || typ == 0x05
+	ptrs := hdr + 8
+	if interior {
+		ptrs = hdr + 12
+	}
+
+	for i := 0; i < cells; i++ {
+		if ptrs+2*i+2 > len(p) {
+			return true
+		}
+		off := int(binary.BigEndian.Uint16(p[ptrs+2*i:]))
+		if off >= len(p) {
+			continue
+		}
+		cell := p[off:]
+
+		switch typ {
+		case 0x05: // Table interior: child, rowid
+			if len(cell) >= 4 && !db.walkPage(int(binary.BigEndian.Uint32(cell)), fn) {
+				return false
+			}
+		case 0x0d: // Table leaf: payload size, rowid, payload
+			size, k := sqliteVarint(cell)
+			_, k2 := sqliteVarint(cell[k:])
+			if rec := db.payload(cell[k+k2:], int(size), false); rec != nil && !fn(db.record(rec)) {
+				return false
+			}
+		case 0x02: // Index interior: child, payload size, payload
+			if len(cell) < 4 {
+				continue
+			}
+			if !db.walkPage(int(binary.BigEndian.Uint32(cell)), fn) {
+				return false
+			}
+			size, k := sqliteVarint(cell[4:])
+			if rec := db.payload(cell[4+k:], int(size), true); rec != nil && !fn(db.record(rec)) {
+				return false
+			}
+		case 0x0a: // Index leaf: payload size, payload
+			size, k := sqliteVarint(cell)
+			if rec := db.payload(cell[k:], int(size), true); rec != nil && !fn(db.record(rec)) {
+				return false
+			}
+		default:
+			return true
+		}
+	}
+	if interior && hdr+12 <= len(p) {
+		return db.walkPage(int(binary.BigEndian.Uint32(p[hdr+8:])), fn)
+	}
+	return true
+}
+
+// payload assembles a cell payload of size bytes, following overflow pages
+// when it does not fit in the page.
+func (db *sqliteDB) payload(local []byte, size int, index bool) []byte {
+	if size < 0 || size > MaxInputSize {
+		return nil
+	}
+	u := db.usable
+	x := u - 35
+	if index {
+		x = (u-12)*64/255 - 23
+	}
+	n := size
+	if size > x {
+		m := (u-12)*32/255 - 23
+		n = m + (size-m)%(u-4)
+		if n > x {
+			n = m
+		}
+	}
+	if n > len(local) {
+		return nil
+	}
+	out := append([]byte(nil), local[:n]...)
+	if n == size {
+		return out
+	}
+
+	if n+4 > len(local) {
+		return nil
+	}
+	next := int(binary.BigEndian.Uint32(local[n:]))
+	seen := make(map[int]bool)
+	for len(out) < size && next != 0 && !seen[next] {
+		seen[next] = true
+		p := db.page(next)
+		if p == nil {
+			break
+		}
+		next = int(binary.BigEndian.Uint32(p))
+		out = append(out, p[4:min(u, 4+size-len(out))]...)
+	}
+	return out
+}
+
+// record decodes a record: a header of serial types, then the values.
+func (db *sqliteDB) record(rec []byte) []any {
+	hdrLen, k := sqliteVarint(rec)
+	if hdrLen > uint64(len(rec)) || hdrLen < uint64(k) {
+		return nil
+	}
+	var values []any
+	body := int(hdrLen)
+	for pos := k; pos < int(hdrLen); {
+		st, n := sqliteVarint(rec[pos:hdrLen])
+		if n == 0 {
+			break
+		}
+		pos += n
+
+		var size int
+		switch {
+		case st <= 4:
+			size = int(st)
+		case st == 5:
+			size = 6
+		case st == 6, st == 7:
+			size = 8
+		case st >= 12:
+			size = int(st-12) / 2
+		}
+		if body+size > len(rec) {
+			break
+		}
+		v := rec[body : body+size]
+		body += size
+
+		switch {
+		case st == 0:
+			values = append(values, nil)
+		case st <= 6:
+			var i int64
+			for _, c := range v {
+				i = i<<8 | int64(c)
+			}
+			// Sign extend
+			if size > 0 && size < 8 && v[0]&0x80 != 0 {
+				i -= 1 << (8 * size)
+			}
+			values = append(values, i)
+		case st == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case st == 8, st == 9:
+			values = append(values, int64(st-8))
+		case st >= 12 && st%2 == 0:
+			values = append(values, v)
+		case st >= 13:
+			values = append(values, db.text(v))
+		default:
+			values = append(values, nil)
+		}
+	}
+	return values
+}
+
+func (db *sqliteDB) text(v []byte) string {
+	switch db.encoding {
+	case 2:
+		return string(decodeUTF16LE(v))
+	case 3:
+		u := make([]byte, len(v)&^1)
+		for i := 0; i+1 < len(v); i += 2 {
+			u[i], u[i+1] = v[i+1], v[i]
+		}
+		return string(decodeUTF16LE(u))
+	}
+	return string(v)
+}
+
+// sqliteVarint decodes a big-endian varint of up to 9 bytes.
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 9; i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, 0
+}
+
+// sqliteColumns returns the column names of a CREATE TABLE statement.
+func sqliteColumns(sql string) []string {
+	open, end := strings.IndexByte(sql, '('), strings.LastIndexByte(sql, ')')
+	if open < 0 || end < open {
+		return nil
+	}
+
+	// Split on the commas outside parentheses and quotes
+	var defs []string
+	depth, start := 0, open+1
+	var quote byte
+	for i := open + 1; i < end; i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote || quote == '[' && c == ']' {
+				quote = 0
+			}
+		case c == '"' || c == '`' || c == '\'' || c == '[':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			defs = append(defs, sql[start:i])
+			start = i + 1
+		}
+	}
+	defs = append(defs, sql[start:end])
+
+	var cols []string
+	for _, d := range defs {
+		d = strings.TrimSpace(d)
+		first := strings.ToUpper(strings.SplitN(d, " ", 2)[0])
+		switch first {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue // Table constraint
+		}
+		name := d
+		if len(d) > 0 && strings.ContainsRune("\"`'[", rune(d[0])) {
+			closing := d[0]
+			if closing == '[' {
+				closing = ']'
+			}
+			if i := strings.IndexByte(d[1:], closing); i >= 0 {
+				name = d[1 : i+1]
+			}
+		} else if i := strings.IndexAny(d, " \t\n\r"); i >= 0 {
+			name = d[:i]
+		}
+		cols = append(cols, name)
+	}
+	return cols
+}
@@ -0,0 +1,119 @@
+package extractor_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/0xSterny/spuderman/pkg/extractor"
+)
+
+func sqliteVarint(v uint64) []byte {
+	if v < 0x80 {
+		return []byte{byte(v)}
+	}
+	var groups []byte
+	for ; v > 0; v >>= 7 {
+		groups = append([]byte{byte(v&0x7f) | 0x80}, groups...)
+	}
+	groups[len(groups)-1] &^= 0x80
+	return groups
+}
+
+func sqliteRecord(values ...any) []byte {
+	var hdr, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			hdr = append(hdr, 0)
+		case int:
+			hdr = append(hdr, 1)
+			body = append(body, byte(v))
+		case string:
+			hdr = append(hdr, sqliteVarint(uint64(13+2*len(v)))...)
+			body = append(body, v...)
+		case []byte:
+			hdr = append(hdr, sqliteVarint(uint64(12+2*len(v)))...)
+			body = append(body, v...)
+		}
+	}
+	rec := append(sqliteVarint(uint64(len(hdr)+1)), hdr...)
+	return append(rec, body...)
+}
+
+// sqliteLeaf builds a table leaf page holding the records; page 1 starts
+// with the database header.
+func sqliteLeaf(first bool, records ...[]byte) []byte {
+	const pageSize = 1024
+	p := make([]byte, pageSize)
+	hdr := 0
+	if first {
+		hdr = 100
+	}
+	p[hdr] = 0x0d
+	binary.BigEndian.PutUint16(p[hdr+3:], uint16(len(records)))
+
+	end := pageSize
+	for i, rec := range records {
+		cell := append(sqliteVarint(uint64(len(rec))), sqliteVarint(uint64(i+1))...)
+		cell = append(cell, rec...)
+		end -= len(cell)
+		copy(p[end:], cell)
+		binary.BigEndian.PutUint16(p[hdr+8+2*i:], uint16(end))
+	}
+	binary.BigEndian.PutUint16(p[hdr+5:], uint16(end))
+	return p
+}
+
+func TestDatabaseExtractors(t *testing.T) {
+	schema := sqliteLeaf(true, sqliteRecord("table", "users", "users", 2,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, "login" TEXT, password TEXT, avatar BLOB, UNIQUE(login))`))
+	copy(schema, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(schema[16:], 1024)
+	schema[18], schema[19], schema[21], schema[22], schema[23] = 1, 1, 64, 32, 32
+	binary.BigEndian.PutUint32(schema[28:], 2)
+	binary.BigEndian.PutUint32(schema[56:], 1) // UTF-8
+
+	users := sqliteLeaf(false,
+		sqliteRecord(nil, "admin", "S3cret!", []byte{0, 1, 2}),
+		sqliteRecord(nil, "svc_backup", "Backup#2024", []byte("printable"), 7),
+	)
+	db := append(schema, users...)
+
+	for _, name := range []string{"app.sqlite", "app.dat"} {
+		e := extractor.GetExtractorFor(name, head(db))
+		doc, err := extractor.ExtractDocument(e, bytes.NewReader(db), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "users.login: admin\nusers.password: S3cret!\n" +
+			"users.login: svc_backup\nusers.password: Backup#2024\nusers.avatar: printable\n"
+		if doc.Text != want {
+			t.Errorf("%s: text = %q, want %q", name, doc.Text, want)
+		}
+		if doc.Metadata["tables"] != "users" {
+			t.Errorf("%s: metadata = %v", name, doc.Metadata)
+		}
+	}
+
+	mdb := append([]byte("\x00\x01\x00\x00Standard Jet DB\x00\x01"), make([]byte, 64)...)
+	mdb = append(mdb, "\x00\x00sa_password\x00"...)
+	for _, c := range utf16.Encode([]rune("Connect=ODBC;PWD=Jet4pw")) {
+		mdb = binary.LittleEndian.AppendUint16(mdb, c)
+	}
+	mdb = append(mdb, 0, 0)
+	doc, err := extractor.ExtractDocument(extractor.GetExtractorFor("crm.mdb", head(mdb)), bytes.NewReader(mdb), "crm.mdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"sa_password\n", "Connect=ODBC;PWD=Jet4pw\n"} {
+		if !strings.Contains(doc.Text, want) {
+			t.Errorf("mdb text %q does not contain %q", doc.Text, want)
+		}
+	}
+	if doc.Metadata["format"] != "Jet 4" {
+		t.Errorf("mdb metadata = %v", doc.Metadata)
+	}
+}
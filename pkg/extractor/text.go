@@ -16,6 +16,18 @@ func (e *TextExtractor) Extract(r io.Reader, filename string) (string, error) {
 	return string(b), nil
 }
 
+// HtmlExtractor reads the text of HTML pages, without markup, scripts and
+// styles.
+type HtmlExtractor struct{}
+
+func (e *HtmlExtractor) Extract(r io.Reader, filename string) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return htmlText(b), nil
+}
+
 // StringsExtractor mimics the unix 'strings' command
 // It finds printable strings of length >= 4
 type StringsExtractor struct {
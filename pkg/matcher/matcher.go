@@ -16,8 +16,11 @@ type MatchConfig struct {
 }
 
 type Matcher struct {
-	FilenameRegex  []*regexp.Regexp
-	ContentRegex   []*regexp.Regexp
+	FilenameRegex []*regexp.Regexp
+	ContentRegex  []*regexp.Regexp
+	// ContentRules names the rule of each ContentRegex, for reporting:
+	// "content:<pattern>" or "preset:<name>".
+	ContentRules   []string
 	DirnameRegex   []*regexp.Regexp
 	ExcludeRegex   []*regexp.Regexp
 	BlacklistRegex []*regexp.Regexp
@@ -46,6 +49,7 @@ func NewMatcher(config MatchConfig) (*Matcher, error) {
 			return nil, err
 		}
 		m.ContentRegex = append(m.ContentRegex, re)
+		m.ContentRules = append(m.ContentRules, "content:"+p)
 	}
 
 	// 2. Load Presets
@@ -58,6 +62,7 @@ func NewMatcher(config MatchConfig) (*Matcher, error) {
 					return nil, err
 				}
 				m.ContentRegex = append(m.ContentRegex, re)
+				m.ContentRules = append(m.ContentRules, "preset:"+strings.ToLower(preset))
 			}
 		}
 	}
@@ -127,15 +132,22 @@ func (m *Matcher) CheckExtension(filename string) bool {
 
 // CheckFilenameRegex returns true if filename matches any of the regex patterns
 func (m *Matcher) CheckFilenameRegex(filename string) bool {
+	_, ok := m.MatchFilename(filename)
+	return ok
+}
+
+// MatchFilename is CheckFilenameRegex, also returning the rule that matched
+// ("filename:<pattern>").
+func (m *Matcher) MatchFilename(filename string) (string, bool) {
 	if len(m.Config.Filenames) == 0 {
-		return false
+		return "", false
 	}
-	for _, re := range m.FilenameRegex {
+	for i, re := range m.FilenameRegex {
 		if re.MatchString(filename) {
-			return true
+			return "filename:" + m.Config.Filenames[i], true
 		}
 	}
-	return false
+	return "", false
 }
 
 // Deprecated: wrapper for backward compatibility if needed, but we should use split checks.
@@ -145,27 +157,41 @@ func (m *Matcher) CheckFilename(filename string) bool {
 
 // CheckContent returns true if content matches regex, and the matching line (snippet)
 func (m *Matcher) CheckContent(text string) (bool, string) {
+	cm, ok := m.MatchContent(text)
+	return ok, cm.Snippet
+}
+
+// ContentMatch is where a content regex matched.
+type ContentMatch struct {
+	Rule    string // See ContentRules
+	Snippet string // The matching line, trimmed to 80 characters
+	Line    int    // 1-based, in the text searched
+}
+
+// MatchContent is CheckContent, also returning the rule that matched and
+// the line. The match is empty when there is no content filter.
+func (m *Matcher) MatchContent(text string) (ContentMatch, bool) {
 	if len(m.Config.Content) == 0 {
-		return true, "" // No content filter
+		return ContentMatch{}, true // No content filter
 	}
 
 	// Split text into lines to find the matching one
 	// This might be slow for huge files, but we already extracted it to memory.
 	lines := strings.Split(text, "\n")
 
-	for _, re := range m.ContentRegex {
-		for _, line := range lines {
+	for i, re := range m.ContentRegex {
+		for n, line := range lines {
 			if re.MatchString(line) {
 				// Found a match
 				snippet := strings.TrimSpace(line)
 				if len(snippet) > 80 {
 					snippet = snippet[:80] + "..."
 				}
-				return true, snippet
+				return ContentMatch{Rule: m.ContentRules[i], Snippet: snippet, Line: n + 1}, true
 			}
 		}
 	}
-	return false, ""
+	return ContentMatch{}, false
 }
 
 // CheckExclude returns true if the filename matches any exclusion pattern
@@ -0,0 +1,336 @@
+// Package report renders the JSON output of a scan (one finding per line,
+// then the summary) for people: a single HTML file that works offline.
+package report
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/0xSterny/spuderman/pkg/detector"
+	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// Scan is what a JSON output file holds.
+type Scan struct {
+	Findings []spider.MatchResult
+	Summary  *spider.Summary // Nil for scans that did not finish, or older ones
+}
+
+// Load reads the output of the JSON reporter. Older outputs, without rules,
+// snippets or summary, load too.
+func Load(r io.Reader) (*Scan, error) {
+	scan := &Scan{}
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for n := 1; ; n++ {
+		var line struct {
+			spider.MatchResult
+			Summary *spider.Summary `json:"summary"`
+		}
+		if err := dec.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d: %w", n, err)
+		}
+		if line.Summary != nil {
+			scan.Summary = line.Summary
+			continue
+		}
+		scan.Findings = append(scan.Findings, line.MatchResult)
+	}
+	return scan, nil
+}
+
+// Options are the settings of a report.
+type Options struct {
+	Title     string
+	Generated time.Time
+
+	// Loot holds the loot settings of the scan (LootDir, Structured,
+	// Delimiter), to find the downloads of findings without a loot_path.
+	Loot spider.Config
+	// Dir is where the report is written: loot links are relative to it.
+	Dir string
+}
+
+// Severity groups, most severe first. Findings of the filename and content
+// rules have no severity: they come last, as "unrated".
+var severities = []string{detector.SeverityCritical, detector.SeverityHigh, detector.SeverityMedium}
+
+func severityOrder(s string) int {
+	if s == "" {
+		return len(severities) + 1
+	}
+	if i := slices.Index(severities, s); i >= 0 {
+		return i
+	}
+	return len(severities)
+}
+
+func severityLabel(s string) string {
+	if s == "" {
+		return "unrated"
+	}
+	return s
+}
+
+//go:embed report.html
+var page string
+
+var pageTmpl = template.Must(template.New("report").Parse(page))
+
+// HTML writes the report of scan to w.
+func HTML(w io.Writer, scan *Scan, opts Options) error {
+	return pageTmpl.Execute(w, newView(scan, opts))
+}
+
+type view struct {
+	Title      string
+	Generated  string
+	Total      int
+	Hosts      []hostView
+	Severities []countView
+	Rules      []countView
+	HostNames  []string
+	Summary    *summaryView
+}
+
+type countView struct {
+	Name  string
+	Count int
+}
+
+type hostView struct {
+	Name   string
+	Count  int
+	Shares []shareView
+}
+
+type shareView struct {
+	Name   string
+	Count  int
+	Groups []groupView
+}
+
+type groupView struct {
+	Severity string // Label
+	severity string
+	Findings []findingView
+}
+
+type findingView struct {
+	spider.MatchResult
+	Severity string // Label
+	Label    string // Reason without the snippet
+	Snippet  template.HTML
+	URI      string
+	Size     string
+	Hash     string // Shortened
+	Loot     template.URL
+	Text     string // Searched by the filter
+}
+
+type summaryView struct {
+	Shares      []statsView
+	Total       statsView
+	FailedFiles []spider.FailedFile
+}
+
+type statsView struct {
+	spider.ShareStats
+	Read string
+}
+
+func newView(scan *Scan, opts Options) view {
+	v := view{Title: opts.Title, Generated: opts.Generated.UTC().Format(time.RFC3339), Total: len(scan.Findings)}
+
+	findings := slices.Clone(scan.Findings)
+	slices.SortStableFunc(findings, func(a, b spider.MatchResult) int {
+		return strings.Compare(a.Host+"\x00"+a.Share+"\x00"+a.Path, b.Host+"\x00"+b.Share+"\x00"+b.Path)
+	})
+
+	// Duplicates are only downloaded once: link them to the copy kept
+	links := make(map[string]template.URL)
+	for _, m := range findings {
+		if l := lootLink(m, opts); l != "" && m.Hash != "" && links[m.Hash] == "" {
+			links[m.Hash] = l
+		}
+	}
+
+	sevCounts := make(map[string]int)
+	ruleCounts := make(map[string]int)
+	for _, m := range findings {
+		f := newFinding(m, opts)
+		if f.Loot == "" {
+			f.Loot = links[m.Hash]
+		}
+		sevCounts[m.Severity]++
+		ruleCounts[f.Rule]++
+
+		if len(v.Hosts) == 0 || v.Hosts[len(v.Hosts)-1].Name != m.Host {
+			v.Hosts = append(v.Hosts, hostView{Name: m.Host})
+			v.HostNames = append(v.HostNames, m.Host)
+		}
+		h := &v.Hosts[len(v.Hosts)-1]
+		h.Count++
+		if len(h.Shares) == 0 || h.Shares[len(h.Shares)-1].Name != m.Share {
+			h.Shares = append(h.Shares, shareView{Name: m.Share})
+		}
+		sh := &h.Shares[len(h.Shares)-1]
+		sh.Count++
+		i := slices.IndexFunc(sh.Groups, func(g groupView) bool { return g.severity == m.Severity })
+		if i < 0 {
+			sh.Groups = append(sh.Groups, groupView{Severity: f.Severity, severity: m.Severity})
+			i = len(sh.Groups) - 1
+		}
+		sh.Groups[i].Findings = append(sh.Groups[i].Findings, f)
+	}
+
+	for hi := range v.Hosts {
+		for si := range v.Hosts[hi].Shares {
+			groups := v.Hosts[hi].Shares[si].Groups
+			slices.SortStableFunc(groups, func(a, b groupView) int {
+				return severityOrder(a.severity) - severityOrder(b.severity)
+			})
+		}
+	}
+
+	for s, n := range sevCounts {
+		v.Severities = append(v.Severities, countView{s, n})
+	}
+	slices.SortFunc(v.Severities, func(a, b countView) int {
+		if c := severityOrder(a.Name) - severityOrder(b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	for i := range v.Severities {
+		v.Severities[i].Name = severityLabel(v.Severities[i].Name)
+	}
+	for r, n := range ruleCounts {
+		v.Rules = append(v.Rules, countView{r, n})
+	}
+	slices.SortFunc(v.Rules, func(a, b countView) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if sum := scan.Summary; sum != nil {
+		sv := &summaryView{Total: statsView{sum.Total, utils.FormatBytes(sum.Total.BytesRead)}, FailedFiles: sum.FailedFiles}
+		for _, st := range sum.Shares {
+			sv.Shares = append(sv.Shares, statsView{st, utils.FormatBytes(st.BytesRead)})
+		}
+		v.Summary = sv
+	}
+	return v
+}
+
+func newFinding(m spider.MatchResult, opts Options) findingView {
+	f := findingView{MatchResult: m, Severity: severityLabel(m.Severity), URI: m.URI(), Loot: lootLink(m, opts)}
+	if m.Size > 0 {
+		f.Size = utils.FormatBytes(m.Size)
+	}
+	// Hand-edited or older files may hold anything as the hash
+	f.Hash = m.Hash
+	if len(f.Hash) > 8 {
+		f.Hash = f.Hash[:8]
+	}
+	if f.Rule == "" {
+		f.Rule = "unknown"
+	}
+
+	// Content reasons end with the snippet (in bold on terminals)
+	f.Label = spider.PlainText(m.Reason)
+	snippet := m.Snippet
+	if strings.HasPrefix(f.Label, "Content") {
+		if label, rest, ok := strings.Cut(f.Label, ": "); ok {
+			f.Label = label
+			if snippet == "" {
+				snippet = rest
+			}
+		}
+	}
+	f.Snippet = highlight(snippet, m.Rule)
+
+	f.Text = strings.ToLower(strings.Join([]string{m.Host, m.Share, m.Path, f.Label, snippet, f.Rule, m.Category, m.Hash}, " "))
+	return f
+}
+
+// highlight escapes snippet and marks what the rule matched in it.
+func highlight(snippet, rule string) template.HTML {
+	var patterns []string
+	kind, arg, _ := strings.Cut(rule, ":")
+	switch kind {
+	case "content":
+		patterns = []string{"(?i)" + arg}
+	case "preset":
+		patterns = matcher.SecretPresets[arg]
+	}
+
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			continue
+		}
+		locs := re.FindAllStringIndex(snippet, -1)
+		if len(locs) == 0 || locs[0][0] == locs[0][1] {
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, l := range locs {
+			b.WriteString(template.HTMLEscapeString(snippet[last:l[0]]))
+			b.WriteString("<mark>" + template.HTMLEscapeString(snippet[l[0]:l[1]]) + "</mark>")
+			last = l[1]
+		}
+		b.WriteString(template.HTMLEscapeString(snippet[last:]))
+		return template.HTML(b.String())
+	}
+	return template.HTML(template.HTMLEscapeString(snippet))
+}
+
+// lootLink returns the link to the downloaded copy of m, if it is there:
+// its loot_path, or for older scans where the loot settings put it.
+func lootLink(m spider.MatchResult, opts Options) template.URL {
+	p := m.LootPath
+	if p == "" {
+		if opts.Loot.LootDir == "" {
+			return ""
+		}
+		cfg := opts.Loot
+		cfg.Host, cfg.Share = m.Host, m.Share
+		p = cfg.LootPath(m.Path)
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return ""
+	}
+	if info, err := os.Stat(abs); err != nil || info.IsDir() {
+		return ""
+	}
+
+	if dir, err := filepath.Abs(opts.Dir); err == nil {
+		if rel, err := filepath.Rel(dir, abs); err == nil {
+			return template.URL((&url.URL{Path: filepath.ToSlash(rel)}).String())
+		}
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+	return template.URL(u.String())
+}
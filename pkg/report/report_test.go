@@ -0,0 +1,102 @@
+package report_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xSterny/spuderman/pkg/report"
+	"github.com/0xSterny/spuderman/pkg/spider"
+)
+
+// An older scan (no rule, snippet or loot_path; the snippet in bold in the
+// reason), a current one and the summary.
+const results = `{"path":"IT/notes.txt","reason":"Content: \u001b[1mdb password=Hunter2\u001b[0m","sha256":"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa","timestamp":"2024-05-01T10:00:00Z","host":"10.0.0.5","share":"C$"}
+{"path":"Windows/System32/config/SAM","reason":"Registry hive (SAM)","rule":"classify:registry-hive","severity":"critical","category":"registry-hive","sha256":"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb","timestamp":"2024-05-01T10:00:01Z","host":"10.0.0.5","share":"C$"}
+{"path":"<script>alert(1)</script>.txt","reason":"Content: api_key=x","rule":"preset:auth","snippet":"api_key=x","line":3,"timestamp":"2024-05-01T10:00:02Z","host":"10.0.0.6","share":"Users"}
+{"summary":{"shares":[{"host":"10.0.0.5","share":"C$","dirs":4,"files":12,"scanned":3,"bytes_read":2048,"access_denied":1,"downloads":2}],"total":{"dirs":4,"files":12,"scanned":3,"bytes_read":2048,"access_denied":1,"downloads":2},"failed_files":[{"host":"10.0.0.5","share":"C$","path":"big.pdf","reason":"timeout"}]}}
+`
+
+func TestLoad(t *testing.T) {
+	scan, err := report.Load(strings.NewReader(results))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scan.Findings) != 3 || scan.Summary == nil {
+		t.Fatalf("got %d findings, summary %v", len(scan.Findings), scan.Summary)
+	}
+	if scan.Findings[1].Severity != "critical" || scan.Summary.Total.Files != 12 || len(scan.Summary.FailedFiles) != 1 {
+		t.Errorf("got %+v", scan)
+	}
+
+	if _, err := report.Load(strings.NewReader("{\"path\": 1}\n")); err == nil {
+		t.Error("no error on an invalid record")
+	}
+}
+
+func TestHTML(t *testing.T) {
+	scan, err := report.Load(strings.NewReader(results))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The older finding was downloaded to the flat loot path
+	dir := t.TempDir()
+	loot := spider.Config{LootDir: filepath.Join(dir, "loot"), Delimiter: "+"}
+	if err := os.MkdirAll(loot.LootDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(loot.LootDir, "10.0.0.5+C$+IT+notes.txt"), []byte("db password=Hunter2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	opts := report.Options{Title: "Scan", Generated: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Loot: loot, Dir: dir}
+	if err := report.HTML(&buf, scan, opts); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`<code class="snippet">db password=Hunter2</code>`, // From the reason, no rule to highlight
+		`<mark>api_key</mark>=x`,                           // Preset pattern
+		`href="loot/10.0.0.5&#43;C$&#43;IT&#43;notes.txt"`, // Relative to the report
+		`&lt;script&gt;alert(1)&lt;/script&gt;.txt`,
+		`data-severity="critical"`,
+		`big.pdf`,
+		`Generated 2024-05-01T12:00:00Z`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+	if strings.Contains(out, "<script>alert") || strings.Contains(out, "\x1b") {
+		t.Error("unescaped content in the report")
+	}
+	// Severity groups: critical before unrated within the share
+	if c, u := strings.Index(out, `data-severity="critical"`), strings.Index(out, `data-severity="unrated" data-rule="unknown"`); c < 0 || u < 0 || c > u {
+		t.Errorf("group order: critical at %d, unrated at %d", c, u)
+	}
+}
+
+func TestHTMLShortHash(t *testing.T) {
+	// Hand-edited files may hold a hash of any length
+	scan, err := report.Load(strings.NewReader(`{"path":"a.txt","reason":"x","sha256":"abc","host":"h","share":"s"}
+{"path":"b.txt","reason":"x","sha256":"0123456789abcdef","host":"h","share":"s"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := report.HTML(&buf, scan, report.Options{Title: "Scan"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`title="abc">abc</span>`, `title="0123456789abcdef">01234567</span>`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+}
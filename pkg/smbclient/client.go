@@ -10,13 +10,24 @@ import (
 type Session struct {
 	Session *smb2.Session
 	Conn    net.Conn
+
+	// Info is gathered while connecting (see sniffConn)
+	Info HostInfo
 }
 
 func NewSession(host, username, password, domain, hash, ccache, krbConfig string) (s *Session, err error) {
-	conn, err := net.Dial("tcp", host+":445")
+	// host may carry its own port ("fs01:4455", "[fe80::1]:445"); default to 445.
+	// JoinHostPort also brackets bare IPv6 literals.
+	addr := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		addr = net.JoinHostPort(host, "445")
+	}
+
+	tcpConn, err := net.Dial("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
+	conn := newSniffConn(tcpConn)
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -54,7 +65,10 @@ func NewSession(host, username, password, domain, hash, ccache, krbConfig string
 		return nil, err
 	}
 
-	return &Session{Session: session, Conn: conn}, nil
+	info := conn.Info()
+	info.lookupReverseDNS()
+
+	return &Session{Session: session, Conn: conn, Info: info}, nil
 }
 
 func (s *Session) ListShares() ([]string, error) {
@@ -0,0 +1,248 @@
+package smbclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// HostInfo describes a scanned host. Everything except ReverseDNS is read
+// passively off the wire during SMB negotiation and the NTLM challenge, so it
+// costs no extra requests.
+type HostInfo struct {
+	IP              string `json:"ip,omitempty"`
+	ReverseDNS      string `json:"reverse_dns,omitempty"`
+	NetBIOSName     string `json:"netbios_name,omitempty"`
+	NetBIOSDomain   string `json:"netbios_domain,omitempty"`
+	DNSName         string `json:"dns_name,omitempty"`
+	DNSDomain       string `json:"dns_domain,omitempty"`
+	Dialect         string `json:"smb_dialect,omitempty"`
+	SigningRequired bool   `json:"signing_required"`
+	OSBuild         string `json:"os_build,omitempty"`
+}
+
+// Name returns the most descriptive name known for the host (FS01.corp.local).
+func (h *HostInfo) Name() string {
+	switch {
+	case h.DNSName != "":
+		return h.DNSName
+	case h.NetBIOSName != "" && h.DNSDomain != "":
+		return h.NetBIOSName + "." + h.DNSDomain
+	case h.ReverseDNS != "":
+		return h.ReverseDNS
+	case h.NetBIOSName != "":
+		return h.NetBIOSName
+	}
+	return h.IP
+}
+
+// String is a one-line summary for logging.
+func (h *HostInfo) String() string {
+	parts := []string{h.Name()}
+	if h.NetBIOSDomain != "" {
+		parts = append(parts, "domain "+h.NetBIOSDomain)
+	}
+	if h.Dialect != "" {
+		parts = append(parts, "SMB "+h.Dialect)
+	}
+	if h.SigningRequired {
+		parts = append(parts, "signing required")
+	} else {
+		parts = append(parts, "signing not required")
+	}
+	if h.OSBuild != "" {
+		parts = append(parts, "build "+h.OSBuild)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// lookupReverseDNS fills ReverseDNS from the PTR record for IP, if any.
+func (h *HostInfo) lookupReverseDNS() {
+	if h.IP == "" {
+		return
+	}
+	names, err := net.LookupAddr(h.IP)
+	if err == nil && len(names) > 0 {
+		h.ReverseDNS = strings.TrimSuffix(names[0], ".")
+	}
+}
+
+const (
+	smb2CommandNegotiate    = 0x0000
+	smb2CommandSessionSetup = 0x0001
+	smb2HeaderSize          = 64
+
+	smb2SigningRequired = 0x0002
+)
+
+var smb2Dialects = map[uint16]string{
+	0x0202: "2.0.2",
+	0x0210: "2.1",
+	0x0300: "3.0",
+	0x0302: "3.0.2",
+	0x0311: "3.1.1",
+}
+
+var ntlmChallengeSig = []byte("NTLMSSP\x00\x02\x00\x00\x00")
+
+// sniffConn wraps the TCP connection handed to go-smb2 and parses the server's
+// NEGOTIATE and SESSION_SETUP responses as they are read. go-smb2 keeps these
+// details unexported, so this is the only way to get at them.
+// Sniffing stops at the first SESSION_SETUP response, NTLM or not, or after
+// maxSniff bytes.
+type sniffConn struct {
+	net.Conn
+
+	mu   sync.Mutex
+	buf  []byte
+	read int
+	done bool
+	info HostInfo
+}
+
+// maxSniff is how much of a session is looked at: the negotiate and session
+// setup responses fit well within it.
+const maxSniff = 64 * 1024
+
+func newSniffConn(conn net.Conn) *sniffConn {
+	c := &sniffConn{Conn: conn}
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		c.info.IP = addr.IP.String()
+	}
+	return c
+}
+
+func (c *sniffConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.feed(p[:n])
+	}
+	return n, err
+}
+
+// Info returns a copy of what has been gathered so far.
+func (c *sniffConn) Info() HostInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.info
+}
+
+// feed reassembles NetBIOS session frames (4 byte length prefix) and parses
+// each complete one.
+func (c *sniffConn) feed(b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done {
+		return
+	}
+	if c.read += len(b); c.read > maxSniff {
+		c.done, c.buf = true, nil
+		return
+	}
+
+	c.buf = append(c.buf, b...)
+	for len(c.buf) >= 4 {
+		size := int(binary.BigEndian.Uint32(c.buf[:4]) & 0x00ffffff)
+		if len(c.buf) < 4+size {
+			return
+		}
+		c.parseFrame(c.buf[4 : 4+size])
+		c.buf = c.buf[4+size:]
+		if c.done {
+			c.buf = nil
+			return
+		}
+	}
+}
+
+func (c *sniffConn) parseFrame(pkt []byte) {
+	if len(pkt) < smb2HeaderSize || !bytes.Equal(pkt[:4], []byte("\xfeSMB")) {
+		return
+	}
+
+	body := pkt[smb2HeaderSize:]
+	switch binary.LittleEndian.Uint16(pkt[12:14]) {
+	case smb2CommandNegotiate:
+		// StructureSize(2) SecurityMode(2) DialectRevision(2)
+		if len(body) < 6 {
+			return
+		}
+		c.info.SigningRequired = binary.LittleEndian.Uint16(body[2:4])&smb2SigningRequired != 0
+		dialect := binary.LittleEndian.Uint16(body[4:6])
+		if name, ok := smb2Dialects[dialect]; ok {
+			c.info.Dialect = name
+		} else {
+			c.info.Dialect = fmt.Sprintf("0x%04x", dialect)
+		}
+	case smb2CommandSessionSetup:
+		// Nothing to learn past this one (Kerberos has no challenge)
+		c.done = true
+		// The NTLM challenge is wrapped in SPNEGO; just look for the signature.
+		if idx := bytes.Index(body, ntlmChallengeSig); idx != -1 {
+			parseNTLMChallenge(body[idx:], &c.info)
+		}
+	}
+}
+
+// NTLM AV_PAIR ids (MS-NLMP 2.2.2.1)
+const (
+	avEOL             = 0
+	avNbComputerName  = 1
+	avNbDomainName    = 2
+	avDnsComputerName = 3
+	avDnsDomainName   = 4
+
+	ntlmNegotiateVersion = 0x02000000
+)
+
+// parseNTLMChallenge pulls the target info AV pairs and the OS version out of
+// an NTLM CHALLENGE_MESSAGE (MS-NLMP 2.2.1.2).
+func parseNTLMChallenge(msg []byte, info *HostInfo) {
+	if len(msg) < 48 {
+		return
+	}
+	flags := binary.LittleEndian.Uint32(msg[20:24])
+
+	tiLen := int(binary.LittleEndian.Uint16(msg[40:42]))
+	tiOff := int(binary.LittleEndian.Uint32(msg[44:48]))
+	if tiOff > 0 && tiOff+tiLen <= len(msg) {
+		ti := msg[tiOff : tiOff+tiLen]
+		for len(ti) >= 4 {
+			id := binary.LittleEndian.Uint16(ti[0:2])
+			l := int(binary.LittleEndian.Uint16(ti[2:4]))
+			if id == avEOL || 4+l > len(ti) {
+				break
+			}
+			val := decodeUTF16(ti[4 : 4+l])
+			switch id {
+			case avNbComputerName:
+				info.NetBIOSName = val
+			case avNbDomainName:
+				info.NetBIOSDomain = val
+			case avDnsComputerName:
+				info.DNSName = val
+			case avDnsDomainName:
+				info.DNSDomain = val
+			}
+			ti = ti[4+l:]
+		}
+	}
+
+	if flags&ntlmNegotiateVersion != 0 && len(msg) >= 56 {
+		major, minor := msg[48], msg[49]
+		build := binary.LittleEndian.Uint16(msg[50:52])
+		info.OSBuild = fmt.Sprintf("%d.%d.%d", major, minor, build)
+	}
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
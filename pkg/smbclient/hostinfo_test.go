@@ -0,0 +1,220 @@
+package smbclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// smb2Frame is a server response as read off the wire: NetBIOS session
+// header, SMB2 header, then body.
+func smb2Frame(command uint16, body []byte) []byte {
+	hdr := make([]byte, smb2HeaderSize)
+	copy(hdr, "\xfeSMB")
+	binary.LittleEndian.PutUint16(hdr[4:], smb2HeaderSize)
+	binary.LittleEndian.PutUint16(hdr[12:], command)
+	binary.LittleEndian.PutUint32(hdr[16:], 0x00000001) // SMB2_FLAGS_SERVER_TO_REDIR
+	pkt := append(hdr, body...)
+
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(pkt)))
+	return append(frame, pkt...)
+}
+
+// negotiateResponse is the start of an SMB2 NEGOTIATE response body
+// (MS-SMB2 2.2.4): StructureSize, SecurityMode, DialectRevision, then the
+// rest, which the sniffer does not read.
+func negotiateResponse(securityMode, dialect uint16) []byte {
+	body := make([]byte, 64)
+	binary.LittleEndian.PutUint16(body[0:], 65)
+	binary.LittleEndian.PutUint16(body[2:], securityMode)
+	binary.LittleEndian.PutUint16(body[4:], dialect)
+	return body
+}
+
+type avPair struct {
+	id  uint16
+	val string
+}
+
+// challengeMessage builds an NTLM CHALLENGE_MESSAGE (MS-NLMP 2.2.1.2) with
+// the target info pairs and, if version is set, the version field.
+func challengeMessage(pairs []avPair, version []byte) []byte {
+	var ti []byte
+	for _, p := range pairs {
+		val := utf16.Encode([]rune(p.val))
+		ti = binary.LittleEndian.AppendUint16(ti, p.id)
+		ti = binary.LittleEndian.AppendUint16(ti, uint16(2*len(val)))
+		for _, u := range val {
+			ti = binary.LittleEndian.AppendUint16(ti, u)
+		}
+	}
+	ti = append(ti, 0, 0, 0, 0) // MsvAvEOL
+
+	msg := make([]byte, 56)
+	copy(msg, ntlmChallengeSig)
+	flags := uint32(0x00808205) // Unicode, NTLM, target info
+	if version != nil {
+		flags |= ntlmNegotiateVersion
+		copy(msg[48:], version)
+	}
+	binary.LittleEndian.PutUint32(msg[20:], flags)
+	binary.LittleEndian.PutUint16(msg[40:], uint16(len(ti)))
+	binary.LittleEndian.PutUint16(msg[42:], uint16(len(ti)))
+	binary.LittleEndian.PutUint32(msg[44:], 56)
+	return append(msg, ti...)
+}
+
+var (
+	serverPairs = []avPair{
+		{avNbDomainName, "CORP"},
+		{avNbComputerName, "FS01"},
+		{avDnsDomainName, "corp.local"},
+		{avDnsComputerName, "FS01.corp.local"},
+		{7, "\x00\x00\x00\x00"}, // MsvAvTimestamp, ignored
+	}
+	// Windows Server 2022: 10.0 build 20348, NTLM revision 15
+	serverVersion = []byte{10, 0, 0x7c, 0x4f, 0, 0, 0, 15}
+)
+
+// sessionSetupResponse wraps an NTLM message in an SMB2 SESSION_SETUP
+// response body, behind SPNEGO-like bytes the sniffer skips.
+func sessionSetupResponse(ntlm []byte) []byte {
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint16(body[0:], 9)
+	binary.LittleEndian.PutUint16(body[4:], smb2HeaderSize+8)
+	binary.LittleEndian.PutUint16(body[6:], uint16(16+len(ntlm)))
+	body = append(body, "\xa1\x81\x00\x30\x81\x00\xa0\x03\x0a\x01\x01\xa2\x04\x04\x81\x00"...)
+	return append(body, ntlm...)
+}
+
+func TestSniffConn(t *testing.T) {
+	stream := append(smb2Frame(smb2CommandNegotiate, negotiateResponse(0x0003, 0x0311)),
+		smb2Frame(smb2CommandSessionSetup, sessionSetupResponse(challengeMessage(serverPairs, serverVersion)))...)
+	// Anything after the challenge is not parsed
+	stream = append(stream, smb2Frame(smb2CommandNegotiate, negotiateResponse(0, 0x0202))...)
+
+	want := HostInfo{
+		NetBIOSName:     "FS01",
+		NetBIOSDomain:   "CORP",
+		DNSName:         "FS01.corp.local",
+		DNSDomain:       "corp.local",
+		Dialect:         "3.1.1",
+		SigningRequired: true,
+		OSBuild:         "10.0.20348",
+	}
+
+	// Frames split across reads at every possible size
+	for _, chunk := range []int{1, 3, 7, 64, len(stream)} {
+		c := &sniffConn{}
+		for b := stream; len(b) > 0; {
+			n := min(chunk, len(b))
+			c.feed(b[:n])
+			b = b[n:]
+		}
+		if got := c.Info(); got != want {
+			t.Errorf("chunks of %d: got %+v, want %+v", chunk, got, want)
+		}
+		if !c.done || c.buf != nil {
+			t.Errorf("chunks of %d: sniffing not stopped after the challenge", chunk)
+		}
+	}
+}
+
+func TestParseFrame(t *testing.T) {
+	negotiate := smb2Frame(smb2CommandNegotiate, negotiateResponse(0x0001, 0x0210))[4:]
+
+	for _, tc := range []struct {
+		name string
+		pkt  []byte
+		want HostInfo
+	}{
+		{"negotiate", negotiate, HostInfo{Dialect: "2.1"}},
+		{"unknown dialect", smb2Frame(smb2CommandNegotiate, negotiateResponse(0x0002, 0x02ff))[4:], HostInfo{Dialect: "0x02ff", SigningRequired: true}},
+		{"truncated body", negotiate[:smb2HeaderSize+5], HostInfo{}},
+		{"truncated header", negotiate[:smb2HeaderSize-1], HostInfo{}},
+		{"SMB1", append([]byte("\xffSMB"), negotiate[4:]...), HostInfo{}},
+		{"no challenge", smb2Frame(smb2CommandSessionSetup, sessionSetupResponse([]byte("NTLMSSP\x00\x03\x00\x00\x00")))[4:], HostInfo{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &sniffConn{}
+			c.parseFrame(tc.pkt)
+			if c.info != tc.want {
+				t.Errorf("got %+v, want %+v", c.info, tc.want)
+			}
+		})
+	}
+}
+
+func TestSniffConnOversizeFrame(t *testing.T) {
+	// A frame claiming 16 MiB is buffered, never parsed
+	frame := smb2Frame(smb2CommandNegotiate, negotiateResponse(0x0003, 0x0311))
+	binary.BigEndian.PutUint32(frame, 0x00ffffff)
+	c := &sniffConn{}
+	c.feed(frame)
+	if got := c.Info(); got != (HostInfo{}) {
+		t.Errorf("got %+v from an incomplete frame", got)
+	}
+	// Nor kept past maxSniff bytes
+	c.feed(make([]byte, maxSniff))
+	if !c.done || c.buf != nil {
+		t.Errorf("still sniffing after %d bytes", c.read)
+	}
+}
+
+func TestSniffConnKerberos(t *testing.T) {
+	// A Kerberos session setup has no NTLM challenge; what follows (file
+	// reads) is not buffered
+	stream := append(smb2Frame(smb2CommandNegotiate, negotiateResponse(0x0001, 0x0302)),
+		smb2Frame(smb2CommandSessionSetup, sessionSetupResponse([]byte("\xa1\x14\x30\x12\xa0\x03\x0a\x01\x00")))...)
+	stream = append(stream, smb2Frame(smb2CommandNegotiate, negotiateResponse(0, 0x0202))[:10]...)
+
+	c := &sniffConn{}
+	c.feed(stream)
+	if got, want := c.Info(), (HostInfo{Dialect: "3.0.2"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if !c.done || c.buf != nil {
+		t.Error("sniffing not stopped after the session setup response")
+	}
+}
+
+func TestParseNTLMChallenge(t *testing.T) {
+	full := challengeMessage(serverPairs, serverVersion)
+	all := HostInfo{NetBIOSName: "FS01", NetBIOSDomain: "CORP", DNSName: "FS01.corp.local", DNSDomain: "corp.local", OSBuild: "10.0.20348"}
+
+	withTI := func(length uint16, off uint32) []byte {
+		msg := bytes.Clone(full)
+		binary.LittleEndian.PutUint16(msg[40:], length)
+		binary.LittleEndian.PutUint32(msg[44:], off)
+		return msg
+	}
+	// Second pair ("FS01") claims more bytes than the list holds
+	oversizePair := bytes.Clone(full)
+	binary.LittleEndian.PutUint16(oversizePair[56+4+8+2:], 0xfff0)
+
+	for _, tc := range []struct {
+		name string
+		msg  []byte
+		want HostInfo
+	}{
+		{"full", full, all},
+		{"no version", challengeMessage(serverPairs, nil), HostInfo{NetBIOSName: "FS01", NetBIOSDomain: "CORP", DNSName: "FS01.corp.local", DNSDomain: "corp.local"}},
+		{"no pairs", challengeMessage(nil, serverVersion), HostInfo{OSBuild: "10.0.20348"}},
+		{"truncated header", full[:47], HostInfo{}},
+		{"truncated version", full[:52], HostInfo{}},
+		{"truncated target info", full[:len(full)-8], HostInfo{OSBuild: "10.0.20348"}},
+		{"oversize target info", withTI(0xffff, 56), HostInfo{OSBuild: "10.0.20348"}},
+		{"target info offset past the end", withTI(8, 0xfffffff0), HostInfo{OSBuild: "10.0.20348"}},
+		{"oversize pair", oversizePair, HostInfo{NetBIOSDomain: "CORP", OSBuild: "10.0.20348"}},
+		{"truncated pair", challengeMessage([]avPair{{avNbComputerName, "FS01"}}, nil)[:56+4+7], HostInfo{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got HostInfo
+			parseNTLMChallenge(tc.msg, &got)
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package spider
+
+import (
+	"crypto/tls"
+	"io/fs"
+	"net"
+	"net/textproto"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// maxIdleFTPConns caps how many idle data-capable connections FTPFS keeps.
+const maxIdleFTPConns = 8
+
+// FTPConfig describes how to reach an FTP server. An empty User logs in
+// anonymously.
+type FTPConfig struct {
+	Addr     string // host:port, port defaults to 21
+	User     string
+	Password string
+
+	// ExplicitTLS upgrades the control and data connections with AUTH TLS.
+	// Certificates are not verified.
+	ExplicitTLS bool
+}
+
+// FTPFS spiders an FTP server. Listings use MLSD when the server advertises
+// it and fall back to parsing LIST output otherwise. All transfers are passive
+// (EPSV, then PASV).
+//
+// An FTP control connection can only run one transfer at a time, so every
+// open file gets its own connection from a small pool.
+type FTPFS struct {
+	cfg     FTPConfig
+	noMLSD  atomic.Bool // Set by the walker, read when dialing for Open
+	mu      sync.Mutex
+	idle    []*ftp.ServerConn
+	walker  *ftp.ServerConn
+	entries sync.Map // path -> *ftp.Entry, filled while walking (used by Stat)
+}
+
+// DialFTP connects and logs in once to validate the credentials.
+func DialFTP(cfg FTPConfig) (*FTPFS, error) {
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		cfg.Addr = net.JoinHostPort(cfg.Addr, "21")
+	}
+	if cfg.User == "" {
+		cfg.User = "anonymous"
+		if cfg.Password == "" {
+			cfg.Password = "anonymous@"
+		}
+	}
+
+	f := &FTPFS{cfg: cfg}
+	conn, err := f.dial()
+	if err != nil {
+		return nil, err
+	}
+	f.walker = conn
+	return f, nil
+}
+
+func (f *FTPFS) dial() (*ftp.ServerConn, error) {
+	opts := []ftp.DialOption{
+		ftp.DialWithTimeout(10 * time.Second),
+		ftp.DialWithDisabledMLSD(f.noMLSD.Load()),
+	}
+	if f.cfg.ExplicitTLS {
+		host, _, _ := net.SplitHostPort(f.cfg.Addr)
+		opts = append(opts, ftp.DialWithExplicitTLS(&tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+		}))
+	}
+
+	conn, err := ftp.Dial(f.cfg.Addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Login(f.cfg.User, f.cfg.Password); err != nil {
+		conn.Quit()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// get returns an idle connection or dials a new one.
+func (f *FTPFS) get() (*ftp.ServerConn, error) {
+	f.mu.Lock()
+	if n := len(f.idle); n > 0 {
+		conn := f.idle[n-1]
+		f.idle = f.idle[:n-1]
+		f.mu.Unlock()
+		return conn, nil
+	}
+	f.mu.Unlock()
+	return f.dial()
+}
+
+// put hands a connection back to the pool (or closes it if the pool is full).
+func (f *FTPFS) put(conn *ftp.ServerConn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.idle) >= maxIdleFTPConns {
+		conn.Quit()
+		return
+	}
+	f.idle = append(f.idle, conn)
+}
+
+// list runs MLSD/LIST on the walker connection. If MLSD is advertised but
+// rejected, the walker is redialed with MLSD disabled and the listing retried.
+func (f *FTPFS) list(dir string) ([]*ftp.Entry, error) {
+	f.mu.Lock()
+	walker := f.walker
+	f.mu.Unlock()
+	if walker == nil {
+		return nil, net.ErrClosed
+	}
+
+	entries, err := walker.List(dir)
+	if err == nil || f.noMLSD.Load() {
+		return entries, err
+	}
+
+	if tpErr, ok := err.(*textproto.Error); ok && (tpErr.Code == ftp.StatusBadCommand || tpErr.Code == ftp.StatusNotImplemented) {
+		f.noMLSD.Store(true)
+		conn, dialErr := f.dial()
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		// Close may have run meanwhile, and already logged the old one out
+		f.mu.Lock()
+		if f.walker != walker {
+			f.mu.Unlock()
+			conn.Quit()
+			return nil, net.ErrClosed
+		}
+		f.walker = conn
+		f.mu.Unlock()
+		walker.Quit()
+		return conn.List(dir)
+	}
+	return nil, err
+}
+
+func (f *FTPFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	if root == "" {
+		root = "/"
+	}
+	return f.walk(root, fn)
+}
+
+func (f *FTPFS) walk(dir string, fn fs.WalkDirFunc) error {
+	entries, err := f.list(dir)
+	if err != nil {
+		return fn(dir, nil, err)
+	}
+
+	for _, e := range entries {
+		if e.Name == "." || e.Name == ".." {
+			continue
+		}
+
+		fullPath := path.Join(dir, e.Name)
+		f.entries.Store(fullPath, e)
+		info := &ftpFileInfo{entry: e}
+
+		if err := fn(fullPath, fs.FileInfoToDirEntry(info), nil); err != nil {
+			if err == fs.SkipDir {
+				if e.Type == ftp.EntryTypeFolder {
+					continue
+				}
+				return nil
+			}
+			return err
+		}
+
+		// Links are not followed
+		if e.Type == ftp.EntryTypeFolder {
+			if err := f.walk(fullPath, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *FTPFS) Open(name string) (fs.File, error) {
+	conn, err := f.get()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := conn.Retr(name)
+	if err != nil {
+		// Control connection is still usable after a 5xx on RETR
+		if _, ok := err.(*textproto.Error); ok {
+			f.put(conn)
+		} else {
+			conn.Quit()
+		}
+		return nil, err
+	}
+
+	entry := &ftp.Entry{Name: path.Base(name), Type: ftp.EntryTypeFile}
+	if e, ok := f.entries.Load(name); ok {
+		entry = e.(*ftp.Entry)
+	}
+
+	return &ftpFile{Response: resp, conn: conn, fs: f, info: &ftpFileInfo{entry: entry}}, nil
+}
+
+// Close logs out of every pooled connection.
+func (f *FTPFS) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.idle {
+		conn.Quit()
+	}
+	f.idle = nil
+	if f.walker != nil {
+		f.walker.Quit()
+		f.walker = nil
+	}
+}
+
+// ftpFile is a RETR data stream. Closing it returns the control connection to
+// the pool.
+type ftpFile struct {
+	*ftp.Response
+	conn *ftp.ServerConn
+	fs   *FTPFS
+	info *ftpFileInfo
+}
+
+func (f *ftpFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *ftpFile) Close() error {
+	err := f.Response.Close()
+	if err != nil {
+		// Transfer did not finish cleanly; don't reuse the connection
+		f.conn.Quit()
+		return err
+	}
+	f.fs.put(f.conn)
+	return nil
+}
+
+// ftpFileInfo adapts an ftp.Entry to fs.FileInfo.
+type ftpFileInfo struct {
+	entry *ftp.Entry
+}
+
+func (i *ftpFileInfo) Name() string       { return i.entry.Name }
+func (i *ftpFileInfo) Size() int64        { return int64(i.entry.Size) }
+func (i *ftpFileInfo) ModTime() time.Time { return i.entry.Time }
+func (i *ftpFileInfo) IsDir() bool        { return i.entry.Type == ftp.EntryTypeFolder }
+func (i *ftpFileInfo) Sys() any           { return i.entry }
+
+func (i *ftpFileInfo) Mode() fs.FileMode {
+	switch i.entry.Type {
+	case ftp.EntryTypeFolder:
+		return fs.ModeDir | 0755
+	case ftp.EntryTypeLink:
+		return fs.ModeSymlink | 0777
+	}
+	return 0644
+}
@@ -0,0 +1,278 @@
+package spider_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// fakeFTPServer is a minimal FTP server (passive mode only) serving a local
+// directory. It understands just enough of RFC 959/3659 for FTPFS.
+type fakeFTPServer struct {
+	root string
+	mlsd bool // advertise and answer MLSD; otherwise only LIST
+	// brokenMLSD advertises MLSD but rejects it in directories named
+	// "deep", so FTPFS falls back to LIST while files are being opened
+	brokenMLSD bool
+	// onConnect, if set, runs before the greeting of each connection
+	onConnect func()
+}
+
+func (s *fakeFTPServer) start(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (s *fakeFTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, a ...any) {
+		fmt.Fprintf(conn, format+"\r\n", a...)
+	}
+
+	var data net.Listener
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+	// transfer accepts the pending passive connection and runs fn on it
+	transfer := func(fn func(w io.Writer)) {
+		if data == nil {
+			reply("425 Use PASV first")
+			return
+		}
+		dc, err := data.Accept()
+		data.Close()
+		data = nil
+		if err != nil {
+			reply("425 Can't open data connection")
+			return
+		}
+		reply("150 Opening data connection")
+		fn(dc)
+		dc.Close()
+		reply("226 Transfer complete")
+	}
+
+	if s.onConnect != nil {
+		s.onConnect()
+	}
+	reply("220 fake ftp ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		local := filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+arg)))
+
+		switch strings.ToUpper(cmd) {
+		case "USER":
+			reply("331 Password required")
+		case "PASS":
+			reply("230 Logged in")
+		case "FEAT":
+			if s.mlsd || s.brokenMLSD {
+				reply("211-Features:\r\n MLST type*;size*;modify*;\r\n211 End")
+			} else {
+				reply("211-Features:\r\n SIZE\r\n211 End")
+			}
+		case "TYPE", "OPTS":
+			reply("200 OK")
+		case "EPSV":
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply("425 Cannot listen")
+				continue
+			}
+			data = ln
+			reply("229 Entering Extended Passive Mode (|||%d|)", ln.Addr().(*net.TCPAddr).Port)
+		case "MLSD":
+			if !s.mlsd && (!s.brokenMLSD || filepath.Base(local) == "deep") {
+				reply("500 Unknown command")
+				continue
+			}
+			entries, err := os.ReadDir(local)
+			if err != nil {
+				reply("550 No such directory")
+				continue
+			}
+			transfer(func(w io.Writer) {
+				for _, e := range entries {
+					info, _ := e.Info()
+					typ := "file"
+					if e.IsDir() {
+						typ = "dir"
+					}
+					fmt.Fprintf(w, "type=%s;size=%d;modify=%s; %s\r\n", typ, info.Size(), info.ModTime().UTC().Format("20060102150405"), e.Name())
+				}
+			})
+		case "LIST":
+			entries, err := os.ReadDir(local)
+			if err != nil {
+				reply("550 No such directory")
+				continue
+			}
+			transfer(func(w io.Writer) {
+				for _, e := range entries {
+					info, _ := e.Info()
+					perm := "-rw-r--r--"
+					if e.IsDir() {
+						perm = "drwxr-xr-x"
+					}
+					fmt.Fprintf(w, "%s 1 ftp ftp %d %s %s\r\n", perm, info.Size(), info.ModTime().Format("Jan _2 15:04"), e.Name())
+				}
+			})
+		case "RETR":
+			f, err := os.Open(local)
+			if err != nil {
+				reply("550 No such file")
+				continue
+			}
+			transfer(func(w io.Writer) { io.Copy(w, f) })
+			f.Close()
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSpiderFTP(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		mlsd, brokenMLSD bool
+	}{
+		{"mlsd", true, false},
+		{"list", false, false},
+		{"broken-mlsd", false, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			remote := filepath.Join(tmpDir, "remote")
+			files := map[string]string{
+				"backup/router.cfg":  "enable secret 5 $1$abcd; password cisco",
+				"backup/readme.txt":  "nothing to see",
+				"pub/deep/creds.ini": "[db]\npassword = hunter2",
+			}
+			for rel, content := range files {
+				full := filepath.Join(remote, rel)
+				if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			srv := &fakeFTPServer{root: remote, mlsd: tc.mlsd, brokenMLSD: tc.brokenMLSD}
+			addr := srv.start(t)
+
+			// Anonymous login
+			fsys, err := spider.DialFTP(spider.FTPConfig{Addr: addr})
+			if err != nil {
+				t.Fatalf("DialFTP: %v", err)
+			}
+			defer fsys.Close()
+
+			m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.ExcludeRegex = nil
+
+			lootDir := filepath.Join(tmpDir, "loot")
+			cfg := spider.Config{
+				Threads:    4,
+				LootDir:    lootDir,
+				Structured: true,
+				Host:       "ftp-host",
+				Share:      "root",
+			}
+			s := spider.NewSpider(cfg, m, fsys, utils.NewDeduplicator(), nil)
+			s.Walk("/")
+
+			for _, want := range []string{"backup/router.cfg", "pub/deep/creds.ini"} {
+				b, err := os.ReadFile(filepath.Join(lootDir, "ftp-host", "root", want))
+				if err != nil {
+					t.Errorf("expected %s in loot: %v", want, err)
+					continue
+				}
+				if string(b) != files[want] {
+					t.Errorf("loot %s has wrong content: %q", want, b)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(lootDir, "ftp-host", "root", "backup/readme.txt")); err == nil {
+				t.Error("readme.txt should not have matched")
+			}
+		})
+	}
+}
+
+// Closing the FS while the walker is redialed for the LIST fallback must
+// neither race nor leave the walk hanging.
+func TestFTPCloseDuringWalk(t *testing.T) {
+	remote := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(remote, "pub", "deep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The second connection is the redial: close the FS before it is up
+	var conns atomic.Int32
+	var fsysp atomic.Pointer[spider.FTPFS]
+	srv := &fakeFTPServer{root: remote, brokenMLSD: true, onConnect: func() {
+		if conns.Add(1) == 2 {
+			fsysp.Load().Close()
+		}
+	}}
+	fsys, err := spider.DialFTP(spider.FTPConfig{Addr: srv.start(t)})
+	if err != nil {
+		t.Fatalf("DialFTP: %v", err)
+	}
+	fsysp.Store(fsys)
+
+	var paths []string
+	err = fsys.WalkDir("/", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			p += ": " + err.Error()
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("WalkDir: %v", err)
+	}
+	if want := []string{"/pub", "/pub/deep", "/pub/deep: " + net.ErrClosed.Error()}; !slices.Equal(paths, want) {
+		t.Errorf("walked %q, want %q", paths, want)
+	}
+}
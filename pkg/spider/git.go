@@ -0,0 +1,287 @@
+package spider
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/0xSterny/spuderman/pkg/extractor"
+	"github.com/0xSterny/spuderman/pkg/utils"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// GitInfo locates a finding in git history.
+type GitInfo struct {
+	Commit string `json:"commit"`
+	Author string `json:"author"`
+	Date   string `json:"date"` // Author date (RFC 3339, UTC)
+	Path   string `json:"path"` // Path inside the repository
+}
+
+// isGitDir checks that dir has the layout of a git directory, a .git
+// directory or a bare repo whatever its name: a HEAD file naming a ref or a
+// commit, and objects and refs directories. Most directories fail on HEAD,
+// a single lookup; only those with one are listed.
+func (s *Spider) isGitDir(dir string) bool {
+	f, err := s.FS.Open(dir + "/HEAD")
+	if err != nil {
+		return false
+	}
+	head := make([]byte, 64)
+	n, _ := io.ReadFull(f, head)
+	f.Close()
+	if !isGitHead(head[:n]) {
+		return false
+	}
+
+	var objects, refs bool
+	s.FS.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.Trim(strings.TrimPrefix(p, dir), `/\`) == "" || !d.IsDir() {
+			return nil
+		}
+		switch d.Name() {
+		case "objects":
+			objects = true
+		case "refs":
+			refs = true
+		}
+		return fs.SkipDir
+	})
+	return objects && refs
+}
+
+// isGitHead reports whether b is the start of a HEAD file: a symbolic ref,
+// or a detached SHA-1 or SHA-256 commit id.
+func isGitHead(b []byte) bool {
+	if bytes.HasPrefix(b, []byte("ref: refs/")) {
+		return true
+	}
+	id := bytes.TrimSpace(b)
+	if len(id) != 40 && len(id) != 64 {
+		return false
+	}
+	_, err := hex.Decode(make([]byte, len(id)/2), id)
+	return err == nil
+}
+
+// scanGitHistory runs the filename and content rules on every blob of every
+// commit in the repository at dir (a .git directory or a bare repo). Blobs
+// are checked once, attributed to the first commit listing them in object
+// store order (not necessarily the oldest one in history).
+func (s *Spider) scanGitHistory(dir string) {
+	utils.LogInfo("Scanning git history: //%s/%s/%s", s.Config.Host, s.Config.Share, dir)
+
+	local := dir
+	if _, ok := s.FS.(*LocalFS); !ok {
+		// go-git needs random access to pack files, so take a local copy
+		tmp, err := os.MkdirTemp("", "spuderman-git-")
+		if err != nil {
+			utils.LogError("Failed to create temp dir for %s: %v", dir, err)
+			return
+		}
+		defer os.RemoveAll(tmp)
+
+		if err := s.copyTree(dir, tmp); errors.Is(err, errGitTooLarge) {
+			utils.LogWarning("Skipping git dir //%s/%s/%s: larger than %s (--max-git-size)", s.Config.Host, s.Config.Share, dir, utils.FormatBytes(s.Config.MaxGitSize))
+			return
+		} else if err != nil {
+			utils.LogWarning("Failed to copy git dir //%s/%s/%s: %v", s.Config.Host, s.Config.Share, dir, err)
+			return
+		}
+		local = tmp
+	}
+
+	repo, err := git.PlainOpen(local)
+	if err != nil {
+		utils.LogWarning("Failed to open git repo //%s/%s/%s: %v", s.Config.Host, s.Config.Share, dir, err)
+		return
+	}
+
+	// CommitObjects also returns commits no ref points to anymore
+	commits, err := repo.CommitObjects()
+	if err != nil {
+		utils.LogWarning("Failed to list commits in //%s/%s/%s: %v", s.Config.Host, s.Config.Share, dir, err)
+		return
+	}
+	defer commits.Close()
+
+	seen := make(map[plumbing.Hash]bool)
+	err = commits.ForEach(func(c *object.Commit) error {
+		files, err := c.Files()
+		if err != nil {
+			utils.LogDebug("Failed to read tree of %s: %v", c.Hash, err)
+			return nil
+		}
+		defer files.Close()
+
+		return files.ForEach(func(f *object.File) error {
+			if seen[f.Hash] {
+				return nil
+			}
+			seen[f.Hash] = true
+			s.checkGitBlob(dir, c, f)
+			return nil
+		})
+	})
+	if err != nil {
+		utils.LogWarning("Error walking history of //%s/%s/%s: %v", s.Config.Host, s.Config.Share, dir, err)
+	}
+}
+
+var errGitTooLarge = errors.New("git dir too large")
+
+// copyTree copies everything under dir on s.FS into local, up to
+// Config.MaxGitSize bytes in total (errGitTooLarge past it).
+func (s *Spider) copyTree(dir, local string) error {
+	left := s.Config.MaxGitSize
+	return s.FS.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimLeft(strings.TrimPrefix(p, dir), `/\`)
+		if rel == "" {
+			return nil
+		}
+		dest := filepath.Join(local, filepath.FromSlash(strings.ReplaceAll(rel, `\`, "/")))
+		if d.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+
+		// Checked on the listed size first, so nothing is read from a
+		// repo that is obviously too large
+		if info, err := d.Info(); err == nil && s.Config.MaxGitSize > 0 && info.Size() > left {
+			return errGitTooLarge
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		src, err := s.FS.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		dst, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		defer dst.Close()
+		if s.Config.MaxGitSize <= 0 {
+			_, err = io.Copy(dst, src)
+			return err
+		}
+		n, err := io.Copy(dst, io.LimitReader(src, left+1))
+		if left -= n; left < 0 {
+			return errGitTooLarge
+		}
+		return err
+	})
+}
+
+func (s *Spider) checkGitBlob(dir string, c *object.Commit, f *object.File) {
+	// Reported path: <git dir>@<commit>/<path in repo>
+	vpath := fmt.Sprintf("%s@%s/%s", dir, c.Hash.String()[:12], f.Name)
+	defer s.recoverFile(vpath)
+
+	// Default exclusions contain ".git", so only test the in-repo path
+	s.count(func(st *ShareStats) { st.Files++ })
+	switch {
+	case s.Matcher.CheckExclude(f.Name):
+		s.countSkip(SkipExcluded)
+		return
+	case s.Matcher.CheckBlacklist(vpath):
+		s.countSkip(SkipBlacklisted)
+		return
+	case !s.Matcher.CheckDir(f.Name):
+		s.countSkip(SkipDirname)
+		return
+	case !s.Matcher.CheckExtension(filepath.Base(f.Name)):
+		s.countSkip(SkipExtension)
+		return
+	case s.Config.MaxFileSize > 0 && f.Size > s.Config.MaxFileSize:
+		s.countSkip(SkipSize)
+		return
+	}
+
+	hasNameTerm := len(s.Matcher.Config.Filenames) > 0
+	hasContentTerm := len(s.Matcher.Config.Content) > 0
+
+	if !hasNameTerm && !hasContentTerm {
+		s.handleGitMatch(DownloadJob{Path: vpath, Reason: "Extension/All", Rule: RuleAll}, c, f)
+		return
+	}
+
+	if rule, ok := s.Matcher.MatchFilename(filepath.Base(f.Name)); hasNameTerm && ok {
+		s.handleGitMatch(DownloadJob{Path: vpath, Reason: "Filename", Rule: rule}, c, f)
+		return
+	}
+
+	if hasContentTerm {
+		r, err := f.Reader()
+		if err != nil {
+			return
+		}
+		defer r.Close()
+
+		var read byteCounter
+		defer func() {
+			s.count(func(st *ShareStats) {
+				st.Scanned++
+				st.BytesRead += read.n.Load()
+			})
+		}()
+
+		// Same extractor selection and size limit as for regular files
+		br := bufio.NewReader(read.reader(r))
+		head, _ := br.Peek(extractor.SniffSize)
+		doc, err := extractor.ExtractDocument(extractor.GetExtractorFor(f.Name, head), io.LimitReader(br, extractor.MaxInputSize), f.Name)
+		s.logExtraction(vpath, doc, err)
+		if err != nil {
+			return
+		}
+
+		if m, ok := s.matchContent(doc.Text); ok {
+			s.handleGitMatch(m.job(vpath, doc.Metadata), c, f)
+		}
+	}
+}
+
+// handleGitMatch reports (and downloads) a blob right away: there is no
+// listing entry to queue it with.
+func (s *Spider) handleGitMatch(job DownloadJob, c *object.Commit, f *object.File) {
+	vpath := job.Path
+	utils.LogSuccess("Match found in git history (%s): //%s/%s/%s", job.Reason, s.Config.Host, s.Config.Share, vpath)
+
+	result := s.jobResult(job)
+	result.Size = f.Size
+	result.Git = &GitInfo{
+		Commit: c.Hash.String(),
+		Author: fmt.Sprintf("%s <%s>", c.Author.Name, c.Author.Email),
+		Date:   c.Author.When.UTC().Format(time.RFC3339),
+		Path:   f.Name,
+	}
+
+	if !s.Config.NoDownload {
+		if r, err := f.Reader(); err == nil {
+			result.Hash, result.LootPath, err = s.saveLoot(vpath, r)
+			if err != nil {
+				utils.LogDebug("Download failed: %v", err)
+			}
+			r.Close()
+		}
+	}
+	s.report(result)
+}
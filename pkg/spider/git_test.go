@@ -0,0 +1,256 @@
+package spider_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	nfshelper "github.com/willscott/go-nfs/helpers"
+	"github.com/willscott/go-nfs/helpers/memfs"
+
+	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+type collectReporter struct {
+	mu      sync.Mutex
+	results []spider.MatchResult
+}
+
+func (r *collectReporter) Report(m spider.MatchResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, m)
+}
+
+func (r *collectReporter) Close() {}
+
+// copyingFS hides the *LocalFS type so the spider treats it as remote.
+type copyingFS struct {
+	*spider.LocalFS
+}
+
+// initGitRepo creates a repository whose history holds config/db.yml, a
+// secret since removed.
+func initGitRepo(t *testing.T) string {
+	t.Helper()
+	repoDir := t.TempDir()
+	repo, err := git.PlainInit(repoDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func(msg string) {
+		t.Helper()
+		if _, err := wt.Add("."); err != nil {
+			t.Fatal(err)
+		}
+		_, err := wt.Commit(msg, &git.CommitOptions{
+			All:    true,
+			Author: &object.Signature{Name: "Dev", Email: "dev@corp.local", When: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	secret := filepath.Join(repoDir, "config", "db.yml")
+	os.MkdirAll(filepath.Dir(secret), 0755)
+	os.WriteFile(secret, []byte("password: hunter2\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "README.md"), []byte("hello\n"), 0644)
+	commit("add config")
+	// The README change creates a second commit holding the same db.yml blob
+	os.WriteFile(filepath.Join(repoDir, "README.md"), []byte("hello again\n"), 0644)
+	commit("update readme")
+	os.Remove(secret)
+	commit("remove secret")
+	return repoDir
+}
+
+func TestSpiderGitHistory(t *testing.T) {
+	repoDir := initGitRepo(t)
+
+	for name, fsys := range map[string]spider.FileSystem{
+		"local":  &spider.LocalFS{},
+		"copied": copyingFS{&spider.LocalFS{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.ExcludeRegex = nil
+
+			rep := &collectReporter{}
+			cfg := spider.Config{Threads: 2, NoDownload: true, GitHistory: true}
+			s := spider.NewSpider(cfg, m, fsys, utils.NewDeduplicator(), rep)
+			s.Walk(repoDir)
+
+			if len(rep.results) != 1 {
+				t.Fatalf("got %d results, want 1 (blob deduplicated): %+v", len(rep.results), rep.results)
+			}
+			g := rep.results[0].Git
+			if g == nil {
+				t.Fatal("result has no git info")
+			}
+			if g.Path != "config/db.yml" || g.Author != "Dev <dev@corp.local>" || g.Date != "2023-05-01T12:00:00Z" || len(g.Commit) != 40 {
+				t.Errorf("unexpected git info: %+v", g)
+			}
+		})
+	}
+}
+
+// Remote repos above MaxGitSize are not copied nor scanned.
+func TestSpiderGitHistoryTooLarge(t *testing.T) {
+	repoDir := initGitRepo(t)
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	rep := &collectReporter{}
+	cfg := spider.Config{Threads: 2, NoDownload: true, GitHistory: true, MaxGitSize: 100}
+	s := spider.NewSpider(cfg, m, copyingFS{&spider.LocalFS{}}, utils.NewDeduplicator(), rep)
+	s.Walk(repoDir)
+
+	if len(rep.results) != 0 {
+		t.Errorf("got %d results, want none: %+v", len(rep.results), rep.results)
+	}
+}
+
+// On backends with their own walker, skipping the .git directory must not
+// skip the entries listed after it.
+func TestSpiderGitHistoryNFS(t *testing.T) {
+	repoDir := initGitRepo(t)
+	os.WriteFile(filepath.Join(repoDir, "notes.txt"), []byte("password=x\n"), 0644)
+
+	mem := memfs.New()
+	err := filepath.WalkDir(repoDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(repoDir, p)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return util.WriteFile(mem, filepath.ToSlash(filepath.Join("repo", rel)), data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := startNFSServer(t, nfshelper.NewNullAuthHandler(mem))
+	fsys, err := spider.DialNFS(spider.NFSConfig{Host: "127.0.0.1", Port: port}, "/srv")
+	if err != nil {
+		t.Fatalf("DialNFS: %v", err)
+	}
+	defer fsys.Close()
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	rep := &collectReporter{}
+	cfg := spider.Config{Threads: 2, NoDownload: true, GitHistory: true}
+	s := spider.NewSpider(cfg, m, fsys, utils.NewDeduplicator(), rep)
+	s.Walk("/")
+
+	var paths []string
+	for _, r := range rep.results {
+		if r.Git != nil {
+			paths = append(paths, "git:"+r.Git.Path)
+		} else {
+			paths = append(paths, r.Path)
+		}
+	}
+	slices.Sort(paths)
+	if want := []string{"/srv/repo/notes.txt", "git:config/db.yml"}; !slices.Equal(paths, want) {
+		t.Errorf("got %q, want %q", paths, want)
+	}
+}
+
+// Bare repos are found by their layout, whatever their name.
+func TestSpiderGitHistoryBare(t *testing.T) {
+	root := t.TempDir()
+	if _, err := git.PlainClone(filepath.Join(root, "backup"), true, &git.CloneOptions{URL: initGitRepo(t)}); err != nil {
+		t.Fatal(err)
+	}
+	// A lookalike: HEAD but no objects or refs
+	os.MkdirAll(filepath.Join(root, "notes"), 0755)
+	os.WriteFile(filepath.Join(root, "notes", "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password", "ref:"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	rep := &collectReporter{}
+	cfg := spider.Config{Threads: 2, NoDownload: true, GitHistory: true}
+	spider.NewSpider(cfg, m, copyingFS{&spider.LocalFS{}}, utils.NewDeduplicator(), rep).Walk(root)
+
+	var got []string
+	for _, r := range rep.results {
+		if r.Git != nil {
+			got = append(got, "git:"+r.Git.Path)
+		} else {
+			got = append(got, filepath.Base(r.Path))
+		}
+	}
+	slices.Sort(got)
+	if want := []string{"HEAD", "git:config/db.yml"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// slowGitFS is a remote backend stalling on object reads.
+type slowGitFS struct {
+	copyingFS
+	release chan struct{}
+}
+
+func (f slowGitFS) Open(name string) (fs.File, error) {
+	if strings.Contains(filepath.ToSlash(name), "/objects/") {
+		<-f.release
+	}
+	return f.copyingFS.Open(name)
+}
+
+// A repo taking longer than FileTimeout is given up on like a file.
+func TestSpiderGitHistoryTimeout(t *testing.T) {
+	repoDir := initGitRepo(t)
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	fsys := slowGitFS{copyingFS{&spider.LocalFS{}}, make(chan struct{})}
+	defer close(fsys.release)
+	failures := &spider.Failures{}
+	cfg := spider.Config{Threads: 1, NoDownload: true, GitHistory: true, FileTimeout: 100 * time.Millisecond, Failures: failures}
+	spider.NewSpider(cfg, m, fsys, utils.NewDeduplicator(), &collectReporter{}).Walk(repoDir)
+
+	files := failures.Files()
+	if len(files) != 1 || filepath.Base(files[0].Path) != ".git" || files[0].Reason != "timeout" {
+		t.Errorf("failures: %+v", files)
+	}
+}
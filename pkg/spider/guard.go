@@ -0,0 +1,93 @@
+package spider
+
+import (
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xSterny/spuderman/pkg/extractor"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// FailedFile is a file whose processing panicked or ran out of time.
+type FailedFile struct {
+	Host   string `json:"host,omitempty"`
+	Share  string `json:"share,omitempty"`
+	Scheme string `json:"scheme,omitempty"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"` // extractor.ReasonPanic or extractor.ReasonTimeout
+	Error  string `json:"error,omitempty"`
+}
+
+// Failures collects the failed files of a scan, for the final report. One
+// is shared by all the spiders of a scan (Config.Failures).
+type Failures struct {
+	mu    sync.Mutex
+	files []FailedFile
+}
+
+func (f *Failures) add(ff FailedFile) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files = append(f.files, ff)
+}
+
+// Files returns the failed files, sorted by host, share and path.
+func (f *Failures) Files() []FailedFile {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	files := slices.Clone(f.files)
+	slices.SortFunc(files, func(a, b FailedFile) int {
+		return strings.Compare(a.Host+"\x00"+a.Share+"\x00"+a.Path, b.Host+"\x00"+b.Share+"\x00"+b.Path)
+	})
+	return files
+}
+
+// guard runs fn, the processing of the file at path, so that a panic in a
+// parser only loses that file, and, with Config.FileTimeout, gives up on the
+// file after that long. Go cannot stop fn: it is left to finish in the
+// background, and the caller (and its semaphore slot) moves on.
+func (s *Spider) guard(path string, fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer s.recoverFile(path)
+		fn()
+	}()
+
+	if s.Config.FileTimeout <= 0 {
+		<-done
+		return
+	}
+	timer := time.NewTimer(s.Config.FileTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		s.fileFailed(path, extractor.ReasonTimeout, fmt.Sprintf("still running after %s", s.Config.FileTimeout))
+	}
+}
+
+// recoverFile is deferred by the code processing the file at path: a panic
+// is recorded as a failure of that file instead of ending the scan.
+func (s *Spider) recoverFile(path string) {
+	if r := recover(); r != nil {
+		s.fileFailed(path, extractor.ReasonPanic, fmt.Sprint(r))
+		utils.LogDebug("Panic processing //%s/%s/%s: %v\n%s", s.Config.Host, s.Config.Share, path, r, debug.Stack())
+	}
+}
+
+func (s *Spider) fileFailed(path, reason, msg string) {
+	utils.LogWarning("File failed (%s): //%s/%s/%s: %s", reason, s.Config.Host, s.Config.Share, path, msg)
+	s.countFailure(reason)
+	s.Config.Failures.add(FailedFile{Host: s.Config.Host, Share: s.Config.Share, Scheme: s.Config.Scheme, Path: path, Reason: reason, Error: msg})
+}
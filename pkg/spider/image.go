@@ -0,0 +1,170 @@
+package spider
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// ImageSeparator separates the path of a disk image from the path inside it,
+// e.g. "Backups/dc01.vhdx!/Windows/NTDS/ntds.dit".
+const ImageSeparator = "!"
+
+// imageExts are the disk image formats opened with Config.ScanImages.
+var imageExts = map[string]bool{
+	".iso":  true,
+	".vhd":  true,
+	".vhdx": true,
+	".vmdk": true,
+}
+
+func isImageName(name string) bool {
+	return imageExts[strings.ToLower(path.Ext(name))]
+}
+
+// scanImage walks the filesystems inside the disk image at p with a child
+// spider, so the image contents go through the normal match pipeline.
+func (s *Spider) scanImage(p string, sem chan struct{}) {
+	// Image parsers run on untrusted data: a panic skips the image
+	defer s.recoverFile(p)
+
+	f, err := s.FS.Open(p)
+	if err != nil {
+		utils.LogDebug("Failed to open image //%s/%s/%s: %v", s.Config.Host, s.Config.Share, p, err)
+		return
+	}
+	defer f.Close()
+
+	img, err := OpenImage(f, path.Base(p))
+	if err != nil {
+		utils.LogDebug("Skipping image //%s/%s/%s: %v", s.Config.Host, s.Config.Share, p, err)
+		return
+	}
+	utils.LogInfo("Scanning disk image //%s/%s/%s (%s)", s.Config.Host, s.Config.Share, p, img)
+
+	child := NewSpider(s.Config, s.Matcher, &nestedFS{FS: img, prefix: p + ImageSeparator}, s.Dedup, s.Reporter)
+	child.Semaphore = sem
+	child.Walk("/")
+}
+
+// OpenImage opens the filesystem(s) inside a disk image. The format is picked
+// from name's extension; f must support random access (io.ReaderAt).
+func OpenImage(f fs.File, name string) (*ImageFS, error) {
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		return nil, errors.New("no random access on this backend")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	ext := strings.ToLower(path.Ext(name))
+	if ext == ".iso" {
+		vol, err := openISO9660(ra, size)
+		if err != nil {
+			return nil, err
+		}
+		return &ImageFS{format: "iso9660", volumes: []imageVolume{{kind: "iso9660", fs: vol}}}, nil
+	}
+
+	var disk io.ReaderAt
+	switch ext {
+	case ".vhd":
+		disk, size, err = openVHD(ra, size)
+	case ".vhdx":
+		disk, size, err = openVHDX(ra, size)
+	case ".vmdk":
+		disk, size, err = openVMDK(ra, size)
+	default:
+		err = fmt.Errorf("unsupported image type %q", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return openDisk(ext[1:], disk, size)
+}
+
+// ImageFS exposes the volumes of a disk image as one FileSystem. A single
+// volume is served at "/"; several are served as "/p1", "/p2", ...
+type ImageFS struct {
+	format  string
+	volumes []imageVolume
+}
+
+type imageVolume struct {
+	name string // "" for a single volume
+	kind string // ntfs, fat32, iso9660
+	fs   FileSystem
+}
+
+func (img *ImageFS) String() string {
+	kinds := make([]string, 0, len(img.volumes))
+	for _, v := range img.volumes {
+		kinds = append(kinds, v.kind)
+	}
+	return fmt.Sprintf("%s: %s", img.format, strings.Join(kinds, ", "))
+}
+
+// resolve returns the volume holding p and p inside that volume.
+func (img *ImageFS) resolve(p string) (*imageVolume, string) {
+	p = path.Clean("/" + p)
+	for i := range img.volumes {
+		v := &img.volumes[i]
+		if v.name == "" {
+			return v, p
+		}
+		prefix := "/" + v.name
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return v, path.Clean("/" + strings.TrimPrefix(p, prefix))
+		}
+	}
+	return nil, ""
+}
+
+func (img *ImageFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	if v, inner := img.resolve(root); v != nil {
+		return v.fs.WalkDir(inner, func(p string, d fs.DirEntry, err error) error {
+			return fn(path.Join("/", v.name, p), d, err)
+		})
+	}
+
+	// Several volumes: walk each under its own name
+	for _, v := range img.volumes {
+		if err := img.WalkDir("/"+v.name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (img *ImageFS) Open(name string) (fs.File, error) {
+	v, inner := img.resolve(name)
+	if v == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return v.fs.Open(inner)
+}
+
+// nestedFS reports paths of an inner FileSystem as prefix + inner path.
+type nestedFS struct {
+	FS     FileSystem
+	prefix string
+}
+
+func (n *nestedFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return n.FS.WalkDir(strings.TrimPrefix(root, n.prefix), func(p string, d fs.DirEntry, err error) error {
+		return fn(n.prefix+p, d, err)
+	})
+}
+
+func (n *nestedFS) Open(name string) (fs.File, error) {
+	return n.FS.Open(strings.TrimPrefix(name, n.prefix))
+}
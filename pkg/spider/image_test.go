@@ -0,0 +1,367 @@
+package spider_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	diskfs "github.com/diskfs/go-diskfs"
+	"github.com/diskfs/go-diskfs/disk"
+	"github.com/diskfs/go-diskfs/filesystem"
+	"github.com/diskfs/go-diskfs/filesystem/iso9660"
+	"github.com/diskfs/go-diskfs/partition/mbr"
+
+	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// writeImageFile writes a file into a go-diskfs filesystem.
+func writeImageFile(t *testing.T, fsys filesystem.FileSystem, name, content string) {
+	t.Helper()
+	if err := fsys.Mkdir(filepath.Dir(name)); err != nil {
+		t.Fatal(err)
+	}
+	f, err := fsys.OpenFile(name, os.O_CREATE|os.O_RDWR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+// createFAT32 creates a FAT32 disk image, with an MBR if partitioned.
+func createFAT32(t *testing.T, p string, partitioned bool) {
+	t.Helper()
+	size := int64(40 * 1024 * 1024)
+	d, err := diskfs.Create(p, size, diskfs.SectorSizeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	spec := disk.FilesystemSpec{Partition: 0, FSType: filesystem.TypeFat32}
+	if partitioned {
+		table := &mbr.Table{
+			LogicalSectorSize:  512,
+			PhysicalSectorSize: 512,
+			Partitions: []*mbr.Partition{
+				{Type: mbr.Fat32LBA, Start: 2048, Size: uint32(size/512) - 2048},
+			},
+		}
+		if err := d.Partition(table); err != nil {
+			t.Fatal(err)
+		}
+		spec.Partition = 1
+	}
+	fsys, err := d.CreateFilesystem(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeImageFile(t, fsys, "/backup/secret.txt", "password=Winter2024")
+	writeImageFile(t, fsys, "/backup/notes.txt", "nothing here")
+}
+
+// appendVHDFooter turns a raw disk into a fixed VHD, with a footer of
+// footerSize bytes (511 before Virtual PC 2004).
+func appendVHDFooter(t *testing.T, p string, footerSize int) {
+	t.Helper()
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	footer := make([]byte, 512)
+	copy(footer, "conectix")
+	binary.BigEndian.PutUint64(footer[40:], uint64(info.Size()))
+	binary.BigEndian.PutUint64(footer[48:], uint64(info.Size()))
+	binary.BigEndian.PutUint32(footer[60:], 2)
+
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(footer[:footerSize]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// guid returns the on-disk (mixed-endian) bytes of a GUID string.
+func guid(s string) []byte {
+	b, _ := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	b[4], b[5] = b[5], b[4]
+	b[6], b[7] = b[7], b[6]
+	return b
+}
+
+// VHDX layout written by vhdxImage, for the tests that corrupt it.
+const (
+	vhdxRegionTable = 192 * 1024
+	vhdxMetadata    = 1 << 20
+	vhdxItems       = vhdxMetadata + 64*1024 // Block size, virtual size, sector size
+	vhdxBAT         = 2 << 20
+)
+
+// vhdxImage wraps a raw disk into a dynamic VHDX with 1 MiB blocks. Blocks
+// that are all zeros are left unallocated.
+func vhdxImage(t *testing.T, disk []byte) []byte {
+	t.Helper()
+	const blockSize = 1 << 20
+	blocks := (len(disk) + blockSize - 1) / blockSize
+	batLen := (blocks*8 + blockSize - 1) / blockSize * blockSize
+
+	img := make([]byte, vhdxBAT+batLen)
+	copy(img, "vhdxfile")
+
+	rt := img[vhdxRegionTable:]
+	copy(rt, "regi")
+	binary.LittleEndian.PutUint32(rt[8:], 2)
+	copy(rt[16:], guid("2DC27766-F623-4200-9D64-115E9BFD4A08"))
+	binary.LittleEndian.PutUint64(rt[32:], vhdxBAT)
+	binary.LittleEndian.PutUint32(rt[40:], uint32(batLen))
+	copy(rt[48:], guid("8B7CA206-4790-4B9A-B8FE-575F050F886E"))
+	binary.LittleEndian.PutUint64(rt[64:], vhdxMetadata)
+	binary.LittleEndian.PutUint32(rt[72:], 1<<20)
+
+	meta := img[vhdxMetadata:]
+	copy(meta, "metadata")
+	binary.LittleEndian.PutUint16(meta[10:], 3)
+	for i, item := range []struct {
+		id       string
+		off, len uint32
+	}{
+		{"CAA16737-FA36-4D43-B3B6-33F0AA44E76B", 64 * 1024, 8},
+		{"2FA54224-CD1B-4876-B211-5DBED83BF4B8", 64*1024 + 8, 8},
+		{"8141BF1D-A96F-4709-BA47-F233A8FAAB5F", 64*1024 + 16, 4},
+	} {
+		e := meta[32+i*32:]
+		copy(e, guid(item.id))
+		binary.LittleEndian.PutUint32(e[16:], item.off)
+		binary.LittleEndian.PutUint32(e[20:], item.len)
+	}
+	binary.LittleEndian.PutUint32(img[vhdxItems:], blockSize)
+	binary.LittleEndian.PutUint64(img[vhdxItems+8:], uint64(len(disk)))
+	binary.LittleEndian.PutUint32(img[vhdxItems+16:], 512)
+
+	// 1 MiB blocks and 512 byte sectors: the first bitmap entry comes after
+	// 4096 payload blocks, so block i is BAT entry i
+	for i := 0; i < blocks; i++ {
+		block := disk[i*blockSize : min((i+1)*blockSize, len(disk))]
+		if !bytes.ContainsFunc(block, func(r rune) bool { return r != 0 }) {
+			continue
+		}
+		off := len(img)
+		img = append(img, make([]byte, blockSize)...)
+		copy(img[off:], block)
+		binary.LittleEndian.PutUint64(img[vhdxBAT+i*8:], uint64(off)|6) // PAYLOAD_BLOCK_FULLY_PRESENT
+	}
+	return img
+}
+
+// ntfsImage returns a small NTFS volume holding
+// "/Folder A/Folder B/Hello world text document.txt" (the test image of
+// go-ntfs).
+func ntfsImage(t *testing.T) []byte {
+	t.Helper()
+	f, err := os.Open("testdata/ntfs.dd.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func createISO(t *testing.T, p string) {
+	t.Helper()
+	d, err := diskfs.Create(p, 10*1024*1024, diskfs.SectorSizeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.LogicalBlocksize = 2048
+
+	fsys, err := d.CreateFilesystem(disk.FilesystemSpec{Partition: 0, FSType: filesystem.TypeISO9660})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeImageFile(t, fsys, "/backup/secret.txt", "password=Winter2024")
+	if err := fsys.(*iso9660.FileSystem).Finalize(iso9660.FinalizeOptions{RockRidge: true}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpiderDiskImages(t *testing.T) {
+	tmpDir := t.TempDir()
+	createFAT32(t, filepath.Join(tmpDir, "dc01.vhd"), true)
+	appendVHDFooter(t, filepath.Join(tmpDir, "dc01.vhd"), 512)
+	createFAT32(t, filepath.Join(tmpDir, "old.vhd"), true)
+	appendVHDFooter(t, filepath.Join(tmpDir, "old.vhd"), 511)
+	createFAT32(t, filepath.Join(tmpDir, "flat.vmdk"), false)
+	createISO(t, filepath.Join(tmpDir, "install.iso"))
+
+	raw := filepath.Join(t.TempDir(), "raw.img")
+	createFAT32(t, raw, true)
+	disk, err := os.ReadFile(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "dc02.vhdx"), vhdxImage(t, disk), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "fs01.vhd"), ntfsImage(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	appendVHDFooter(t, filepath.Join(tmpDir, "fs01.vhd"), 512)
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password", "hello world"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	rep := &collectReporter{}
+	cfg := spider.Config{Threads: 2, NoDownload: true, ScanImages: true}
+	s := spider.NewSpider(cfg, m, &spider.LocalFS{}, utils.NewDeduplicator(), rep)
+	s.Walk(tmpDir)
+
+	var got []string
+	for _, r := range rep.results {
+		rel, _ := filepath.Rel(tmpDir, r.Path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+
+	want := []string{
+		"dc01.vhd!/backup/secret.txt",
+		"dc02.vhdx!/backup/secret.txt",
+		"flat.vmdk!/backup/secret.txt",
+		"fs01.vhd!/Folder A/Folder B/Hello world text document.txt",
+		"install.iso!/backup/secret.txt",
+		"old.vhd!/backup/secret.txt",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("result %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+// Corrupt images must fail with an error: no panic, and no allocation sized
+// from a bogus header.
+func TestOpenImageCorrupt(t *testing.T) {
+	raw := filepath.Join(t.TempDir(), "raw.img")
+	createFAT32(t, raw, false)
+	disk, err := os.ReadFile(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	garbage := bytes.Repeat([]byte{0xA5, 0x3C, 0xFF, 0x00}, 256*1024)
+
+	vhdx := func(patch func(img []byte)) []byte {
+		img := vhdxImage(t, disk)
+		patch(img)
+		return img
+	}
+	vhdFooter := func(diskType uint32, dynHeader []byte) []byte {
+		img := make([]byte, 4096)
+		copy(img, dynHeader)
+		footer := img[len(img)-512:]
+		copy(footer, "conectix")
+		binary.BigEndian.PutUint64(footer[16:], 0) // Dynamic header at 0
+		binary.BigEndian.PutUint64(footer[48:], 1<<40)
+		binary.BigEndian.PutUint32(footer[60:], diskType)
+		return img
+	}
+	dynHeader := make([]byte, 1024)
+	copy(dynHeader, "cxsparse")
+	binary.BigEndian.PutUint64(dynHeader[16:], 1024)
+	binary.BigEndian.PutUint32(dynHeader[28:], 0xFFFFFFFF) // 16 GiB BAT
+	binary.BigEndian.PutUint32(dynHeader[32:], 2<<20)
+
+	gpt := make([]byte, 1<<20)
+	gpt[446+4] = 0xEE
+	binary.LittleEndian.PutUint32(gpt[446+8:], 1)
+	gpt[510], gpt[511] = 0x55, 0xAA
+	copy(gpt[512:], "EFI PART")
+	binary.LittleEndian.PutUint64(gpt[512+72:], 2)
+	binary.LittleEndian.PutUint32(gpt[512+80:], 1024)
+	binary.LittleEndian.PutUint32(gpt[512+84:], 0xFFFFFF80) // 4 TiB table
+
+	ntfs := ntfsImage(t)
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"garbage.vhd", garbage},
+		{"truncated.vhd", []byte("conectix")},
+		{"dynamic-bat.vhd", vhdFooter(3, dynHeader)},
+		{"garbage.vhdx", garbage},
+		{"truncated.vhdx", []byte("vhdxfile")},
+		{"truncated-bat.vhdx", vhdxImage(t, disk)[:vhdxBAT+1024]},
+		{"bat-length.vhdx", vhdx(func(img []byte) {
+			binary.LittleEndian.PutUint32(img[vhdxRegionTable+40:], 0xFFFFFFFF)
+		})},
+		{"item-length.vhdx", vhdx(func(img []byte) {
+			binary.LittleEndian.PutUint32(img[vhdxMetadata+32+20:], 0xFFFFFFFF)
+		})},
+		{"block-size.vhdx", vhdx(func(img []byte) {
+			binary.LittleEndian.PutUint32(img[vhdxItems:], 1<<31) // chunk ratio of 0
+		})},
+		{"sector-size.vhdx", vhdx(func(img []byte) {
+			binary.LittleEndian.PutUint32(img[vhdxItems+16:], 0)
+		})},
+		{"garbage.vmdk", garbage},
+		{"truncated.vmdk", []byte("KDMV")},
+		{"gpt-table.vmdk", gpt},
+		{"truncated-ntfs.vmdk", ntfs[:64*1024]},
+		{"garbage-ntfs.vmdk", append(slices.Clone(ntfs[:512]), garbage...)},
+		{"truncated-fat32.vmdk", disk[:4096]},
+		{"garbage.iso", garbage},
+		{"truncated.iso", []byte("CD001")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), tc.name)
+			if err := os.WriteFile(p, tc.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			img, err := spider.OpenImage(f, tc.name)
+			if err == nil {
+				// Some damage only shows when the volume is read
+				err = img.WalkDir("/", func(p string, d fs.DirEntry, err error) error { return err })
+			}
+			if err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package spider
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diskfs/go-diskfs/backend"
+	"github.com/diskfs/go-diskfs/filesystem"
+	"github.com/diskfs/go-diskfs/filesystem/fat32"
+	"github.com/diskfs/go-diskfs/filesystem/iso9660"
+	ntfs "www.velocidex.com/golang/go-ntfs/parser"
+)
+
+// Read-only adapters from in-image filesystems to FileSystem. The parsers
+// are not safe for concurrent use, so every call takes the volume lock.
+
+// ntfsFS serves an NTFS volume through go-ntfs.
+type ntfsFS struct {
+	mu  sync.Mutex
+	ctx *ntfs.NTFSContext
+}
+
+func openNTFS(vol io.ReaderAt) (fsys FileSystem, err error) {
+	defer recoverParser(&err)
+
+	reader, err := ntfs.NewPagedReader(vol, 1024, 10000)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := ntfs.GetNTFSContext(reader, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &ntfsFS{ctx: ctx}, nil
+}
+
+// recoverParser turns a panic on a corrupt image into an error.
+func recoverParser(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("corrupt filesystem: %v", r)
+	}
+}
+
+func (n *ntfsFS) readDir(dir string) (infos []*ntfs.FileInfo, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	defer recoverParser(&err)
+
+	root, err := n.ctx.GetMFT(5)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := root.Open(n.ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return ntfs.ListDir(n.ctx, entry), nil
+}
+
+func (n *ntfsFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	infos, err := n.readDir(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	for _, fi := range infos {
+		// Skip ".", metadata files ($MFT, $LogFile, ...) and alternate streams
+		if fi.Name == "." || strings.HasPrefix(fi.Name, "$") || strings.Contains(fi.Name, ":") {
+			continue
+		}
+		info := &imageFileInfo{name: fi.Name, size: fi.Size, modTime: fi.Mtime, dir: fi.IsDir}
+		p := path.Join(root, fi.Name)
+
+		if err := fn(p, fs.FileInfoToDirEntry(info), nil); err != nil {
+			if err == fs.SkipDir {
+				if fi.IsDir {
+					continue
+				}
+				return nil
+			}
+			return err
+		}
+		if fi.IsDir {
+			if err := n.WalkDir(p, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (n *ntfsFS) Open(name string) (f fs.File, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	defer recoverParser(&err)
+
+	data, err := ntfs.GetDataForPath(n.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	size := ntfs.RangeSize(data)
+	info := &imageFileInfo{name: path.Base(name), size: size}
+	return &imageFileAt{SectionReader: io.NewSectionReader(&lockedReaderAt{mu: &n.mu, r: data}, 0, size), info: info}, nil
+}
+
+// lockedReaderAt serializes reads through a shared parser.
+type lockedReaderAt struct {
+	mu *sync.Mutex
+	r  io.ReaderAt
+}
+
+func (l *lockedReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer recoverParser(&err)
+	return l.r.ReadAt(p, off)
+}
+
+// diskfsFS serves FAT32 and ISO9660 volumes through go-diskfs.
+type diskfsFS struct {
+	mu sync.Mutex
+	fs filesystem.FileSystem
+}
+
+func openFAT32(vol io.ReaderAt, size int64) (fsys FileSystem, err error) {
+	defer recoverParser(&err)
+	f, err := fat32.Read(newReadOnlyStorage(vol, size), size, 0, 512)
+	if err != nil {
+		return nil, err
+	}
+	return &diskfsFS{fs: f}, nil
+}
+
+func openISO9660(vol io.ReaderAt, size int64) (fsys FileSystem, err error) {
+	defer recoverParser(&err)
+	f, err := iso9660.Read(newReadOnlyStorage(vol, size), size, 0, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &diskfsFS{fs: f}, nil
+}
+
+func (d *diskfsFS) readDir(dir string) (infos []os.FileInfo, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer recoverParser(&err)
+	return d.fs.ReadDir(dir)
+}
+
+func (d *diskfsFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	infos, err := d.readDir(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	for _, info := range infos {
+		if info.Name() == "." || info.Name() == ".." {
+			continue
+		}
+		p := path.Join(root, info.Name())
+		if err := fn(p, fs.FileInfoToDirEntry(info), nil); err != nil {
+			if err == fs.SkipDir {
+				if info.IsDir() {
+					continue
+				}
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if err := d.WalkDir(p, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *diskfsFS) Open(name string) (f fs.File, err error) {
+	infos, err := d.readDir(path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	var info os.FileInfo
+	for _, i := range infos {
+		if strings.EqualFold(i.Name(), path.Base(name)) {
+			info = i
+			break
+		}
+	}
+	if info == nil || info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer recoverParser(&err)
+
+	file, err := d.fs.OpenFile(name, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	return &imageFile{Reader: &lockedReader{mu: &d.mu, r: file}, info: info, closer: file}, nil
+}
+
+type lockedReader struct {
+	mu *sync.Mutex
+	r  io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (n int, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer recoverParser(&err)
+	return l.r.Read(p)
+}
+
+// readOnlyStorage is the go-diskfs backend over an io.ReaderAt.
+type readOnlyStorage struct {
+	*io.SectionReader
+}
+
+func newReadOnlyStorage(r io.ReaderAt, size int64) *readOnlyStorage {
+	return &readOnlyStorage{io.NewSectionReader(r, 0, size)}
+}
+
+func (s *readOnlyStorage) Stat() (fs.FileInfo, error) {
+	return &imageFileInfo{name: "image", size: s.Size()}, nil
+}
+
+func (s *readOnlyStorage) Close() error { return nil }
+
+func (s *readOnlyStorage) Sys() (*os.File, error) { return nil, backend.ErrNotSuitable }
+
+func (s *readOnlyStorage) Writable() (backend.WritableFile, error) {
+	return nil, backend.ErrIncorrectOpenMode
+}
+
+// imageFile is a file inside a disk image.
+type imageFile struct {
+	io.Reader
+	info   fs.FileInfo
+	closer io.Closer
+}
+
+func (f *imageFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *imageFile) Close() error {
+	if f.closer != nil {
+		return f.closer.Close()
+	}
+	return nil
+}
+
+// imageFileAt is a file with random access, so images nested in an image
+// can be opened too.
+type imageFileAt struct {
+	*io.SectionReader
+	info fs.FileInfo
+}
+
+func (f *imageFileAt) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *imageFileAt) Close() error               { return nil }
+
+type imageFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *imageFileInfo) Name() string       { return i.name }
+func (i *imageFileInfo) Size() int64        { return i.size }
+func (i *imageFileInfo) ModTime() time.Time { return i.modTime }
+func (i *imageFileInfo) IsDir() bool        { return i.dir }
+func (i *imageFileInfo) Sys() any           { return nil }
+
+func (i *imageFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
@@ -0,0 +1,308 @@
+package spider
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xSterny/spuderman/pkg/utils"
+	"github.com/willscott/go-nfs-client/nfs"
+	"github.com/willscott/go-nfs-client/nfs/rpc"
+	"github.com/willscott/go-nfs-client/nfs/xdr"
+)
+
+// nfsEntryTimeout is how long directory listings are cached by the client.
+const nfsEntryTimeout = time.Minute
+
+// NFSConfig describes an NFSv3 server. Credentials are AUTH_SYS, i.e. the
+// server trusts whatever uid/gid we claim: pick the owner of the files you
+// want to read (root is usually squashed to nobody).
+type NFSConfig struct {
+	Host string
+
+	// Port, if set, reaches MOUNT and NFS directly on this port instead of
+	// asking the portmapper (userspace servers serve both on one port).
+	Port int
+
+	UID         uint32
+	GID         uint32
+	MachineName string // sent in the AUTH_SYS credential; defaults to our hostname
+}
+
+func (c NFSConfig) auth() rpc.Auth {
+	name := c.MachineName
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	return rpc.NewAuthUnix(name, c.UID, c.GID).Auth()
+}
+
+// dialMount connects to the MOUNT service. With a fixed port the returned
+// Mount has no Addr, so Mount() reuses the same connection for NFS.
+func (c NFSConfig) dialMount() (*nfs.Mount, error) {
+	if c.Port != 0 {
+		client, err := nfs.DialServiceAtPort(c.Host, c.Port)
+		if err != nil {
+			return nil, err
+		}
+		return &nfs.Mount{Client: client}, nil
+	}
+	return nfs.DialMount(c.Host, nfsEntryTimeout)
+}
+
+// NFSExport is one line of `showmount -e`.
+type NFSExport struct {
+	Dir    string
+	Groups []string // clients allowed to mount; empty means everyone
+}
+
+func (e NFSExport) String() string {
+	if len(e.Groups) == 0 {
+		return e.Dir + " *"
+	}
+	return e.Dir + " " + strings.Join(e.Groups, ",")
+}
+
+// ListNFSExports asks the MOUNT service for its export list (MOUNTPROC3_EXPORT),
+// like `showmount -e host`.
+func ListNFSExports(cfg NFSConfig) ([]NFSExport, error) {
+	m, err := cfg.dialMount()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	type export struct {
+		rpc.Header
+	}
+	res, err := m.Call(&export{rpc.Header{
+		Rpcvers: 2,
+		Prog:    nfs.MountProg,
+		Vers:    nfs.MountVers,
+		Proc:    nfs.MountProc3Export,
+		Cred:    rpc.AuthNull,
+		Verf:    rpc.AuthNull,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	// exports: linked list of {dir, groups: linked list of names}, each
+	// element preceded by a "value follows" bool.
+	var exports []NFSExport
+	for {
+		more, err := xdr.ReadUint32(res)
+		if err != nil {
+			return nil, err
+		}
+		if more == 0 {
+			break
+		}
+		dir, err := readXDRString(res)
+		if err != nil {
+			return nil, err
+		}
+		e := NFSExport{Dir: dir}
+		for {
+			more, err := xdr.ReadUint32(res)
+			if err != nil {
+				return nil, err
+			}
+			if more == 0 {
+				break
+			}
+			group, err := readXDRString(res)
+			if err != nil {
+				return nil, err
+			}
+			e.Groups = append(e.Groups, group)
+		}
+		exports = append(exports, e)
+	}
+	return exports, nil
+}
+
+// readXDRString reads a string and its padding (xdr.ReadOpaque leaves the
+// padding in the stream).
+func readXDRString(r io.Reader) (string, error) {
+	b, err := xdr.ReadOpaque(r)
+	if err != nil {
+		return "", err
+	}
+	if pad := (4 - len(b)%4) % 4; pad > 0 {
+		if _, err := io.CopyN(io.Discard, r, int64(pad)); err != nil {
+			return "", err
+		}
+	}
+	return string(b), nil
+}
+
+// NFSFS spiders one or more exports of a host over NFSv3. Paths are absolute
+// server paths ("/srv/share/file"), so several exports can be walked at once.
+type NFSFS struct {
+	mounts []*nfsMount // longest export path first
+}
+
+type nfsMount struct {
+	export string
+	mount  *nfs.Mount
+	target *nfs.Target
+}
+
+// DialNFS mounts export. With an empty export, every export listed by the
+// server is mounted; the ones we are not allowed to mount are skipped.
+func DialNFS(cfg NFSConfig, export string) (*NFSFS, error) {
+	n := &NFSFS{}
+	if export != "" {
+		m, err := mountNFS(cfg, export)
+		if err != nil {
+			return nil, err
+		}
+		n.mounts = append(n.mounts, m)
+		return n, nil
+	}
+
+	exports, err := ListNFSExports(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exports {
+		utils.LogInfo("NFS export on %s: %s", cfg.Host, e)
+		m, err := mountNFS(cfg, e.Dir)
+		if err != nil {
+			utils.LogWarning("Failed to mount %s:%s: %v", cfg.Host, e.Dir, err)
+			continue
+		}
+		n.mounts = append(n.mounts, m)
+	}
+	if len(n.mounts) == 0 {
+		return nil, fmt.Errorf("no mountable exports (%d listed)", len(exports))
+	}
+
+	sort.Slice(n.mounts, func(i, j int) bool {
+		return len(n.mounts[i].export) > len(n.mounts[j].export)
+	})
+	return n, nil
+}
+
+func mountNFS(cfg NFSConfig, export string) (*nfsMount, error) {
+	m, err := cfg.dialMount()
+	if err != nil {
+		return nil, err
+	}
+	target, err := m.Mount(export, cfg.auth())
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	return &nfsMount{export: path.Clean("/" + export), mount: m, target: target}, nil
+}
+
+// resolve finds the export containing p and returns p relative to it.
+func (n *NFSFS) resolve(p string) (*nfsMount, string) {
+	p = path.Clean("/" + p)
+	for _, m := range n.mounts {
+		if m.export == "/" {
+			return m, p
+		}
+		if p == m.export || strings.HasPrefix(p, m.export+"/") {
+			return m, strings.TrimPrefix(p, m.export)
+		}
+	}
+	return nil, ""
+}
+
+// WalkDir walks root, or every mounted export when root is "" or "/" and
+// none of them is "/" itself.
+func (n *NFSFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	if m, _ := n.resolve(root); m != nil {
+		return n.walk(m, path.Clean("/"+root), fn)
+	}
+	if root != "" && root != "/" {
+		return fn(root, nil, fs.ErrNotExist)
+	}
+	for _, m := range n.mounts {
+		if err := n.walk(m, m.export, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *NFSFS) walk(m *nfsMount, dir string, fn fs.WalkDirFunc) error {
+	_, rel := n.resolve(dir)
+	entries, err := m.target.ReadDirPlus(rel)
+	if err != nil {
+		return fn(dir, nil, err)
+	}
+
+	for _, e := range entries {
+		p := path.Join(dir, e.Name())
+		if err := fn(p, fs.FileInfoToDirEntry(e), nil); err != nil {
+			if err == fs.SkipDir {
+				if e.IsDir() {
+					continue
+				}
+				return nil
+			}
+			return err
+		}
+
+		if e.IsDir() {
+			if err := n.walk(m, p, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (n *NFSFS) Open(name string) (fs.File, error) {
+	m, rel := n.resolve(name)
+	if m == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	info, _, err := m.target.Lookup(rel)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+
+	f, err := m.target.Open(rel)
+	if err != nil {
+		return nil, err
+	}
+	return &nfsFile{File: f, info: info}, nil
+}
+
+// Close unmounts (so we drop out of the server's rmtab) and disconnects.
+func (n *NFSFS) Close() {
+	for _, m := range n.mounts {
+		m.mount.Unmount()
+		m.target.Client.Close()
+		m.mount.Close()
+	}
+}
+
+// nfsFile is read-only: nfs.File.Close sends a COMMIT, which we don't want.
+type nfsFile struct {
+	*nfs.File
+	info fs.FileInfo
+}
+
+func (f *nfsFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *nfsFile) Close() error {
+	return nil
+}
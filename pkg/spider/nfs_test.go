@@ -0,0 +1,202 @@
+package spider_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	gonfs "github.com/willscott/go-nfs"
+	"github.com/willscott/go-nfs-client/nfs/rpc"
+	"github.com/willscott/go-nfs-client/nfs/xdr"
+	nfshelper "github.com/willscott/go-nfs/helpers"
+	"github.com/willscott/go-nfs/helpers/memfs"
+
+	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// credHandler records the AUTH_SYS credential of MOUNT calls.
+type credHandler struct {
+	gonfs.Handler
+	mu  sync.Mutex
+	uid uint32
+	gid uint32
+}
+
+func (h *credHandler) Mount(ctx context.Context, conn net.Conn, req gonfs.MountRequest) (gonfs.MountStatus, billy.Filesystem, []gonfs.AuthFlavor) {
+	var au rpc.AuthUnix
+	if req.Header.Cred.Flavor == 1 && xdr.Read(bytes.NewReader(req.Header.Cred.Body), &au) == nil {
+		h.mu.Lock()
+		h.uid, h.gid = au.Uid, au.Gid
+		h.mu.Unlock()
+	}
+	return h.Handler.Mount(ctx, conn, req)
+}
+
+// startNFSServer serves mem over NFSv3 (MOUNT and NFS on a single port, no
+// portmapper) and returns the port.
+func startNFSServer(t *testing.T, h gonfs.Handler) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go gonfs.Serve(ln, nfshelper.NewCachingHandler(h, 1024))
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestSpiderNFS(t *testing.T) {
+	files := map[string]string{
+		"home/alice/.bash_history": "mysql -u root -ppassword123",
+		"home/alice/notes.txt":     "nothing to see",
+		"backups/deep/db.conf":     "db_password=hunter2",
+	}
+	mem := memfs.New()
+	for rel, content := range files {
+		if err := util.WriteFile(mem, rel, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &credHandler{Handler: nfshelper.NewNullAuthHandler(mem)}
+	port := startNFSServer(t, h)
+
+	fsys, err := spider.DialNFS(spider.NFSConfig{Host: "127.0.0.1", Port: port, UID: 1001, GID: 100}, "/srv")
+	if err != nil {
+		t.Fatalf("DialNFS: %v", err)
+	}
+	defer fsys.Close()
+
+	h.mu.Lock()
+	if h.uid != 1001 || h.gid != 100 {
+		t.Errorf("AUTH_SYS credential = %d:%d, want 1001:100", h.uid, h.gid)
+	}
+	h.mu.Unlock()
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	lootDir := filepath.Join(t.TempDir(), "loot")
+	cfg := spider.Config{
+		Threads:    4,
+		LootDir:    lootDir,
+		Structured: true,
+		Host:       "nfs-host",
+		Share:      "srv",
+	}
+	s := spider.NewSpider(cfg, m, fsys, utils.NewDeduplicator(), nil)
+	s.Walk("/")
+
+	for _, want := range []string{"home/alice/.bash_history", "backups/deep/db.conf"} {
+		b, err := os.ReadFile(filepath.Join(lootDir, "nfs-host", "srv", "srv", want))
+		if err != nil {
+			t.Errorf("expected %s in loot: %v", want, err)
+			continue
+		}
+		if string(b) != files[want] {
+			t.Errorf("loot %s has wrong content: %q", want, b)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(lootDir, "nfs-host", "srv", "srv", "home/alice/notes.txt")); err == nil {
+		t.Error("notes.txt should not have matched")
+	}
+}
+
+// startExportServer answers MOUNTPROC3_EXPORT (go-nfs doesn't implement it)
+// with a fixed export list.
+func startExportServer(t *testing.T, reply []byte) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					var hdr uint32
+					if err := binary.Read(conn, binary.BigEndian, &hdr); err != nil {
+						return
+					}
+					call := make([]byte, hdr&0x7fffffff)
+					if _, err := io.ReadFull(conn, call); err != nil {
+						return
+					}
+
+					var msg bytes.Buffer
+					msg.Write(call[:4]) // xid
+					for _, v := range []uint32{1, 0, 0, 0, 0} {
+						// REPLY, MSG_ACCEPTED, AUTH_NULL verifier, SUCCESS
+						binary.Write(&msg, binary.BigEndian, v)
+					}
+					msg.Write(reply)
+					binary.Write(conn, binary.BigEndian, uint32(msg.Len())|0x80000000)
+					conn.Write(msg.Bytes())
+				}
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestListNFSExports(t *testing.T) {
+	type groupNode struct {
+		More uint32
+		Name string
+	}
+	type exportNode struct {
+		More   uint32
+		Dir    string
+		Groups []groupNode
+	}
+	nodes := []exportNode{
+		{1, "/srv", nil},
+		{1, "/home", []groupNode{{1, "10.0.0.0/24"}, {1, "admin-host"}}},
+	}
+
+	var reply bytes.Buffer
+	for _, n := range nodes {
+		xdr.Write(&reply, n.More)
+		xdr.Write(&reply, n.Dir)
+		for _, g := range n.Groups {
+			xdr.Write(&reply, g)
+		}
+		xdr.Write(&reply, uint32(0))
+	}
+	xdr.Write(&reply, uint32(0))
+
+	port := startExportServer(t, reply.Bytes())
+	exports, err := spider.ListNFSExports(spider.NFSConfig{Host: "127.0.0.1", Port: port})
+	if err != nil {
+		t.Fatalf("ListNFSExports: %v", err)
+	}
+
+	want := []spider.NFSExport{
+		{Dir: "/srv"},
+		{Dir: "/home", Groups: []string{"10.0.0.0/24", "admin-host"}},
+	}
+	if !reflect.DeepEqual(exports, want) {
+		t.Errorf("exports = %+v, want %+v", exports, want)
+	}
+}
@@ -2,26 +2,111 @@ package spider
 
 import (
 	"encoding/json"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/0xSterny/spuderman/pkg/creds"
+	"github.com/0xSterny/spuderman/pkg/smbclient"
 )
 
 type MatchResult struct {
 	Path      string `json:"path"`
 	Reason    string `json:"reason"`
+	Rule      string `json:"rule,omitempty"` // Which rule matched, see RuleAll
 	Hash      string `json:"sha256,omitempty"`
+	LootPath  string `json:"loot_path,omitempty"` // Downloaded copy; unset when the content was already looted (same sha256)
 	Size      int64  `json:"size,omitempty"`
+	Modified  string `json:"mtime,omitempty"` // Last write time from the listing (RFC 3339, UTC)
 	Timestamp string `json:"timestamp"`
 	Host      string `json:"host,omitempty"`
 	Share     string `json:"share,omitempty"`
+	Scheme    string `json:"scheme,omitempty"` // URL targets only, see URI
+
+	// Line and Snippet locate a content match: the 1-based line, in the text
+	// extracted from the file (unset for matches in decoded blobs), and the
+	// line itself.
+	Line    int    `json:"line,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+
+	// Severity and Category are set for files identified by content type
+	// (--classify), e.g. "critical" / "registry-hive".
+	Severity string `json:"severity,omitempty"`
+	Category string `json:"category,omitempty"`
+
+	// Metadata holds document properties of content matches, e.g. "author",
+	// "last_saved_by" (legacy Office files).
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Credentials are the secrets parsed from a configuration file
+	// (--parse-configs), each with its key path.
+	Credentials []creds.Credential `json:"credentials,omitempty"`
+
+	// DecodeChain is set when the content matched inside an encoded blob
+	// (--decode): the encodings undone, outermost first, e.g.
+	// ["base64", "gzip"].
+	DecodeChain []string `json:"decode_chain,omitempty"`
+
+	// HostInfo is the per-host record (names, SMB dialect, signing, OS build).
+	// Nil for local scans.
+	HostInfo *smbclient.HostInfo `json:"host_info,omitempty"`
+
+	// Git is set for findings in git history (--git-history).
+	Git *GitInfo `json:"git,omitempty"`
+}
+
+// URI locates the file: smb://host/share/path for SMB, file:///path for
+// local scans and scheme://host/path for URL targets (s3://bucket/key).
+func (m MatchResult) URI() string {
+	p := strings.ReplaceAll(m.Path, `\`, "/")
+	switch {
+	case m.Scheme == "s3":
+		return (&url.URL{Scheme: "s3", Host: m.Share, Path: "/" + strings.TrimPrefix(p, "/")}).String()
+	case m.Scheme != "":
+		return (&url.URL{Scheme: m.Scheme, Host: m.Host, Path: "/" + strings.TrimPrefix(p, "/")}).String()
+	case m.Host == LocalHost || m.Host == "":
+		if abs, err := filepath.Abs(m.Path); err == nil {
+			p = filepath.ToSlash(abs)
+		}
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p // C:/...
+		}
+		return (&url.URL{Scheme: "file", Path: p}).String()
+	}
+	return (&url.URL{Scheme: "smb", Host: m.Host, Path: "/" + m.Share + "/" + strings.TrimPrefix(p, "/")}).String()
 }
 
+// Rules of the findings that are not a filename or content pattern
+// ("filename:<pattern>", "content:<pattern>", "preset:<name>").
+const (
+	RuleAll      = "all"       // No filename or content filter: every file
+	RuleClassify = "classify:" // Followed by the detector category
+	RuleConfig   = "config:credentials"
+)
+
 type Reporter interface {
 	Report(MatchResult)
 	Close()
 }
 
+// Summary is what is known about a scan once it is over.
+type Summary struct {
+	// Shares are the counters of each share scanned, Total all of them
+	// added up.
+	Shares      []ShareStats `json:"shares,omitempty"`
+	Total       ShareStats   `json:"total"`
+	FailedFiles []FailedFile `json:"failed_files,omitempty"`
+}
+
+// SummaryReporter is implemented by reporters that also record the
+// end-of-scan summary.
+type SummaryReporter interface {
+	ReportSummary(Summary)
+}
+
 type JSONReporter struct {
 	file *os.File
 	enc  *json.Encoder
@@ -42,6 +127,9 @@ func NewJSONReporter(path string) (*JSONReporter, error) {
 func (r *JSONReporter) Report(m MatchResult) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.file == nil {
+		return
+	}
 
 	if m.Timestamp == "" {
 		m.Timestamp = time.Now().Format(time.RFC3339)
@@ -49,9 +137,25 @@ func (r *JSONReporter) Report(m MatchResult) {
 	r.enc.Encode(m)
 }
 
+// ReportSummary writes the end-of-scan summary as the last line, as
+// {"summary": {...}}.
+func (r *JSONReporter) ReportSummary(sum Summary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return
+	}
+	r.enc.Encode(struct {
+		Summary Summary `json:"summary"`
+	}{sum})
+}
+
 func (r *JSONReporter) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.file != nil {
 		r.file.Close()
+		r.file = nil
 	}
 }
 
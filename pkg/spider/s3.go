@@ -0,0 +1,178 @@
+package spider
+
+import (
+	"cmp"
+	"context"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// DefaultS3Endpoint is used when no custom endpoint is configured.
+const DefaultS3Endpoint = "https://s3.amazonaws.com"
+
+// S3Config describes an S3-compatible endpoint (AWS, MinIO, Ceph RGW, ...).
+// Keys left empty are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN; with no keys at all, requests are anonymous (public
+// buckets).
+type S3Config struct {
+	Endpoint     string // URL, e.g. http://127.0.0.1:9000; defaults to AWS
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// S3FS spiders a single bucket. Key prefixes ending in "/" are treated as
+// directories (ListObjectsV2 with a "/" delimiter); paths are object keys.
+type S3FS struct {
+	Client *minio.Client
+	Bucket string
+}
+
+// DialS3 creates a client for cfg and checks that bucket is reachable.
+func DialS3(cfg S3Config, bucket string) (*S3FS, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultS3Endpoint
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.AccessKey = cmp.Or(cfg.AccessKey, os.Getenv("AWS_ACCESS_KEY_ID"))
+	cfg.SecretKey = cmp.Or(cfg.SecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	cfg.SessionToken = cmp.Or(cfg.SessionToken, os.Getenv("AWS_SESSION_TOKEN"))
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, cfg.SessionToken)
+	} else {
+		creds = credentials.NewStatic("", "", "", credentials.SignatureAnonymous)
+	}
+
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  creds,
+		Secure: u.Scheme == "https",
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := client.BucketExists(context.Background(), bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	return &S3FS{Client: client, Bucket: bucket}, nil
+}
+
+// WalkDir walks every object under the root prefix. "" and "/" walk the
+// whole bucket.
+func (s *S3FS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	root = strings.TrimPrefix(root, "/")
+	if root != "" && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	return s.walk(root, fn)
+}
+
+func (s *S3FS) walk(prefix string, fn fs.WalkDirFunc) error {
+	// Cancelling stops the lister goroutine if we return early
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects := s.Client.ListObjects(ctx, s.Bucket, minio.ListObjectsOptions{
+		Prefix: prefix,
+	})
+
+	for obj := range objects {
+		if obj.Err != nil {
+			// The channel is closed after an error
+			return fn(prefix, nil, obj.Err)
+		}
+
+		// "Folder" marker objects show up as the prefix itself
+		if obj.Key == prefix {
+			continue
+		}
+
+		info := &s3FileInfo{obj: obj}
+		if err := fn(strings.TrimSuffix(obj.Key, "/"), fs.FileInfoToDirEntry(info), nil); err != nil {
+			if err == fs.SkipDir {
+				if info.IsDir() {
+					continue
+				}
+				return nil
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			if err := s.walk(obj.Key, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *S3FS) Open(name string) (fs.File, error) {
+	obj, err := s.Client.GetObject(context.Background(), s.Bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// GetObject is lazy; Stat forces the request so a missing key or
+	// AccessDenied surfaces here instead of on the first Read.
+	oi, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, err
+	}
+	return &s3File{Object: obj, info: &s3FileInfo{obj: oi}}, nil
+}
+
+// Close is a no-op; the client has no persistent connection to tear down.
+func (s *S3FS) Close() {}
+
+type s3File struct {
+	*minio.Object
+	info *s3FileInfo
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// s3FileInfo adapts minio.ObjectInfo to fs.FileInfo.
+type s3FileInfo struct {
+	obj minio.ObjectInfo
+}
+
+func (i *s3FileInfo) Name() string       { return path.Base(strings.TrimSuffix(i.obj.Key, "/")) }
+func (i *s3FileInfo) Size() int64        { return i.obj.Size }
+func (i *s3FileInfo) ModTime() time.Time { return i.obj.LastModified }
+func (i *s3FileInfo) IsDir() bool        { return strings.HasSuffix(i.obj.Key, "/") }
+func (i *s3FileInfo) Sys() any           { return i.obj }
+
+func (i *s3FileInfo) Mode() fs.FileMode {
+	if i.IsDir() {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
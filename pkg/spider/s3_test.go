@@ -0,0 +1,179 @@
+package spider_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+type s3Object struct {
+	data    string
+	modTime time.Time
+}
+
+type s3ListResult struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
+	Name           string
+	Prefix         string
+	Delimiter      string
+	KeyCount       int
+	MaxKeys        int
+	IsTruncated    bool
+	Contents       []s3ListObject
+	CommonPrefixes []s3ListPrefix
+}
+
+type s3ListObject struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+	StorageClass string
+}
+
+type s3ListPrefix struct {
+	Prefix string
+}
+
+// fakeS3 answers the requests S3FS makes (HEAD bucket, ListObjectsV2 with a
+// "/" delimiter, GET object) for a single path-style bucket, and records
+// the access keys requests were signed with.
+type fakeS3 struct {
+	bucket  string
+	objects map[string]s3Object
+
+	mu   sync.Mutex
+	keys []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if _, cred, ok := strings.Cut(auth, "Credential="); ok {
+			key, _, _ := strings.Cut(cred, "/")
+			f.mu.Lock()
+			f.keys = append(f.keys, key)
+			f.mu.Unlock()
+		}
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case key == "" && r.URL.Query().Get("list-type") == "2":
+		prefix, delim := r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter")
+		res := s3ListResult{Name: f.bucket, Prefix: prefix, Delimiter: delim, MaxKeys: 1000}
+		seen := make(map[string]bool)
+		keys := make([]string, 0, len(f.objects))
+		for k := range f.objects {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			rest, ok := strings.CutPrefix(k, prefix)
+			if !ok {
+				continue
+			}
+			if dir, _, ok := strings.Cut(rest, delim); ok && delim != "" {
+				if p := prefix + dir + delim; !seen[p] {
+					seen[p] = true
+					res.CommonPrefixes = append(res.CommonPrefixes, s3ListPrefix{p})
+				}
+				continue
+			}
+			o := f.objects[k]
+			res.Contents = append(res.Contents, s3ListObject{
+				Key:          k,
+				LastModified: o.modTime.UTC().Format("2006-01-02T15:04:05.000Z"),
+				ETag:         `"0123456789abcdef"`,
+				Size:         len(o.data),
+				StorageClass: "STANDARD",
+			})
+		}
+		res.KeyCount = len(res.Contents) + len(res.CommonPrefixes)
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(res)
+	case key != "":
+		o, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"0123456789abcdef"`)
+		http.ServeContent(w, r, key, o.modTime, bytes.NewReader([]byte(o.data)))
+	default:
+		http.Error(w, "NotImplemented", http.StatusNotImplemented)
+	}
+}
+
+func TestSpiderS3(t *testing.T) {
+	mtime := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	fake := &fakeS3{bucket: "backups", objects: map[string]s3Object{
+		"db/prod.env":        {"DB_PASSWORD=hunter2", mtime},
+		"db/readme.txt":      {"nothing to see", mtime},
+		"db/dumps/big.sql":   {strings.Repeat("x", 200) + "password", mtime},
+		"db/dumps/small.sql": {"-- password=changeme", mtime},
+		"web/config.ini":     {"password=outside the prefix", mtime},
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	// Keys not given come from the environment
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIATESTKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	fsys, err := spider.DialS3(spider.S3Config{Endpoint: srv.URL, Region: "us-east-1"}, "backups")
+	if err != nil {
+		t.Fatalf("DialS3: %v", err)
+	}
+	if _, err := spider.DialS3(spider.S3Config{Endpoint: srv.URL, Region: "us-east-1"}, "missing"); err == nil {
+		t.Error("DialS3 on a missing bucket: no error")
+	}
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	rep := &collectReporter{}
+	cfg := spider.Config{Threads: 2, NoDownload: true, MaxFileSize: 100, Host: "backups", Scheme: "s3"}
+	s := spider.NewSpider(cfg, m, fsys, utils.NewDeduplicator(), rep)
+	s.Walk("db")
+
+	var got []string
+	for _, r := range rep.results {
+		got = append(got, r.Path)
+		if r.Size == 0 || r.Modified != "2024-03-01T10:00:00Z" {
+			t.Errorf("%s: size %d, mtime %q from the listing", r.Path, r.Size, r.Modified)
+		}
+	}
+	sort.Strings(got)
+	// big.sql is over MaxFileSize, web/ is outside the prefix
+	if want := []string{"db/dumps/small.sql", "db/prod.env"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.keys) == 0 || slices.ContainsFunc(fake.keys, func(k string) bool { return k != "AKIATESTKEY" }) {
+		t.Errorf("requests signed with %q, want AKIATESTKEY from the environment", fake.keys)
+	}
+}
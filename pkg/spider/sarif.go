@@ -0,0 +1,258 @@
+package spider
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/0xSterny/spuderman/pkg/detector"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// SARIFReporter writes the whole scan as one SARIF 2.1.0 log, for tools
+// that ingest code scanner output. Findings are kept in memory and the log
+// is written on Close.
+type SARIFReporter struct {
+	file    *os.File
+	mu      sync.Mutex
+	results []MatchResult
+	summary *Summary
+}
+
+func NewSARIFReporter(path string) (*SARIFReporter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SARIFReporter{file: f}, nil
+}
+
+func (r *SARIFReporter) Report(m MatchResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, m)
+}
+
+// ReportSummary keeps the summary for the run properties, and the failed
+// files as tool notifications.
+func (r *SARIFReporter) ReportSummary(sum Summary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.summary = &sum
+}
+
+func (r *SARIFReporter) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return
+	}
+	enc := json.NewEncoder(r.file)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(sarifLog(r.results, r.summary)); err != nil {
+		utils.LogError("Failed to write SARIF output: %v", err)
+	}
+	r.file.Close()
+	r.file = nil
+}
+
+type sarifDoc struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+	Properties  *Summary          `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	RuleIndex  int             `json:"ruleIndex"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties sarifProperties `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int           `json:"startLine"`
+	Snippet   *sarifMessage `json:"snippet,omitempty"`
+}
+
+// sarifProperties are the MatchResult fields SARIF has no place for.
+type sarifProperties struct {
+	Host        string            `json:"host,omitempty"`
+	Share       string            `json:"share,omitempty"`
+	Severity    string            `json:"severity,omitempty"`
+	Category    string            `json:"category,omitempty"`
+	Hash        string            `json:"sha256,omitempty"`
+	Size        int64             `json:"size,omitempty"`
+	Modified    string            `json:"mtime,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	DecodeChain []string          `json:"decode_chain,omitempty"`
+	Git         *GitInfo          `json:"git,omitempty"`
+}
+
+func sarifLog(results []MatchResult, sum *Summary) sarifDoc {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "spuderman",
+			InformationURI: "https://github.com/0xSterny/spuderman",
+			Rules:          []sarifRule{},
+		}},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: true}},
+		Results:     []sarifResult{},
+		Properties:  sum,
+	}
+
+	ruleIndex := make(map[string]int)
+	for _, m := range results {
+		id := m.Rule
+		if id == "" {
+			id = "match"
+		}
+		i, ok := ruleIndex[id]
+		if !ok {
+			i = len(run.Tool.Driver.Rules)
+			ruleIndex[id] = i
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: id, ShortDescription: sarifMessage{ruleDescription(id)}})
+		}
+
+		loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: m.URI()}}}
+		if m.Line > 0 {
+			loc.PhysicalLocation.Region = &sarifRegion{StartLine: m.Line}
+			if m.Snippet != "" {
+				loc.PhysicalLocation.Region.Snippet = &sarifMessage{m.Snippet}
+			}
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    id,
+			RuleIndex: i,
+			Level:     sarifLevel(m.Severity),
+			Message:   sarifMessage{PlainText(m.Reason)},
+			Locations: []sarifLocation{loc},
+			Properties: sarifProperties{
+				Host:        m.Host,
+				Share:       m.Share,
+				Severity:    m.Severity,
+				Category:    m.Category,
+				Hash:        m.Hash,
+				Size:        m.Size,
+				Modified:    m.Modified,
+				Metadata:    m.Metadata,
+				DecodeChain: m.DecodeChain,
+				Git:         m.Git,
+			},
+		})
+	}
+
+	if sum != nil {
+		for _, f := range sum.FailedFiles {
+			uri := MatchResult{Host: f.Host, Share: f.Share, Scheme: f.Scheme, Path: f.Path}.URI()
+			run.Invocations[0].ToolExecutionNotifications = append(run.Invocations[0].ToolExecutionNotifications, sarifNotification{
+				Level:     "warning",
+				Message:   sarifMessage{"File failed (" + f.Reason + "): " + f.Error},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: uri}}}},
+			})
+		}
+	}
+
+	return sarifDoc{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}}
+}
+
+// sarifLevel maps a severity to a SARIF level. Findings without one (the
+// filename and content rules) are warnings.
+func sarifLevel(severity string) string {
+	switch severity {
+	case detector.SeverityCritical, detector.SeverityHigh:
+		return "error"
+	case detector.SeverityMedium, "":
+		return "warning"
+	}
+	return "note"
+}
+
+// ruleDescription describes a rule from its id (see MatchResult.Rule).
+func ruleDescription(id string) string {
+	kind, arg, _ := strings.Cut(id, ":")
+	switch kind {
+	case "filename":
+		return "File name matches " + arg
+	case "content":
+		return "Content matches " + arg
+	case "preset":
+		return "Content matches the " + arg + " secret preset"
+	case "classify":
+		return "File identified as " + arg
+	}
+	switch id {
+	case RuleConfig:
+		return "Credentials in a configuration file"
+	case RuleAll:
+		return "Any file (no filename or content filter)"
+	}
+	return id
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// PlainText removes the terminal colors from s (reasons embed the snippet
+// in bold).
+func PlainText(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
@@ -0,0 +1,118 @@
+package spider_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+func TestSARIFReporter(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "notes.txt"), []byte("nothing\n  db password=Hunter2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password="}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	out := filepath.Join(t.TempDir(), "results.sarif")
+	rep, err := spider.NewSARIFReporter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := spider.Config{Threads: 1, NoDownload: true, Host: spider.LocalHost, Share: tmpDir}
+	spider.NewSpider(cfg, m, &spider.LocalFS{}, utils.NewDeduplicator(), rep).Walk(tmpDir)
+	rep.ReportSummary(spider.Summary{FailedFiles: []spider.FailedFile{{Host: "10.0.0.5", Share: "IT", Path: "a/b.pdf", Reason: "timeout"}}})
+	rep.Close()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct{ ID string }
+				}
+			}
+			Invocations []struct {
+				ToolExecutionNotifications []struct {
+					Locations []struct {
+						PhysicalLocation struct {
+							ArtifactLocation struct{ URI string }
+						}
+					}
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct {
+							StartLine int
+							Snippet   struct{ Text string }
+						}
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("got %s", data)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "content:password=" {
+		t.Errorf("rules: %+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 1 {
+		t.Fatalf("results: %+v", run.Results)
+	}
+	res := run.Results[0]
+	loc := res.Locations[0].PhysicalLocation
+	if res.RuleID != "content:password=" || res.Level != "warning" || res.Message.Text != "Content: db password=Hunter2" {
+		t.Errorf("result: %+v", res)
+	}
+	if want := "file://" + filepath.ToSlash(filepath.Join(tmpDir, "notes.txt")); loc.ArtifactLocation.URI != want {
+		t.Errorf("uri %q, want %q", loc.ArtifactLocation.URI, want)
+	}
+	if loc.Region.StartLine != 2 || loc.Region.Snippet.Text != "db password=Hunter2" {
+		t.Errorf("region: %+v", loc.Region)
+	}
+	if n := run.Invocations[0].ToolExecutionNotifications; len(n) != 1 || n[0].Locations[0].PhysicalLocation.ArtifactLocation.URI != "smb://10.0.0.5/IT/a/b.pdf" {
+		t.Errorf("notifications: %+v", n)
+	}
+}
+
+func TestMatchResultURI(t *testing.T) {
+	tests := []struct {
+		result spider.MatchResult
+		want   string
+	}{
+		{spider.MatchResult{Host: "10.0.0.5", Share: "C$", Path: "Users/j doe/pass.txt"}, "smb://10.0.0.5/C$/Users/j%20doe/pass.txt"},
+		{spider.MatchResult{Host: "files.corp", Share: "/home", Scheme: "sftp", Path: "/home/app/.env"}, "sftp://files.corp/home/app/.env"},
+		{spider.MatchResult{Host: "s3.amazonaws.com", Share: "backups", Scheme: "s3", Path: "db/dump.sql"}, "s3://backups/db/dump.sql"},
+		{spider.MatchResult{Host: spider.LocalHost, Share: "/srv", Path: "/srv/a.txt"}, "file:///srv/a.txt"},
+	}
+	for _, tt := range tests {
+		if got := tt.result.URI(); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package spider
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPFS spiders a remote filesystem over SSH (SFTP subsystem)
+type SFTPFS struct {
+	Client *sftp.Client
+	conn   *ssh.Client
+}
+
+// SFTPAuth holds the credentials for DialSFTP. If KeyFile is set it is tried
+// first; Password doubles as the key passphrase for encrypted keys.
+type SFTPAuth struct {
+	User     string
+	Password string
+	KeyFile  string
+}
+
+// DialSFTP connects to addr (host:port) and starts an SFTP session.
+// Host keys are not verified: we are scanning, not deploying.
+func DialSFTP(addr string, auth SFTPAuth) (*SFTPFS, error) {
+	var methods []ssh.AuthMethod
+
+	if auth.KeyFile != "" {
+		pem, err := os.ReadFile(auth.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) && auth.Password != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(auth.Password))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ssh key %s: %v", auth.KeyFile, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if auth.Password != "" {
+		methods = append(methods,
+			ssh.Password(auth.Password),
+			// Some servers only offer keyboard-interactive for passwords
+			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = auth.Password
+				}
+				return answers, nil
+			}),
+		)
+	}
+
+	cfg := &ssh.ClientConfig{
+		User:            auth.User,
+		Auth:            methods,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         10 * time.Second,
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	conn, err := ssh.Dial("tcp", addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &SFTPFS{Client: client, conn: conn}, nil
+}
+
+func (s *SFTPFS) Open(name string) (fs.File, error) {
+	// *sftp.File has Read, Close and Stat, so it satisfies fs.File as-is
+	return s.Client.Open(name)
+}
+
+func (s *SFTPFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	if root == "" {
+		root = "."
+	}
+	return s.walk(root, fn)
+}
+
+func (s *SFTPFS) walk(dir string, fn fs.WalkDirFunc) error {
+	infos, err := s.Client.ReadDir(dir)
+	if err != nil {
+		return fn(dir, nil, err)
+	}
+
+	for _, info := range infos {
+		name := info.Name()
+		if name == "." || name == ".." {
+			continue
+		}
+
+		fullPath := path.Join(dir, name)
+		d := fs.FileInfoToDirEntry(info)
+
+		if err := fn(fullPath, d, nil); err != nil {
+			if err == fs.SkipDir {
+				if info.IsDir() {
+					continue
+				}
+				return nil
+			}
+			return err
+		}
+
+		// ReadDir uses lstat semantics, so symlinked dirs are not followed
+		if info.IsDir() {
+			if err := s.walk(fullPath, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close ends the SFTP session and the underlying SSH connection.
+func (s *SFTPFS) Close() {
+	if s.Client != nil {
+		s.Client.Close()
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
@@ -0,0 +1,152 @@
+package spider_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// startSFTPServer runs an in-process SSH server with an SFTP subsystem
+// serving the real filesystem. Only user/pass is accepted.
+func startSFTPServer(t *testing.T, user, pass string) string {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, p []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(p) == pass {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			nConn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(nConn, cfg)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func serveSFTP(nConn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nConn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range chReqs {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(ch)
+					if err != nil {
+						return
+					}
+					server.Serve()
+					server.Close()
+					return
+				}
+			}
+		}()
+	}
+}
+
+func TestSpiderSFTP(t *testing.T) {
+	tmpDir := t.TempDir()
+	remote := filepath.Join(tmpDir, "remote")
+	files := map[string]string{
+		"etc/app.conf":        "db_password=hunter2",
+		"home/bob/notes.txt":  "nothing here",
+		"home/bob/.bash_hist": "export PASSWORD=abc",
+	}
+	for rel, content := range files {
+		full := filepath.Join(remote, rel)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addr := startSFTPServer(t, "bob", "s3cret")
+
+	if _, err := spider.DialSFTP(addr, spider.SFTPAuth{User: "bob", Password: "wrong"}); err == nil {
+		t.Fatal("expected auth failure with wrong password")
+	}
+
+	fsys, err := spider.DialSFTP(addr, spider.SFTPAuth{User: "bob", Password: "s3cret"})
+	if err != nil {
+		t.Fatalf("DialSFTP: %v", err)
+	}
+	defer fsys.Close()
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	lootDir := filepath.Join(tmpDir, "loot")
+	cfg := spider.Config{
+		Threads:    2,
+		LootDir:    lootDir,
+		Structured: true,
+		Host:       "sftp-host",
+		Share:      "remote",
+	}
+	s := spider.NewSpider(cfg, m, fsys, utils.NewDeduplicator(), nil)
+	s.Walk(remote)
+
+	for _, want := range []string{"etc/app.conf", "home/bob/.bash_hist"} {
+		p := filepath.Join(lootDir, "sftp-host", "remote", remote, want)
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("expected %s in loot: %v", want, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(lootDir, "sftp-host", "remote", remote, "home/bob/notes.txt")); err == nil {
+		t.Error("notes.txt should not have matched")
+	}
+}
@@ -58,6 +58,9 @@ func (s *SMBFS) walk(path string, fn fs.WalkDirFunc) error {
 
 		if err := fn(fullPath, d, nil); err != nil {
 			if err == fs.SkipDir {
+				if info.IsDir() {
+					continue
+				}
 				return nil
 			}
 			return err
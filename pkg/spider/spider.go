@@ -1,18 +1,25 @@
 package spider
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
-	"errors"
+	"time"
 
+	"github.com/0xSterny/spuderman/pkg/creds"
+	"github.com/0xSterny/spuderman/pkg/decoder"
+	"github.com/0xSterny/spuderman/pkg/detector"
 	"github.com/0xSterny/spuderman/pkg/extractor"
 	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/smbclient"
 	"github.com/0xSterny/spuderman/pkg/utils"
 )
 
@@ -21,6 +28,9 @@ type FileSystem interface {
 	Open(name string) (fs.File, error)
 }
 
+// LocalHost is the Host of local path scans.
+const LocalHost = "Local"
+
 // LocalFS wrapper
 type LocalFS struct{}
 
@@ -38,10 +48,59 @@ type Config struct {
 	LootDir    string
 	NoDownload bool
 
+	// MaxFileSize skips files larger than this many bytes (0 = no limit).
+	// Sizes come from the directory listing, so no extra request is made.
+	MaxFileSize int64
+
 	// Structured Loot options
 	Structured bool
 	Host       string
 	Share      string
+	// Scheme of URL targets ("sftp", "s3"...); empty for SMB and local
+	// paths. See MatchResult.URI.
+	Scheme string
+
+	// GitHistory scans the history of .git directories and bare repos
+	// instead of skipping them.
+	GitHistory bool
+	// MaxGitSize skips repositories on remote backends whose .git
+	// directory is larger than this many bytes: they are copied locally
+	// before their history is read (0 = no limit).
+	MaxGitSize int64
+
+	// ScanImages opens ISO, VHD, VHDX and flat VMDK images and walks the
+	// filesystems inside them.
+	ScanImages bool
+
+	// Classify opens every file to identify registry hives, NTDS.dit and
+	// credential containers (KeePass, PFX, private keys...) by their content,
+	// whatever their name.
+	Classify bool
+
+	// ParseConfigs parses known configuration files (web.config,
+	// unattend.xml, .env, scripts...) and reports the credentials in them
+	// with their key path.
+	ParseConfigs bool
+
+	// Decode also matches the content regexes against base64 and hex blobs
+	// found in the text, decoded (and decompressed) up to decoder.MaxDepth
+	// times.
+	Decode bool
+
+	// FileTimeout gives up on a file whose checks take longer, so it does
+	// not hold a thread forever (0 = no limit).
+	FileTimeout time.Duration
+
+	// Failures collects the files that panicked or timed out. Shared by all
+	// the spiders of a scan; may be nil.
+	Failures *Failures
+
+	// Stats collects the per-share counters of a scan. Shared by all the
+	// spiders of a scan; may be nil.
+	Stats *Stats
+
+	// HostInfo is attached to every MatchResult for this host (SMB only)
+	HostInfo *smbclient.HostInfo
 
 	// Delimiter used between Host/Share/Path segments in the flat loot filename.
 	// Must be filesystem-safe and shell-safe; defaults to "+" when unset.
@@ -51,6 +110,25 @@ type Config struct {
 type DownloadJob struct {
 	Path   string
 	Reason string
+	Rule   string      // See MatchResult.Rule
+	Info   fs.FileInfo // From the listing; may be nil
+
+	// Where a content rule matched (see MatchResult.Line)
+	Line    int
+	Snippet string
+
+	// Set for files identified by the detector (Classify)
+	Severity string
+	Category string
+
+	// Document properties read by the extractor (author, last saved by...)
+	Metadata map[string]string
+
+	// Set for configuration files parsed for credentials (ParseConfigs)
+	Credentials []creds.Credential
+
+	// Set for content matches in decoded blobs (Decode)
+	DecodeChain []string
 }
 
 type Spider struct {
@@ -64,6 +142,12 @@ type Spider struct {
 	// Async Download
 	downloadChan chan DownloadJob
 	downloadWG   sync.WaitGroup
+	// Files given up on (FileTimeout) may still find matches after the
+	// walk. walkDone is set, under walkMu, once downloadChan is closed, and
+	// reportDone once Walk returns (the Reporter may be closed after that).
+	walkMu     sync.RWMutex
+	walkDone   bool
+	reportDone bool
 }
 
 func NewSpider(cfg Config, m *matcher.Matcher, fs FileSystem, dedup *utils.Deduplicator, reporter Reporter) *Spider {
@@ -114,14 +198,37 @@ func (s *Spider) Walk(target string) {
 	err := s.FS.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			utils.LogWarning("Error accessing //%s/%s/%s: %v", s.Config.Host, s.Config.Share, path, errors.Unwrap(err))
+			s.countAccess(err)
 			return nil // Continue walking
 		}
 
 		if d.IsDir() {
+			s.count(func(st *ShareStats) { st.Dirs++ })
+
+			// Repositories are scanned through their history instead of walked
+			if s.Config.GitHistory && s.isGitDir(path) {
+				wg.Add(1)
+				sem <- struct{}{}
+				go func(dir string) {
+					defer wg.Done()
+					defer func() { <-sem }()
+					s.guard(dir, func() { s.scanGitHistory(dir) })
+				}(path)
+				return fs.SkipDir
+			}
+
 			// Check depth (naive implementation, just check separator count)
 			// TODO: Better depth check
 			return nil
 		}
+		s.count(func(st *ShareStats) { st.Files++ })
+
+		// Disk images are opened and walked instead of matched as files
+		// (before the default exclusions, which skip .iso)
+		if s.Config.ScanImages && isImageName(d.Name()) && !s.Matcher.CheckBlacklist(path) {
+			s.scanImage(path, sem)
+			return nil
+		}
 
 		// Check exclusion first
 		if s.Matcher.CheckExclude(path) {
@@ -129,6 +236,7 @@ func (s *Spider) Walk(target string) {
 			if d.IsDir() {
 				return fs.SkipDir
 			}
+			s.countSkip(SkipExcluded)
 			return nil
 		}
 
@@ -138,42 +246,59 @@ func (s *Spider) Walk(target string) {
 			if d.IsDir() {
 				return fs.SkipDir
 			}
+			s.countSkip(SkipBlacklisted)
 			return nil
 		}
 
 		// Check directory filter
 		if !s.Matcher.CheckDir(path) {
+			s.countSkip(SkipDirname)
 			return nil
 		}
 
 		// 3. Check Extension Filter (Extension is a Filter, not a Search Term)
 		if !s.Matcher.CheckExtension(d.Name()) {
+			s.countSkip(SkipExtension)
 			return nil
 		}
 
+		// Size filter (uses the size from the listing)
+		if s.Config.MaxFileSize > 0 {
+			if info, err := d.Info(); err == nil && info.Size() > s.Config.MaxFileSize {
+				utils.LogDebug("Skipping large file (%d bytes): //%s/%s/%s", info.Size(), s.Config.Host, s.Config.Share, path)
+				s.countSkip(SkipSize)
+				return nil
+			}
+		}
+
 		// Search Logic: (NameMatch || ContentMatch)
 		// If no search terms provided (no -f, no -c), we consider it a match (if extension matched). (Dump all mode)
+		// With Classify, every file is opened first to look at its magic bytes,
+		// and with ParseConfigs, known configuration files to parse them.
 
 		hasNameTerm := len(s.Matcher.Config.Filenames) > 0
 		hasContentTerm := len(s.Matcher.Config.Content) > 0
+		deep := s.Config.Classify || s.isConfig(d.Name())
 
-		// Optimization: If no search terms, match immediately
-		if !hasNameTerm && !hasContentTerm {
-			s.handleMatch(path, "Extension/All")
-			return nil
-		}
-
-		// Check Filename Regex
-		if hasNameTerm {
-			if s.Matcher.CheckFilenameRegex(d.Name()) {
-				s.handleMatch(path, "Filename")
-				// Short-circuit: OR logic means if name matches, we are done.
+		if !deep {
+			// Optimization: If no search terms, match immediately
+			if !hasNameTerm && !hasContentTerm {
+				s.handleMatch(path, d, "Extension/All", RuleAll)
 				return nil
 			}
+
+			// Check Filename Regex
+			if hasNameTerm {
+				if rule, ok := s.Matcher.MatchFilename(d.Name()); ok {
+					s.handleMatch(path, d, "Filename", rule)
+					// Short-circuit: OR logic means if name matches, we are done.
+					return nil
+				}
+			}
 		}
 
 		// Check Content Regex (only if needed)
-		if hasContentTerm {
+		if hasContentTerm || deep {
 			// If we are here, Name checks failed (or weren't present).
 			// We MUST check content.
 
@@ -182,31 +307,7 @@ func (s *Spider) Walk(target string) {
 			go func(fPath string, fEntry fs.DirEntry) {
 				defer wg.Done()
 				defer func() { <-sem }()
-
-				// Open file
-				f, err := s.FS.Open(fPath)
-				if err != nil {
-					return
-				}
-				defer f.Close()
-
-				// Extract
-				extEngine := extractor.GetExtractor(fPath)
-				// Limit extraction to 10MB to prevent OOM
-				limitReader := io.LimitReader(f, 10*1024*1024)
-				text, err := extEngine.Extract(limitReader, fPath)
-				if err != nil {
-					return
-				}
-
-				matched, snippet := s.Matcher.CheckContent(text)
-				if matched {
-					reason := "Content"
-					if snippet != "" {
-						reason = "Content: " + utils.Bold(snippet)
-					}
-					s.handleMatch(fPath, reason)
-				}
+				s.guard(fPath, func() { s.checkFile(fPath, fEntry) })
 			}(path, d)
 		}
 
@@ -221,117 +322,391 @@ func (s *Spider) Walk(target string) {
 
 	// Cleanup Downloads
 	if !s.Config.NoDownload {
+		s.walkMu.Lock()
+		s.walkDone = true
 		close(s.downloadChan)
+		s.walkMu.Unlock()
 		s.downloadWG.Wait()
 	}
+	s.walkMu.Lock()
+	s.reportDone = true
+	s.walkMu.Unlock()
+}
+
+// checkFile opens a file for the checks that need its content: detection
+// by magic bytes (Classify), credentials in configuration files
+// (ParseConfigs), then the content regexes.
+func (s *Spider) checkFile(fPath string, fEntry fs.DirEntry) {
+	// Open file
+	f, err := s.FS.Open(fPath)
+	if err != nil {
+		s.countAccess(err)
+		return
+	}
+	defer f.Close()
+
+	var read byteCounter
+	defer func() {
+		s.count(func(st *ShareStats) {
+			st.Scanned++
+			st.BytesRead += read.n.Load()
+		})
+	}()
+
+	sniff := max(detector.SniffSize, extractor.SniffSize)
+	br := bufio.NewReaderSize(read.reader(f), sniff)
+	var src io.Reader = br
+	head, _ := br.Peek(sniff)
+
+	if s.Config.Classify {
+		if detector.Sniff(fEntry.Name(), head) {
+			ra, ok := f.(io.ReaderAt)
+			if ok {
+				ra = read.readerAt(ra)
+			} else {
+				// No random access: read the file into memory, up to the
+				// extraction limit, and extract from that copy below
+				data, _ := io.ReadAll(io.LimitReader(br, extractor.MaxInputSize))
+				ra, src = bytes.NewReader(data), bytes.NewReader(data)
+			}
+			if det := detector.Detect(ra); det != nil {
+				s.handleDetection(fPath, fEntry, det)
+				return
+			}
+		}
+	}
+
+	if s.isConfig(fEntry.Name()) {
+		// Parse from an in-memory copy, which the content checks reuse
+		data, _ := io.ReadAll(io.LimitReader(src, extractor.MaxInputSize))
+		src = bytes.NewReader(data)
+		if found := creds.Parse(fEntry.Name(), data); len(found) > 0 {
+			s.handleCredentials(fPath, fEntry, found)
+			return
+		}
+	}
+
+	if s.Config.Classify || s.isConfig(fEntry.Name()) {
+		// Regular rules (skipped in Walk for these files)
+		if len(s.Matcher.Config.Filenames) == 0 && len(s.Matcher.Config.Content) == 0 {
+			s.handleMatch(fPath, fEntry, "Extension/All", RuleAll)
+			return
+		}
+		if rule, ok := s.Matcher.MatchFilename(fEntry.Name()); ok {
+			s.handleMatch(fPath, fEntry, "Filename", rule)
+			return
+		}
+		if len(s.Matcher.Config.Content) == 0 {
+			return
+		}
+	}
+
+	// Extract (the extractor is picked by signature, extension, then sniffed
+	// type; binaries with no text get none)
+	extEngine := extractor.GetExtractorFor(fPath, head)
+	// Limit extraction to 10MB to prevent OOM
+	limitReader := io.LimitReader(src, extractor.MaxInputSize)
+	doc, err := extractor.ExtractDocument(extEngine, limitReader, fPath)
+	s.logExtraction(fPath, doc, err)
+	if err != nil {
+		return
+	}
+
+	if m, ok := s.matchContent(doc.Text); ok {
+		s.handleJob(m.job(fPath, doc.Metadata), fEntry)
+	}
+}
+
+// contentMatch is what matchContent found.
+type contentMatch struct {
+	matcher.ContentMatch
+	reason string   // To report, with the snippet
+	chain  []string // For a decoded blob, its decode chain
+}
+
+func (m contentMatch) job(path string, meta map[string]string) DownloadJob {
+	return DownloadJob{
+		Path:        path,
+		Reason:      m.reason,
+		Rule:        m.Rule,
+		Line:        m.Line,
+		Snippet:     m.Snippet,
+		Metadata:    meta,
+		DecodeChain: m.chain,
+	}
+}
+
+// matchContent checks extracted text against the content regexes, then,
+// with Decode, the blobs decoded from it.
+func (s *Spider) matchContent(text string) (contentMatch, bool) {
+	if cm, ok := s.Matcher.MatchContent(text); ok {
+		return contentMatch{ContentMatch: cm, reason: contentReason("Content", cm.Snippet)}, true
+	}
+	if !s.Config.Decode {
+		return contentMatch{}, false
+	}
+	for _, layer := range decoder.Decode(text) {
+		if cm, ok := s.Matcher.MatchContent(layer.Text); ok {
+			// The line is in the decoded text, not in the file
+			cm.Line = 0
+			reason := contentReason("Content ("+strings.Join(layer.Chain, " > ")+")", cm.Snippet)
+			return contentMatch{ContentMatch: cm, reason: reason, chain: layer.Chain}, true
+		}
+	}
+	return contentMatch{}, false
+}
+
+// logExtraction reports files that could not be extracted, or only in
+// part, with the reason, so they can be reviewed by hand.
+func (s *Spider) logExtraction(path string, doc *extractor.Document, err error) {
+	var xerr *extractor.ExtractError
+	reason := ""
+	switch {
+	case errors.As(err, &xerr):
+		utils.LogWarning("Extraction failed (%s): //%s/%s/%s: %v", xerr.Reason, s.Config.Host, s.Config.Share, path, xerr.Err)
+		reason = xerr.Reason
+	case err == nil && doc.Metadata["extract_error"] != "":
+		utils.LogWarning("Extraction incomplete (%s): //%s/%s/%s", doc.Metadata["extract_error"], s.Config.Host, s.Config.Share, path)
+		reason = doc.Metadata["extract_error"]
+	case err != nil && !errors.Is(err, extractor.ErrNoExtractor):
+		utils.LogDebug("Extraction failed: //%s/%s/%s: %v", s.Config.Host, s.Config.Share, path, err)
+		reason = "error"
+	}
+	if reason != "" {
+		s.countFailure(reason)
+	}
+}
+
+func (s *Spider) countFailure(reason string) {
+	s.count(func(st *ShareStats) { st.ExtractFailures = addCount(st.ExtractFailures, reason, 1) })
+}
+
+func contentReason(reason, snippet string) string {
+	if snippet != "" {
+		reason += ": " + utils.Bold(snippet)
+	}
+	return reason
 }
 
 func (s *Spider) downloadWorker() {
 	defer s.downloadWG.Done()
 	for job := range s.downloadChan {
-		hash, err := s.downloadFile(job.Path)
-		if err != nil {
-			utils.LogDebug("Download failed: %v", err)
-			// Report failure?
+		s.download(job)
+	}
+}
+
+func (s *Spider) download(job DownloadJob) {
+	defer s.recoverFile(job.Path)
+
+	hash, saved, err := s.downloadFile(job.Path)
+	if err != nil {
+		utils.LogDebug("Download failed: %v", err)
+		// Report failure?
+	}
+
+	// Report match after download (to include hash)
+	result := s.jobResult(job)
+	result.Hash = hash
+	result.LootPath = saved
+	s.report(result)
+}
+
+// report hands a finding to the Reporter and counts it. Findings of files
+// that timed out can come after the walk, when the Reporter may be closed:
+// those are only logged.
+func (s *Spider) report(result MatchResult) {
+	s.walkMu.RLock()
+	defer s.walkMu.RUnlock()
+	if s.reportDone {
+		utils.LogWarning("Match found after the end of the walk, not in the output (%s): //%s/%s/%s", result.Rule, s.Config.Host, s.Config.Share, result.Path)
+		return
+	}
+	s.count(func(st *ShareStats) { st.Matches = addCount(st.Matches, result.Rule, 1) })
+	s.Reporter.Report(result)
+}
+
+// newResult fills in the fields common to every finding. info may be nil.
+func (s *Spider) newResult(path, reason string, info fs.FileInfo) MatchResult {
+	result := MatchResult{
+		Path:     path,
+		Reason:   reason,
+		Host:     s.Config.Host,
+		Share:    s.Config.Share,
+		Scheme:   s.Config.Scheme,
+		HostInfo: s.Config.HostInfo,
+	}
+	if info != nil {
+		result.Size = info.Size()
+		if mt := info.ModTime(); !mt.IsZero() {
+			result.Modified = mt.UTC().Format(time.RFC3339)
 		}
+	}
+	return result
+}
 
-		// Report match after download (to include hash)
-		s.Reporter.Report(MatchResult{
-			Path:   job.Path,
-			Reason: job.Reason,
-			Host:   s.Config.Host,
-			Share:  s.Config.Share,
-			Hash:   hash,
-		})
+// jobResult is newResult plus what the job carries from the checks.
+func (s *Spider) jobResult(job DownloadJob) MatchResult {
+	result := s.newResult(job.Path, job.Reason, job.Info)
+	result.Rule = job.Rule
+	result.Line = job.Line
+	result.Snippet = job.Snippet
+	result.Severity = job.Severity
+	result.Category = job.Category
+	result.Metadata = job.Metadata
+	result.Credentials = job.Credentials
+	result.DecodeChain = job.DecodeChain
+	return result
+}
+
+func (s *Spider) handleMatch(path string, d fs.DirEntry, reason, rule string) {
+	s.handleJob(DownloadJob{Path: path, Reason: reason, Rule: rule}, d)
+}
+
+func (s *Spider) handleJob(job DownloadJob, d fs.DirEntry) {
+	utils.LogSuccess("Match found (%s): //%s/%s/%s", job.Reason, s.Config.Host, s.Config.Share, job.Path)
+	if subject, ok := job.Metadata["subject"]; ok {
+		// Emails: which message it was in
+		utils.LogInfo("  Email %q from %s, %s", subject, job.Metadata["from"], job.Metadata["date"])
 	}
+	s.queueMatch(job, d)
 }
 
-func (s *Spider) handleMatch(path string, reason string) {
-	utils.LogSuccess("Match found (%s): //%s/%s/%s", reason, s.Config.Host, s.Config.Share, path)
+func (s *Spider) handleDetection(path string, d fs.DirEntry, det *detector.Detection) {
+	utils.LogSuccess("[%s] %s: //%s/%s/%s", strings.ToUpper(det.Severity), det.Reason(), s.Config.Host, s.Config.Share, path)
+	s.queueMatch(DownloadJob{Path: path, Reason: det.Reason(), Rule: RuleClassify + det.Category, Severity: det.Severity, Category: det.Category}, d)
+}
 
-	if !s.Config.NoDownload {
-		// Queue for async download
-		s.downloadChan <- DownloadJob{
-			Path:   path,
-			Reason: reason,
+// handleCredentials reports a configuration file with the credentials
+// parsed from it.
+func (s *Spider) handleCredentials(path string, d fs.DirEntry, found []creds.Credential) {
+	utils.LogSuccess("[%s] Credentials in config file: //%s/%s/%s", strings.ToUpper(detector.SeverityHigh), s.Config.Host, s.Config.Share, path)
+	for _, c := range found {
+		user := ""
+		if c.User != "" {
+			user = " (" + c.User + ")"
 		}
-	} else {
-		// Report match immediately
-		s.Reporter.Report(MatchResult{
-			Path:   path,
-			Reason: reason,
-			Host:   s.Config.Host,
-			Share:  s.Config.Share,
-		})
+		utils.LogInfo("  %s = %s%s", c.Key, utils.Bold(c.Value), user)
 	}
+	s.queueMatch(DownloadJob{
+		Path:        path,
+		Reason:      "Config credentials",
+		Rule:        RuleConfig,
+		Line:        found[0].Line,
+		Snippet:     found[0].Key + " = " + found[0].Value,
+		Severity:    detector.SeverityHigh,
+		Category:    creds.Category,
+		Credentials: found,
+	}, d)
 }
 
-func (s *Spider) downloadFile(path string) (string, error) {
-	// Create loot dir if not exists
-	if err := os.MkdirAll(s.Config.LootDir, 0755); err != nil {
-		utils.LogError("Failed to create loot dir: %v", err)
-		return "", err
+// isConfig tells whether name is a configuration file to parse for
+// credentials.
+func (s *Spider) isConfig(name string) bool {
+	return s.Config.ParseConfigs && creds.Known(name)
+}
+
+// queueMatch downloads (asynchronously) and reports a match.
+func (s *Spider) queueMatch(job DownloadJob, d fs.DirEntry) {
+	if d != nil {
+		job.Info, _ = d.Info()
+	}
+
+	if !s.Config.NoDownload {
+		// Queue for async download
+		s.walkMu.RLock()
+		if !s.walkDone {
+			s.downloadChan <- job
+			s.walkMu.RUnlock()
+			return
+		}
+		reportDone := s.reportDone
+		s.walkMu.RUnlock()
+		if !reportDone {
+			utils.LogWarning("Match found after the downloads ended, reported without download (%s): //%s/%s/%s", job.Rule, s.Config.Host, s.Config.Share, job.Path)
+		}
 	}
+	// Report match immediately
+	s.report(s.jobResult(job))
+}
 
+func (s *Spider) downloadFile(path string) (string, string, error) {
 	// Open source
 	src, err := s.FS.Open(path)
 	if err != nil {
+		s.countAccess(err)
 		utils.LogError("Failed to open file for download //%s/%s/%s: %v", s.Config.Host, s.Config.Share, path, err)
-		return "", err
+		return "", "", err
 	}
 	defer src.Close()
 
-	// Create dest
-	var destPath string
-	if s.Config.Structured {
+	return s.saveLoot(path, src)
+}
+
+// LootPath returns where a file found at path on c.Host and c.Share is
+// saved in the loot dir.
+func (c Config) LootPath(path string) string {
+	if c.Structured {
 		// LootDir/Host/Share/Path...
 		// Ensure Host/Share are safe
-		safeHost := strings.ReplaceAll(s.Config.Host, ":", "")
-		safeShare := strings.ReplaceAll(s.Config.Share, "\\", "")
+		safeHost := strings.ReplaceAll(c.Host, ":", "")
+		safeShare := strings.ReplaceAll(c.Share, "\\", "")
 		safeShare = strings.ReplaceAll(safeShare, "/", "")
 
 		// Join effectively sanitizes middle segments? No, we rely on Join.
 		// Path comes in as "foo/bar.txt".
-		destPath = filepath.Join(s.Config.LootDir, safeHost, safeShare, path)
-	} else {
-		// Old flat behavior: collapse path separators into a configurable delimiter
-		// so the full Host/Share/Path is preserved in a single filename.
-		delim := s.Config.Delimiter
-		if delim == "" {
-			delim = "+"
-		}
+		return filepath.Join(c.LootDir, safeHost, safeShare, path)
+	}
+
+	// Old flat behavior: collapse path separators into a configurable delimiter
+	// so the full Host/Share/Path is preserved in a single filename.
+	delim := c.Delimiter
+	if delim == "" {
+		delim = "+"
+	}
 
-		safeName := strings.ReplaceAll(path, "\\", delim)
-		safeName = strings.ReplaceAll(safeName, "/", delim)
-		safeName = strings.ReplaceAll(safeName, ":", "")
+	safeName := strings.ReplaceAll(path, "\\", delim)
+	safeName = strings.ReplaceAll(safeName, "/", delim)
+	safeName = strings.ReplaceAll(safeName, ":", "")
 
-		// Prefix with Host and Share if available
-		safeHost := strings.ReplaceAll(s.Config.Host, ":", "")
-		safeShare := strings.ReplaceAll(s.Config.Share, "\\", "")
-		safeShare = strings.ReplaceAll(safeShare, "/", "")
+	// Prefix with Host and Share if available
+	safeHost := strings.ReplaceAll(c.Host, ":", "")
+	safeShare := strings.ReplaceAll(c.Share, "\\", "")
+	safeShare = strings.ReplaceAll(safeShare, "/", "")
 
-		// Desired format: Host<delim>Share<delim>Path
-		prefix := ""
-		if safeHost != "" {
-			prefix += safeHost + delim
-		}
-		if safeShare != "" {
-			prefix += safeShare + delim
-		}
+	// Desired format: Host<delim>Share<delim>Path
+	prefix := ""
+	if safeHost != "" {
+		prefix += safeHost + delim
+	}
+	if safeShare != "" {
+		prefix += safeShare + delim
+	}
+
+	return filepath.Join(c.LootDir, prefix+safeName)
+}
 
-		destPath = filepath.Join(s.Config.LootDir, prefix+safeName)
+// saveLoot copies src to the loot path for path and returns its SHA-256 and
+// where it was saved. Content already looted (same hash) is removed again,
+// and the path returned empty.
+func (s *Spider) saveLoot(path string, src io.Reader) (string, string, error) {
+	// Create loot dir if not exists
+	if err := os.MkdirAll(s.Config.LootDir, 0755); err != nil {
+		utils.LogError("Failed to create loot dir: %v", err)
+		return "", "", err
 	}
 
+	destPath := s.Config.LootPath(path)
+
 	// Create parent dirs
 	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
 		utils.LogError("Failed to create loot subdirs for %s: %v", destPath, err)
-		return "", err
+		return "", "", err
 	}
 
 	dst, err := os.Create(destPath)
 	if err != nil {
 		utils.LogError("Failed to create destination file %s: %v", destPath, err)
-		return "", err
+		return "", "", err
 	}
 	defer dst.Close()
 
@@ -345,7 +720,7 @@ func (s *Spider) downloadFile(path string) (string, error) {
 		dst.Close() // Ensure closed before removing
 		utils.LogError("Failed to download file %s: %v", path, err)
 		os.Remove(destPath) // Cleanup partial
-		return "", err
+		return "", "", err
 	} else {
 		dst.Close() // Close successful file
 
@@ -356,10 +731,11 @@ func (s *Spider) downloadFile(path string) (string, error) {
 			os.Remove(destPath)
 			// We still return hash? It was a match, just duplicate content.
 			// Maybe report it?
-			return hash, nil
+			return hash, "", nil
 		} else {
 			utils.LogDownload("Downloaded to: %s [Hash: %s]", destPath, hash[:8])
-			return hash, nil
+			s.count(func(st *ShareStats) { st.Downloads++ })
+			return hash, destPath, nil
 		}
 	}
 }
@@ -1,10 +1,19 @@
 package spider_test
 
 import (
+	"encoding/base64"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/0xSterny/spuderman/pkg/creds"
+	"github.com/0xSterny/spuderman/pkg/detector"
 	"github.com/0xSterny/spuderman/pkg/matcher"
 	"github.com/0xSterny/spuderman/pkg/spider"
 	"github.com/0xSterny/spuderman/pkg/utils"
@@ -156,3 +165,240 @@ func TestKnownGoods(t *testing.T) {
 		}
 	}
 }
+
+func TestSpiderParseConfigs(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		".env":       "DB_USER=app\nDB_PASSWORD=Pr0d!\n",
+		"web.config": "<configuration><!-- password is in the vault --></configuration>",
+		"notes.txt":  "the password is on the fridge",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	rep := &collectReporter{}
+	cfg := spider.Config{Threads: 2, NoDownload: true, ParseConfigs: true}
+	s := spider.NewSpider(cfg, m, &spider.LocalFS{}, utils.NewDeduplicator(), rep)
+	s.Walk(tmpDir)
+
+	got := make(map[string]spider.MatchResult)
+	for _, r := range rep.results {
+		got[filepath.Base(r.Path)] = r
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d results, want 3: %+v", len(got), rep.results)
+	}
+
+	env := got[".env"]
+	want := []creds.Credential{{Key: "DB_PASSWORD", Value: "Pr0d!", User: "app", Line: 2}}
+	if env.Category != creds.Category || env.Severity != detector.SeverityHigh || !slices.Equal(env.Credentials, want) {
+		t.Errorf(".env: got %s/%s %+v", env.Severity, env.Category, env.Credentials)
+	}
+	// No credentials in it: the content rules apply
+	for _, name := range []string{"web.config", "notes.txt"} {
+		if r := got[name]; r.Category != "" || !strings.HasPrefix(r.Reason, "Content") {
+			t.Errorf("%s: got %s (%s)", name, r.Reason, r.Category)
+		}
+	}
+}
+
+func TestSpiderDecode(t *testing.T) {
+	tmpDir := t.TempDir()
+	blob := base64.StdEncoding.EncodeToString([]byte("password=Hidden2024"))
+	if err := os.WriteFile(filepath.Join(tmpDir, "deploy.yaml"), []byte("data:\n  config: "+blob+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password="}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	for _, decode := range []bool{false, true} {
+		rep := &collectReporter{}
+		cfg := spider.Config{Threads: 1, NoDownload: true, Decode: decode}
+		spider.NewSpider(cfg, m, &spider.LocalFS{}, utils.NewDeduplicator(), rep).Walk(tmpDir)
+
+		if !decode {
+			if len(rep.results) != 0 {
+				t.Errorf("matched without --decode: %+v", rep.results)
+			}
+			continue
+		}
+		if len(rep.results) != 1 || !slices.Equal(rep.results[0].DecodeChain, []string{"base64"}) {
+			t.Fatalf("got %+v", rep.results)
+		}
+		if r := rep.results[0].Reason; !strings.HasPrefix(r, "Content (base64): ") {
+			t.Errorf("reason %q", r)
+		}
+	}
+}
+
+// faultyFS panics on "boom.txt" and blocks on "slow.txt" until release is
+// closed.
+type faultyFS struct {
+	spider.LocalFS
+	release chan struct{}
+}
+
+func (f *faultyFS) Open(name string) (fs.File, error) {
+	switch filepath.Base(name) {
+	case "boom.txt":
+		panic("parser exploded")
+	case "slow.txt":
+		<-f.release
+	}
+	return f.LocalFS.Open(name)
+}
+
+func TestSpiderFailedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"boom.txt", "slow.txt", "ok.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("password=x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	fsys := &faultyFS{release: make(chan struct{})}
+	defer close(fsys.release)
+	failures := &spider.Failures{}
+	rep := &collectReporter{}
+	cfg := spider.Config{Threads: 1, LootDir: filepath.Join(tmpDir, "loot"), FileTimeout: 100 * time.Millisecond, Failures: failures}
+	spider.NewSpider(cfg, m, fsys, utils.NewDeduplicator(), rep).Walk(tmpDir)
+
+	if len(rep.results) != 1 || filepath.Base(rep.results[0].Path) != "ok.txt" {
+		t.Errorf("results: %+v", rep.results)
+	}
+	files := failures.Files()
+	if len(files) != 2 {
+		t.Fatalf("failures: %+v", files)
+	}
+	for i, want := range []struct{ name, reason string }{{"boom.txt", "panic"}, {"slow.txt", "timeout"}} {
+		if filepath.Base(files[i].Path) != want.name || files[i].Reason != want.reason {
+			t.Errorf("failure %d: got %s (%s), want %s (%s)", i, files[i].Path, files[i].Reason, want.name, want.reason)
+		}
+	}
+}
+
+// closedReporter fails the test on findings reported after Close.
+type closedReporter struct {
+	collectReporter
+	t      *testing.T
+	closed atomic.Bool
+}
+
+func (r *closedReporter) Report(m spider.MatchResult) {
+	if r.closed.Load() {
+		r.t.Errorf("finding reported after Close: %s", m.Path)
+	}
+	r.collectReporter.Report(m)
+}
+
+func (r *closedReporter) Close() { r.closed.Store(true) }
+
+// A file given up on that matches once the walk is over must not reach the
+// (closed) reporter.
+func TestSpiderLateMatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "slow.txt"), []byte("password=x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	for _, noDownload := range []bool{true, false} {
+		fsys := &faultyFS{release: make(chan struct{})}
+		rep := &closedReporter{t: t}
+		cfg := spider.Config{Threads: 1, NoDownload: noDownload, LootDir: filepath.Join(tmpDir, "loot"), FileTimeout: 50 * time.Millisecond}
+		spider.NewSpider(cfg, m, fsys, utils.NewDeduplicator(), rep).Walk(tmpDir)
+		rep.Close()
+
+		close(fsys.release)
+		time.Sleep(100 * time.Millisecond) // Let the file finish
+		if len(rep.results) != 0 {
+			t.Errorf("no download %v: results %+v", noDownload, rep.results)
+		}
+	}
+}
+
+func TestSpiderStats(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"secret.txt":     "password=x",
+		"notes.txt":      "nothing here",
+		"sub/todo.txt":   "buy milk",
+		"sub/photo.png":  "not text",
+		"old_backup.txt": "password=old",
+		"big.txt":        strings.Repeat("password=", 20),
+	}
+	var scanned int64
+	for name, data := range files {
+		p := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(name, ".png") && len(data) <= 50 && !strings.HasPrefix(name, "old_") {
+			scanned += int64(len(data))
+		}
+	}
+
+	m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password="}, Extensions: []string{"txt"}, Blacklist: []string{"old_"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExcludeRegex = nil
+
+	stats := &spider.Stats{}
+	rep := &collectReporter{}
+	cfg := spider.Config{Threads: 2, LootDir: t.TempDir(), MaxFileSize: 50, Host: "Local", Share: "test", Stats: stats}
+	spider.NewSpider(cfg, m, &spider.LocalFS{}, utils.NewDeduplicator(), rep).Walk(tmpDir)
+
+	if len(rep.results) != 1 || rep.results[0].Rule != "content:password=" {
+		t.Fatalf("results: %+v", rep.results)
+	}
+	shares := stats.Shares()
+	if len(shares) != 1 {
+		t.Fatalf("shares: %+v", shares)
+	}
+	got := shares[0]
+	want := spider.ShareStats{
+		Host:      "Local",
+		Share:     "test",
+		Dirs:      2,
+		Files:     6,
+		Skipped:   map[string]int64{spider.SkipBlacklisted: 1, spider.SkipExtension: 1, spider.SkipSize: 1},
+		Scanned:   3,
+		BytesRead: scanned,
+		Matches:   map[string]int64{"content:password=": 1},
+		Downloads: 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if total := stats.Total(); total.MatchCount() != 1 || total.SkipCount() != 3 {
+		t.Errorf("total: %+v", total)
+	}
+}
@@ -0,0 +1,214 @@
+package spider
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Reasons a file was skipped without being checked (ShareStats.Skipped).
+const (
+	SkipExcluded    = "excluded"    // Default exclusions
+	SkipBlacklisted = "blacklisted" // --blacklist
+	SkipDirname     = "dirname"     // Outside the --dirnames directories
+	SkipExtension   = "extension"   // Not one of the --extensions
+	SkipSize        = "size"        // Larger than --max-filesize
+)
+
+// ShareStats are the counters of the scan of one share (or local path, or
+// URL target).
+type ShareStats struct {
+	Host  string `json:"host,omitempty"`
+	Share string `json:"share,omitempty"`
+
+	Dirs  int64 `json:"dirs"`  // Directories listed
+	Files int64 `json:"files"` // Files seen in the listings
+	// Skipped counts the files not checked, by reason (SkipExcluded...).
+	Skipped map[string]int64 `json:"skipped,omitempty"`
+	// Scanned files were opened for their content; BytesRead is what was
+	// read from them.
+	Scanned   int64 `json:"scanned"`
+	BytesRead int64 `json:"bytes_read"`
+	// ExtractFailures counts the files that could not be read, or only in
+	// part, by reason (extractor.ReasonEncrypted...).
+	ExtractFailures map[string]int64 `json:"extract_failures,omitempty"`
+	AccessDenied    int64            `json:"access_denied"`
+	// Matches counts the findings by rule (MatchResult.Rule).
+	Matches   map[string]int64 `json:"matches,omitempty"`
+	Downloads int64            `json:"downloads"`
+}
+
+// MatchCount is the number of findings, all rules together.
+func (st *ShareStats) MatchCount() int64 {
+	return sum(st.Matches)
+}
+
+// SkipCount is the number of files skipped, all reasons together.
+func (st *ShareStats) SkipCount() int64 {
+	return sum(st.Skipped)
+}
+
+// FailureCount is the number of extraction failures, all reasons together.
+func (st *ShareStats) FailureCount() int64 {
+	return sum(st.ExtractFailures)
+}
+
+func (st *ShareStats) add(o *ShareStats) {
+	st.Dirs += o.Dirs
+	st.Files += o.Files
+	st.Scanned += o.Scanned
+	st.BytesRead += o.BytesRead
+	st.AccessDenied += o.AccessDenied
+	st.Downloads += o.Downloads
+	st.Skipped = addCounts(st.Skipped, o.Skipped)
+	st.ExtractFailures = addCounts(st.ExtractFailures, o.ExtractFailures)
+	st.Matches = addCounts(st.Matches, o.Matches)
+}
+
+func (st *ShareStats) clone() ShareStats {
+	c := *st
+	c.Skipped = maps.Clone(st.Skipped)
+	c.ExtractFailures = maps.Clone(st.ExtractFailures)
+	c.Matches = maps.Clone(st.Matches)
+	return c
+}
+
+// Stats holds the counters of a scan, per host and share. One is shared by
+// all the spiders of a scan (Config.Stats).
+type Stats struct {
+	mu     sync.Mutex
+	shares map[[2]string]*ShareStats
+}
+
+// update runs fn on the counters of host/share, under the lock.
+func (t *Stats) update(host, share string, fn func(*ShareStats)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.shares == nil {
+		t.shares = make(map[[2]string]*ShareStats)
+	}
+	key := [2]string{host, share}
+	st, ok := t.shares[key]
+	if !ok {
+		st = &ShareStats{Host: host, Share: share}
+		t.shares[key] = st
+	}
+	fn(st)
+}
+
+// Shares returns the counters of every share, sorted by host and share.
+func (t *Stats) Shares() []ShareStats {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	shares := make([]ShareStats, 0, len(t.shares))
+	for _, st := range t.shares {
+		shares = append(shares, st.clone())
+	}
+	slices.SortFunc(shares, func(a, b ShareStats) int {
+		return strings.Compare(a.Host+"\x00"+a.Share, b.Host+"\x00"+b.Share)
+	})
+	return shares
+}
+
+// Total returns the counters of all the shares added up.
+func (t *Stats) Total() ShareStats {
+	var total ShareStats
+	for _, st := range t.Shares() {
+		total.add(&st)
+	}
+	return total
+}
+
+// count updates the counters of the share being walked.
+func (s *Spider) count(fn func(*ShareStats)) {
+	s.Config.Stats.update(s.Config.Host, s.Config.Share, fn)
+}
+
+func (s *Spider) countSkip(reason string) {
+	s.count(func(st *ShareStats) { st.Skipped = addCount(st.Skipped, reason, 1) })
+}
+
+// countAccess counts err when it is an access denied error.
+func (s *Spider) countAccess(err error) {
+	if isAccessDenied(err) {
+		s.count(func(st *ShareStats) { st.AccessDenied++ })
+	}
+}
+
+// isAccessDenied tells permission errors (SMB STATUS_ACCESS_DENIED, EACCES)
+// from other errors. Backends that do not map them to fs.ErrPermission are
+// recognised by the message.
+func isAccessDenied(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "access denied") || strings.Contains(msg, "permission denied") ||
+		strings.Contains(msg, "access is denied")
+}
+
+// byteCounter counts what is read from a file, sequentially or at random.
+type byteCounter struct {
+	n atomic.Int64
+}
+
+func (c *byteCounter) reader(r io.Reader) io.Reader {
+	return readerFunc(func(p []byte) (int, error) {
+		n, err := r.Read(p)
+		c.n.Add(int64(n))
+		return n, err
+	})
+}
+
+func (c *byteCounter) readerAt(ra io.ReaderAt) io.ReaderAt {
+	return readerAtFunc(func(p []byte, off int64) (int, error) {
+		n, err := ra.ReadAt(p, off)
+		c.n.Add(int64(n))
+		return n, err
+	})
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+type readerAtFunc func(p []byte, off int64) (int, error)
+
+func (f readerAtFunc) ReadAt(p []byte, off int64) (int, error) { return f(p, off) }
+
+func addCount(m map[string]int64, key string, n int64) map[string]int64 {
+	if m == nil {
+		m = make(map[string]int64)
+	}
+	m[key] += n
+	return m
+}
+
+func addCounts(m, o map[string]int64) map[string]int64 {
+	for k, n := range o {
+		m = addCount(m, k, n)
+	}
+	return m
+}
+
+func sum(m map[string]int64) int64 {
+	var n int64
+	for _, v := range m {
+		n += v
+	}
+	return n
+}
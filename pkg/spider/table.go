@@ -0,0 +1,184 @@
+package spider
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// TableColumns are the columns of the CSV and Markdown reports, in order.
+// The Markdown report has one table per host, under the host as heading,
+// without the host column.
+var TableColumns = []string{"host", "share", "path", "reason", "rule", "severity", "size", "mtime", "sha256", "loot_path"}
+
+// tableRow is m in TableColumns order. Sizes are in bytes.
+func tableRow(m MatchResult) []string {
+	size := ""
+	if m.Size > 0 {
+		size = strconv.FormatInt(m.Size, 10)
+	}
+	return []string{m.Host, m.Share, m.Path, PlainText(m.Reason), m.Rule, m.Severity, size, m.Modified, m.Hash, m.LootPath}
+}
+
+// CSVReporter writes one row per finding, after a header row.
+type CSVReporter struct {
+	file *os.File
+	w    *csv.Writer
+	mu   sync.Mutex
+}
+
+func NewCSVReporter(path string) (*CSVReporter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &CSVReporter{file: f, w: csv.NewWriter(f)}
+	r.w.Write(TableColumns)
+	r.w.Flush()
+	return r, nil
+}
+
+func (r *CSVReporter) Report(m MatchResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return
+	}
+
+	row := tableRow(m)
+	for i, cell := range row {
+		row[i] = csvSafe(cell)
+	}
+	r.w.Write(row)
+	r.w.Flush()
+}
+
+func (r *CSVReporter) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil {
+		r.w.Flush()
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// csvSafe keeps spreadsheets from evaluating a cell (file names and
+// snippets come from the scanned hosts): cells that would be read as a
+// formula get a leading quote.
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// MarkdownReporter writes a table of the findings per host, ready to paste
+// into a report. Findings are kept in memory and written on Close.
+type MarkdownReporter struct {
+	file    *os.File
+	mu      sync.Mutex
+	results []MatchResult
+}
+
+func NewMarkdownReporter(path string) (*MarkdownReporter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MarkdownReporter{file: f}, nil
+}
+
+func (r *MarkdownReporter) Report(m MatchResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, m)
+}
+
+func (r *MarkdownReporter) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return
+	}
+	if err := writeMarkdown(r.file, r.results); err != nil {
+		utils.LogError("Failed to write Markdown output: %v", err)
+	}
+	r.file.Close()
+	r.file = nil
+}
+
+func writeMarkdown(w io.Writer, results []MatchResult) error {
+	results = slices.Clone(results)
+	slices.SortStableFunc(results, func(a, b MatchResult) int {
+		return cmp.Or(strings.Compare(a.Host, b.Host), strings.Compare(a.Share, b.Share), strings.Compare(a.Path, b.Path))
+	})
+
+	hosts := 0
+	for i, m := range results {
+		if i == 0 || m.Host != results[i-1].Host {
+			hosts++
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Findings\n\n%d findings on %d hosts.\n", len(results), hosts)
+
+	header := TableColumns[1:]
+	for i, m := range results {
+		if i == 0 || m.Host != results[i-1].Host {
+			host := m.Host
+			if host == "" {
+				host = "(no host)"
+			}
+			fmt.Fprintf(&b, "\n## %s\n\n| %s |\n|%s\n", mdText(host), strings.Join(header, " | "), strings.Repeat(" --- |", len(header)))
+		}
+
+		row := tableRow(m)[1:]
+		for j, cell := range row {
+			switch header[j] {
+			case "path", "sha256", "loot_path":
+				row[j] = mdCode(cell)
+			default:
+				row[j] = mdText(cell)
+			}
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(row, " | "))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var mdEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "<", `\<`, ">", `\>`, "[", `\[`, "]", `\]`, "#", `\#`,
+	"\r", " ", "\n", " ",
+)
+
+// mdText escapes s for a Markdown table cell.
+func mdText(s string) string {
+	return mdEscaper.Replace(s)
+}
+
+// mdCode renders s as code in a Markdown table cell.
+func mdCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	s = strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ").Replace(s)
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		return fence + " " + s + " " + fence
+	}
+	return fence + s + fence
+}
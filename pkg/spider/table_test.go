@@ -0,0 +1,86 @@
+package spider_test
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/0xSterny/spuderman/pkg/spider"
+)
+
+var tableResults = []spider.MatchResult{
+	{Host: "10.0.0.6", Share: "Users", Path: "=HYPERLINK(\"http://x\")|a.txt", Reason: "Content: \x1b[1m-2+3\x1b[0m", Rule: "content:password", Size: 2048},
+	{Host: "10.0.0.5", Share: "C$", Path: "Windows/System32/config/SAM", Reason: "Registry hive (SAM)", Rule: "classify:registry-hive",
+		Severity: "critical", Size: 65536, Modified: "2024-05-01T10:00:00Z", Hash: "abc123", LootPath: "loot/10.0.0.5+C$+SAM"},
+}
+
+func TestCSVReporter(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "results.csv")
+	rep, err := spider.NewCSVReporter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range tableResults {
+		rep.Report(m)
+	}
+	rep.Close()
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		spider.TableColumns,
+		{"10.0.0.6", "Users", "'=HYPERLINK(\"http://x\")|a.txt", "Content: -2+3", "content:password", "", "2048", "", "", ""},
+		{"10.0.0.5", "C$", "Windows/System32/config/SAM", "Registry hive (SAM)", "classify:registry-hive", "critical", "65536", "2024-05-01T10:00:00Z", "abc123", "loot/10.0.0.5+C$+SAM"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %q", rows)
+	}
+	for i := range want {
+		if !slices.Equal(rows[i], want[i]) {
+			t.Errorf("row %d: got %q, want %q", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestMarkdownReporter(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "results.md")
+	rep, err := spider.NewMarkdownReporter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range tableResults {
+		rep.Report(m)
+	}
+	rep.Close()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := string(data)
+	for _, want := range []string{
+		"2 findings on 2 hosts.",
+		"## 10.0.0.5\n\n| share | path | reason | rule | severity | size | mtime | sha256 | loot_path |\n| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n" +
+			"| C$ | `Windows/System32/config/SAM` | Registry hive (SAM) | classify:registry-hive | critical | 65536 | 2024-05-01T10:00:00Z | `abc123` | `loot/10.0.0.5+C$+SAM` |\n",
+		"| Users | `=HYPERLINK(\"http://x\")\\|a.txt` | Content: -2+3 | content:password |  | 2048 |  |  |  |\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("missing %q in:\n%s", want, md)
+		}
+	}
+	// Hosts in order
+	if strings.Index(md, "## 10.0.0.5") > strings.Index(md, "## 10.0.0.6") {
+		t.Errorf("hosts out of order:\n%s", md)
+	}
+}
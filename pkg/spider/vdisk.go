@@ -0,0 +1,414 @@
+package spider
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// Virtual disk containers (VHD, VHDX, VMDK) are turned into a flat
+// io.ReaderAt over the virtual disk, then partitions are located and their
+// filesystems opened. Only what is needed for reading is implemented: no
+// differencing disks, no log replay, no sparse VMDK.
+
+const sectorSize = 512
+
+// maxTableSize bounds the tables whose size comes from an image header
+// (block allocation tables, metadata items, GPT entries): a corrupt or
+// crafted image must not make us allocate gigabytes.
+const maxTableSize = 256 << 20
+
+// readTable reads the n byte table at off. n comes from the image, so it is
+// checked against the image size (limit) and maxTableSize first.
+func readTable(r io.ReaderAt, off, n, limit int64, what string) ([]byte, error) {
+	if n < 0 || n > limit || n > maxTableSize {
+		return nil, fmt.Errorf("bad %s size %d", what, n)
+	}
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// blockDisk reads a virtual disk made of fixed-size blocks. locate returns
+// the file offset of a block, or -1 if it is not allocated (reads as zeros).
+type blockDisk struct {
+	r         io.ReaderAt
+	size      int64
+	blockSize int64
+	locate    func(block int64) int64
+}
+
+func (d *blockDisk) ReadAt(p []byte, off int64) (int, error) {
+	if off >= d.size {
+		return 0, io.EOF
+	}
+	if rem := d.size - off; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		block, within := pos/d.blockSize, pos%d.blockSize
+		chunk := p[n:]
+		if max := d.blockSize - within; int64(len(chunk)) > max {
+			chunk = chunk[:max]
+		}
+
+		if fileOff := d.locate(block); fileOff < 0 {
+			clear(chunk)
+		} else if m, err := d.r.ReadAt(chunk, fileOff+within); m < len(chunk) {
+			return n + m, err
+		}
+		n += len(chunk)
+	}
+
+	if off+int64(n) >= d.size {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// openVHD handles fixed and dynamic VHDs (footer "conectix").
+func openVHD(r io.ReaderAt, size int64) (io.ReaderAt, int64, error) {
+	footer := make([]byte, sectorSize)
+	if _, err := r.ReadAt(footer, size-sectorSize); err != nil {
+		return nil, 0, err
+	}
+	if !bytes.HasPrefix(footer, []byte("conectix")) {
+		// Pre-2004 fixed disks have a 511 byte footer
+		clear(footer)
+		if _, err := r.ReadAt(footer[:sectorSize-1], size-sectorSize+1); err != nil || !bytes.HasPrefix(footer, []byte("conectix")) {
+			return nil, 0, errors.New("no VHD footer")
+		}
+	}
+
+	diskSize := int64(binary.BigEndian.Uint64(footer[48:]))
+	switch diskType := binary.BigEndian.Uint32(footer[60:]); diskType {
+	case 2: // fixed: raw disk followed by the footer
+		return io.NewSectionReader(r, 0, diskSize), diskSize, nil
+	case 3: // dynamic
+	case 4:
+		return nil, 0, errors.New("differencing VHD (needs its parent)")
+	default:
+		return nil, 0, fmt.Errorf("unknown VHD disk type %d", diskType)
+	}
+
+	hdr := make([]byte, 1024)
+	if _, err := r.ReadAt(hdr, int64(binary.BigEndian.Uint64(footer[16:]))); err != nil {
+		return nil, 0, err
+	}
+	if !bytes.HasPrefix(hdr, []byte("cxsparse")) {
+		return nil, 0, errors.New("bad VHD dynamic header")
+	}
+	tableOffset := int64(binary.BigEndian.Uint64(hdr[16:]))
+	entries := int64(binary.BigEndian.Uint32(hdr[28:]))
+	blockSize := int64(binary.BigEndian.Uint32(hdr[32:]))
+	if blockSize == 0 || blockSize%sectorSize != 0 {
+		return nil, 0, fmt.Errorf("bad VHD block size %d", blockSize)
+	}
+
+	bat, err := readTable(r, tableOffset, entries*4, size, "VHD BAT")
+	if err != nil {
+		return nil, 0, err
+	}
+	// Each block starts with a sector bitmap, padded to a sector
+	bitmapSize := (blockSize/sectorSize/8 + sectorSize - 1) / sectorSize * sectorSize
+
+	return &blockDisk{
+		r:         r,
+		size:      diskSize,
+		blockSize: blockSize,
+		locate: func(block int64) int64 {
+			if block >= entries {
+				return -1
+			}
+			sector := binary.BigEndian.Uint32(bat[block*4:])
+			if sector == 0xFFFFFFFF {
+				return -1
+			}
+			return int64(sector)*sectorSize + bitmapSize
+		},
+	}, diskSize, nil
+}
+
+// VHDX region and metadata item GUIDs (MS-VHDX 2.2.2, 2.6.2).
+var (
+	vhdxBATRegion      = vhdxGUID("2DC27766-F623-4200-9D64-115E9BFD4A08")
+	vhdxMetadataRegion = vhdxGUID("8B7CA206-4790-4B9A-B8FE-575F050F886E")
+	vhdxFileParameters = vhdxGUID("CAA16737-FA36-4D43-B3B6-33F0AA44E76B")
+	vhdxVirtualSize    = vhdxGUID("2FA54224-CD1B-4876-B211-5DBED83BF4B8")
+	vhdxLogicalSector  = vhdxGUID("8141BF1D-A96F-4709-BA47-F233A8FAAB5F")
+)
+
+// vhdxGUID returns the on-disk (mixed-endian) bytes of a GUID string.
+func vhdxGUID(s string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != 16 {
+		panic("bad GUID " + s)
+	}
+	b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	b[4], b[5] = b[5], b[4]
+	b[6], b[7] = b[7], b[6]
+	return b
+}
+
+// openVHDX handles dynamic and fixed VHDX files (MS-VHDX).
+func openVHDX(r io.ReaderAt, size int64) (io.ReaderAt, int64, error) {
+	sig := make([]byte, 8)
+	if _, err := r.ReadAt(sig, 0); err != nil {
+		return nil, 0, err
+	}
+	if string(sig) != "vhdxfile" {
+		return nil, 0, errors.New("no VHDX signature")
+	}
+
+	// Region table (two copies, at 192 KiB and 256 KiB)
+	var batOff, metaOff int64
+	var batLen int64
+	for _, off := range []int64{192 * 1024, 256 * 1024} {
+		rt := make([]byte, 64*1024)
+		if _, err := r.ReadAt(rt, off); err != nil || string(rt[:4]) != "regi" {
+			continue
+		}
+		count := int(binary.LittleEndian.Uint32(rt[8:]))
+		for i := 0; i < count && 16+(i+1)*32 <= len(rt); i++ {
+			e := rt[16+i*32:]
+			switch {
+			case bytes.Equal(e[:16], vhdxBATRegion):
+				batOff = int64(binary.LittleEndian.Uint64(e[16:]))
+				batLen = int64(binary.LittleEndian.Uint32(e[24:]))
+			case bytes.Equal(e[:16], vhdxMetadataRegion):
+				metaOff = int64(binary.LittleEndian.Uint64(e[16:]))
+			}
+		}
+		if batOff != 0 && metaOff != 0 {
+			break
+		}
+	}
+	if batOff == 0 || metaOff == 0 {
+		return nil, 0, errors.New("VHDX region table not found")
+	}
+
+	// Metadata table: 32 byte header, then 32 byte entries
+	meta := make([]byte, 64*1024)
+	if _, err := r.ReadAt(meta, metaOff); err != nil {
+		return nil, 0, err
+	}
+	if string(meta[:8]) != "metadata" {
+		return nil, 0, errors.New("bad VHDX metadata table")
+	}
+	item := func(id []byte) ([]byte, error) {
+		count := int(binary.LittleEndian.Uint16(meta[10:]))
+		for i := 0; i < count && 32+(i+1)*32 <= len(meta); i++ {
+			e := meta[32+i*32:]
+			if bytes.Equal(e[:16], id) {
+				off := metaOff + int64(binary.LittleEndian.Uint32(e[16:]))
+				return readTable(r, off, int64(binary.LittleEndian.Uint32(e[20:])), size, "VHDX metadata item")
+			}
+		}
+		return nil, errors.New("VHDX metadata item missing")
+	}
+
+	params, err := item(vhdxFileParameters)
+	if err != nil || len(params) < 8 {
+		return nil, 0, errors.New("VHDX file parameters missing")
+	}
+	blockSize := int64(binary.LittleEndian.Uint32(params))
+	if binary.LittleEndian.Uint32(params[4:])&2 != 0 {
+		return nil, 0, errors.New("differencing VHDX (needs its parent)")
+	}
+	vsize, err := item(vhdxVirtualSize)
+	if err != nil || len(vsize) < 8 {
+		return nil, 0, errors.New("VHDX virtual size missing")
+	}
+	diskSize := int64(binary.LittleEndian.Uint64(vsize))
+	lss, err := item(vhdxLogicalSector)
+	if err != nil || len(lss) < 4 {
+		return nil, 0, errors.New("VHDX sector size missing")
+	}
+	logicalSector := int64(binary.LittleEndian.Uint32(lss))
+	// Blocks are a power of two from 1 to 256 MiB (MS-VHDX 2.6.2.1)
+	if blockSize < 1<<20 || blockSize > 256<<20 || blockSize&(blockSize-1) != 0 {
+		return nil, 0, fmt.Errorf("bad VHDX block size %d", blockSize)
+	}
+	if logicalSector != 512 && logicalSector != 4096 {
+		return nil, 0, fmt.Errorf("bad VHDX logical sector size %d", logicalSector)
+	}
+
+	// Each chunk of chunkRatio payload blocks is followed by a bitmap entry
+	chunkRatio := (int64(1) << 23) * logicalSector / blockSize
+	if chunkRatio == 0 {
+		return nil, 0, errors.New("bad VHDX geometry")
+	}
+	bat, err := readTable(r, batOff, batLen, size, "VHDX BAT")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &blockDisk{
+		r:         r,
+		size:      diskSize,
+		blockSize: blockSize,
+		locate: func(block int64) int64 {
+			idx := block + block/chunkRatio
+			if (idx+1)*8 > int64(len(bat)) {
+				return -1
+			}
+			entry := binary.LittleEndian.Uint64(bat[idx*8:])
+			switch entry & 7 {
+			case 6, 7: // PAYLOAD_BLOCK_FULLY_PRESENT, PARTIALLY_PRESENT
+				return int64(entry>>20) * 1024 * 1024
+			default: // not present, undefined, zero, unmapped
+				return -1
+			}
+		},
+	}, diskSize, nil
+}
+
+// openVMDK accepts monolithic flat extents ("-flat.vmdk"), which are raw
+// disks. Descriptor files are skipped: the extent they point to is picked up
+// on its own when the walk reaches it.
+func openVMDK(r io.ReaderAt, size int64) (io.ReaderAt, int64, error) {
+	head := make([]byte, 64)
+	if _, err := r.ReadAt(head, 0); err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("KDMV")):
+		return nil, 0, errors.New("sparse VMDK is not supported")
+	case bytes.HasPrefix(head, []byte("COWD")):
+		return nil, 0, errors.New("ESX sparse VMDK is not supported")
+	case bytes.Contains(head, []byte("Disk DescriptorFile")):
+		return nil, 0, errors.New("VMDK descriptor (its flat extent is scanned separately)")
+	}
+	return r, size, nil
+}
+
+// openDisk finds the filesystems on a raw disk: either a bare volume or
+// MBR/GPT partitions.
+func openDisk(format string, disk io.ReaderAt, size int64) (*ImageFS, error) {
+	img := &ImageFS{format: format}
+
+	if kind, vol, err := openVolume(disk, 0, size); err == nil {
+		img.volumes = append(img.volumes, imageVolume{kind: kind, fs: vol})
+		return img, nil
+	}
+
+	parts, err := readPartitions(disk, size)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range parts {
+		kind, vol, err := openVolume(disk, p.start, p.size)
+		if err != nil {
+			utils.LogDebug("Skipping partition %d: %v", i+1, err)
+			continue
+		}
+		img.volumes = append(img.volumes, imageVolume{name: fmt.Sprintf("p%d", i+1), kind: kind, fs: vol})
+	}
+
+	switch len(img.volumes) {
+	case 0:
+		return nil, errors.New("no supported filesystem found")
+	case 1:
+		img.volumes[0].name = ""
+	}
+	return img, nil
+}
+
+type partition struct {
+	start, size int64
+}
+
+// readPartitions parses an MBR, following the protective entry to the GPT.
+func readPartitions(disk io.ReaderAt, size int64) ([]partition, error) {
+	mbr := make([]byte, sectorSize)
+	if _, err := disk.ReadAt(mbr, 0); err != nil {
+		return nil, err
+	}
+	if mbr[510] != 0x55 || mbr[511] != 0xAA {
+		return nil, errors.New("no partition table")
+	}
+
+	var parts []partition
+	for i := 0; i < 4; i++ {
+		e := mbr[446+i*16:]
+		typ := e[4]
+		start := int64(binary.LittleEndian.Uint32(e[8:])) * sectorSize
+		count := int64(binary.LittleEndian.Uint32(e[12:])) * sectorSize
+		switch typ {
+		case 0:
+			continue
+		case 0xEE:
+			return readGPT(disk, size)
+		case 0x05, 0x0F, 0x85:
+			// Extended partitions (logical volumes) are not followed
+			continue
+		}
+		parts = append(parts, partition{start, count})
+	}
+	return parts, nil
+}
+
+func readGPT(disk io.ReaderAt, size int64) ([]partition, error) {
+	hdr := make([]byte, sectorSize)
+	if _, err := disk.ReadAt(hdr, sectorSize); err != nil {
+		return nil, err
+	}
+	if string(hdr[:8]) != "EFI PART" {
+		return nil, errors.New("bad GPT header")
+	}
+	entriesLBA := int64(binary.LittleEndian.Uint64(hdr[72:]))
+	count := int64(binary.LittleEndian.Uint32(hdr[80:]))
+	entrySize := int64(binary.LittleEndian.Uint32(hdr[84:]))
+	if entrySize < 128 || count > 1024 {
+		return nil, errors.New("bad GPT entry table")
+	}
+
+	table, err := readTable(disk, entriesLBA*sectorSize, count*entrySize, size, "GPT entry table")
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []partition
+	for i := int64(0); i < count; i++ {
+		e := table[i*entrySize:]
+		if bytes.Equal(e[:16], make([]byte, 16)) {
+			continue // unused entry
+		}
+		first := int64(binary.LittleEndian.Uint64(e[32:]))
+		last := int64(binary.LittleEndian.Uint64(e[40:]))
+		parts = append(parts, partition{first * sectorSize, (last - first + 1) * sectorSize})
+	}
+	return parts, nil
+}
+
+// openVolume identifies the filesystem at off by its boot sector.
+func openVolume(disk io.ReaderAt, off, size int64) (string, FileSystem, error) {
+	boot := make([]byte, sectorSize)
+	if _, err := disk.ReadAt(boot, off); err != nil {
+		return "", nil, err
+	}
+	vol := io.NewSectionReader(disk, off, size)
+
+	switch {
+	case string(boot[3:11]) == "NTFS    ":
+		fsys, err := openNTFS(vol)
+		return "ntfs", fsys, err
+	case string(boot[82:90]) == "FAT32   ":
+		fsys, err := openFAT32(vol, size)
+		return "fat32", fsys, err
+	case string(boot[54:59]) == "FAT12" || string(boot[54:59]) == "FAT16":
+		return "", nil, errors.New("FAT12/16 volumes are not supported")
+	}
+	return "", nil, errors.New("unknown filesystem")
+}
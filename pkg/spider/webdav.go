@@ -0,0 +1,252 @@
+package spider
+
+import (
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-ntlmssp"
+)
+
+// WebDAVConfig describes a WebDAV server (IIS WebDAV, SharePoint document
+// libraries, Apache mod_dav, Nextcloud, ...).
+type WebDAVConfig struct {
+	// BaseURL is the http(s) URL of the server; only scheme and host are used,
+	// paths passed to WalkDir/Open are absolute on the server.
+	BaseURL string
+
+	// User may be "DOMAIN\user" or "user@domain" for NTLM. Credentials are
+	// sent as Basic and upgraded to NTLM/Negotiate when the server asks for it.
+	User     string
+	Password string
+}
+
+// WebDAVFS spiders a WebDAV server with PROPFIND (Depth: 1) and GET.
+type WebDAVFS struct {
+	client *http.Client
+	base   *url.URL
+	cfg    WebDAVConfig
+	infos  sync.Map // path -> *davFileInfo, filled while walking (used by Stat)
+}
+
+const davPropfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/><D:getlastmodified/></D:prop></D:propfind>`
+
+// DialWebDAV checks the server answers PROPFIND on root with cfg's credentials.
+func DialWebDAV(cfg WebDAVConfig, root string) (*WebDAVFS, error) {
+	base, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		// NTLM authenticates the connection, so keep it alive between requests
+		MaxIdleConnsPerHost: 8,
+	}
+
+	w := &WebDAVFS{
+		client: &http.Client{
+			Transport: ntlmssp.Negotiator{RoundTripper: transport, AllowBasicAuth: true},
+			Timeout:   60 * time.Second,
+		},
+		base: base,
+		cfg:  cfg,
+	}
+
+	if _, err := w.propfind(root); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *WebDAVFS) url(p string) string {
+	u := *w.base
+	u.Path = p
+	u.RawQuery = ""
+	return u.String()
+}
+
+func (w *WebDAVFS) do(method, p string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, w.url(p), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if w.cfg.User != "" {
+		req.SetBasicAuth(w.cfg.User, w.cfg.Password)
+	}
+	return w.client.Do(req)
+}
+
+// davMultistatus is the subset of a PROPFIND response we care about.
+type davMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength string `xml:"DAV: getcontentlength"`
+				LastModified  string `xml:"DAV: getlastmodified"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// propfind lists dir (Depth: 1) and returns its direct children.
+func (w *WebDAVFS) propfind(dir string) ([]*davFileInfo, error) {
+	resp, err := w.do("PROPFIND", dir, strings.NewReader(davPropfindBody), map[string]string{
+		"Depth":        "1",
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("PROPFIND %s: %s", dir, resp.Status)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("PROPFIND %s: bad multistatus: %v", dir, err)
+	}
+
+	parent := path.Clean(dir)
+	var infos []*davFileInfo
+	for _, r := range ms.Responses {
+		// href is either a path or a full URL, percent-encoded either way
+		hu, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		// Only direct children: a server listing dir itself, its parent or
+		// anything else would have walk recurse forever
+		p := path.Clean(hu.Path)
+		if p == parent || path.Dir(p) != parent {
+			continue
+		}
+
+		info := &davFileInfo{path: p}
+		for _, ps := range r.Propstats {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			info.dir = ps.Prop.ResourceType.Collection != nil
+			info.size, _ = strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+			info.modTime, _ = http.ParseTime(ps.Prop.LastModified)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (w *WebDAVFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	if root == "" {
+		root = "/"
+	}
+	return w.walk(root, fn)
+}
+
+func (w *WebDAVFS) walk(dir string, fn fs.WalkDirFunc) error {
+	infos, err := w.propfind(dir)
+	if err != nil {
+		return fn(dir, nil, err)
+	}
+
+	for _, info := range infos {
+		w.infos.Store(info.path, info)
+
+		if err := fn(info.path, fs.FileInfoToDirEntry(info), nil); err != nil {
+			if err == fs.SkipDir {
+				if info.dir {
+					continue
+				}
+				return nil
+			}
+			return err
+		}
+
+		if info.dir {
+			if err := w.walk(info.path+"/", fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *WebDAVFS) Open(name string) (fs.File, error) {
+	// "Translate: f" makes IIS return the raw source of scripts and configs
+	// (.aspx, web.config) instead of executing or blocking them.
+	resp, err := w.do(http.MethodGet, name, nil, map[string]string{"Translate": "f"})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", name, resp.Status)
+	}
+
+	// No Content-Length on chunked responses
+	info := &davFileInfo{path: name, size: max(resp.ContentLength, 0)}
+	if cached, ok := w.infos.Load(name); ok {
+		info = cached.(*davFileInfo)
+	} else if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		info.modTime, _ = http.ParseTime(lm)
+	}
+
+	return &davFile{ReadCloser: resp.Body, info: info}, nil
+}
+
+// Close drops idle (NTLM-authenticated) connections.
+func (w *WebDAVFS) Close() {
+	w.client.CloseIdleConnections()
+}
+
+type davFile struct {
+	io.ReadCloser
+	info *davFileInfo
+}
+
+func (f *davFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// davFileInfo carries the size and mtime reported by PROPFIND.
+type davFileInfo struct {
+	path    string
+	dir     bool
+	size    int64
+	modTime time.Time
+}
+
+func (i *davFileInfo) Name() string       { return path.Base(i.path) }
+func (i *davFileInfo) Size() int64        { return i.size }
+func (i *davFileInfo) ModTime() time.Time { return i.modTime }
+func (i *davFileInfo) IsDir() bool        { return i.dir }
+func (i *davFileInfo) Sys() any           { return nil }
+
+func (i *davFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
@@ -0,0 +1,246 @@
+package spider_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/0xSterny/spuderman/pkg/matcher"
+	"github.com/0xSterny/spuderman/pkg/spider"
+	"github.com/0xSterny/spuderman/pkg/utils"
+)
+
+// ntlmChallenge is an NTLM CHALLENGE message (MS-NLMP 2.2.1.2) with an
+// empty target info list.
+func ntlmChallenge() []byte {
+	msg := make([]byte, 56, 60)
+	copy(msg, "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint32(msg[16:], 56)         // TargetName (empty)
+	binary.LittleEndian.PutUint32(msg[20:], 0x00888205) // Unicode, NTLM, extended session security, target info
+	copy(msg[24:32], "challnge")
+	binary.LittleEndian.PutUint16(msg[40:], 4) // TargetInfo: MsvAvEOL
+	binary.LittleEndian.PutUint16(msg[42:], 4)
+	binary.LittleEndian.PutUint32(msg[44:], 56)
+	return append(msg, 0, 0, 0, 0)
+}
+
+// ntlmField returns the UTF-16 string of the security buffer at off in an
+// NTLM AUTHENTICATE message.
+func ntlmField(msg []byte, off int) string {
+	if len(msg) < off+8 {
+		return ""
+	}
+	n := int(binary.LittleEndian.Uint16(msg[off:]))
+	start := int(binary.LittleEndian.Uint32(msg[off+4:]))
+	if start+n > len(msg) {
+		return ""
+	}
+	u := make([]uint16, n/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(msg[start+2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// davAuth guards a WebDAV handler with Basic ("basic") or NTLM ("ntlm")
+// authentication. NTLM only checks the user and domain of the AUTHENTICATE
+// message, not the response.
+func davAuth(scheme, user, pass string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch scheme {
+		case "basic":
+			if u, p, ok := r.BasicAuth(); ok && u == user && p == pass {
+				h.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="dav"`)
+		case "ntlm":
+			msg, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "NTLM "))
+			switch {
+			case len(msg) >= 12 && binary.LittleEndian.Uint32(msg[8:]) == 1:
+				w.Header().Set("WWW-Authenticate", "NTLM "+base64.StdEncoding.EncodeToString(ntlmChallenge()))
+			case len(msg) >= 12 && binary.LittleEndian.Uint32(msg[8:]) == 3:
+				if ntlmField(msg, 28)+`\`+ntlmField(msg, 36) == user {
+					h.ServeHTTP(w, r)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", "NTLM")
+			default:
+				w.Header().Set("WWW-Authenticate", "NTLM")
+			}
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+}
+
+func TestSpiderWebDAV(t *testing.T) {
+	files := map[string]string{
+		"/Shared Documents/IT/web.config":     `<add key="DbPassword" value="Winter2024"/>`,
+		"/Shared Documents/IT/Deep/creds.txt": "password=hunter2",
+		"/Shared Documents/big.log":           strings.Repeat("x", 200) + "password",
+		"/Shared Documents/readme.txt":        "nothing to see",
+		"/Other/outside.txt":                  "password=outside the root",
+	}
+	mem := webdav.NewMemFS()
+	ctx := context.Background()
+	for name, content := range files {
+		dir := ""
+		for _, elem := range strings.Split(path.Dir(name), "/")[1:] {
+			dir += "/" + elem
+			if err := mem.Mkdir(ctx, dir, 0755); err != nil && !os.IsExist(err) {
+				t.Fatal(err)
+			}
+		}
+		f, err := mem.OpenFile(ctx, name, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+		f.Close()
+	}
+	dav := &webdav.Handler{FileSystem: mem, LockSystem: webdav.NewMemLS()}
+
+	for _, tc := range []struct {
+		scheme string
+		user   string
+	}{
+		{"basic", "jdoe"},
+		{"ntlm", `CORP\jdoe`},
+	} {
+		t.Run(tc.scheme, func(t *testing.T) {
+			srv := httptest.NewServer(davAuth(tc.scheme, tc.user, "Summer2024!", dav))
+			defer srv.Close()
+
+			if _, err := spider.DialWebDAV(spider.WebDAVConfig{BaseURL: srv.URL, User: "nobody", Password: "x"}, "/Shared Documents"); err == nil {
+				t.Error("DialWebDAV with bad credentials: no error")
+			}
+
+			fsys, err := spider.DialWebDAV(spider.WebDAVConfig{BaseURL: srv.URL, User: tc.user, Password: "Summer2024!"}, "/Shared Documents")
+			if err != nil {
+				t.Fatalf("DialWebDAV: %v", err)
+			}
+			defer fsys.Close()
+
+			m, err := matcher.NewMatcher(matcher.MatchConfig{Content: []string{"password"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.ExcludeRegex = nil
+
+			rep := &collectReporter{}
+			cfg := spider.Config{Threads: 2, NoDownload: true, MaxFileSize: 100, Host: "portal", Scheme: "dav"}
+			s := spider.NewSpider(cfg, m, fsys, utils.NewDeduplicator(), rep)
+			s.Walk("/Shared Documents")
+
+			var got []string
+			for _, r := range rep.results {
+				got = append(got, r.Path)
+				if int(r.Size) != len(files[r.Path]) || r.Modified == "" {
+					t.Errorf("%s: size %d, mtime %q from the listing", r.Path, r.Size, r.Modified)
+				}
+			}
+			sort.Strings(got)
+			// big.log is over MaxFileSize, /Other is outside the root
+			want := []string{"/Shared Documents/IT/Deep/creds.txt", "/Shared Documents/IT/web.config"}
+			if !slices.Equal(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// sloppyDAV lists the root, the parent and a directory outside the one
+// asked for in every PROPFIND response, and sends GET bodies chunked.
+func sloppyDAV(h http.Handler) http.Handler {
+	extra := `<D:response><D:href>/</D:href><D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>` +
+		`<D:response><D:href>/Shared%20Documents/</D:href><D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>` +
+		`<D:response><D:href>/Other/</D:href><D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		body := rec.Body.String()
+		if r.Method == "PROPFIND" {
+			body = strings.Replace(body, "</D:multistatus>", extra+"</D:multistatus>", 1)
+		}
+		for k, v := range rec.Header() {
+			if k != "Content-Length" {
+				w.Header()[k] = v
+			}
+		}
+		w.WriteHeader(rec.Code)
+		io.WriteString(w, body)
+		w.(http.Flusher).Flush()
+	})
+}
+
+func TestSpiderWebDAVSloppyServer(t *testing.T) {
+	mem := webdav.NewMemFS()
+	ctx := context.Background()
+	for _, dir := range []string{"/Other", "/Shared Documents", "/Shared Documents/IT"} {
+		if err := mem.Mkdir(ctx, dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{
+		"/Shared Documents/IT/creds.txt": "password=hunter2",
+		"/Other/outside.txt":             "password=outside the root",
+	} {
+		f, err := mem.OpenFile(ctx, name, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+		f.Close()
+	}
+	srv := httptest.NewServer(sloppyDAV(&webdav.Handler{FileSystem: mem, LockSystem: webdav.NewMemLS()}))
+	defer srv.Close()
+
+	fsys, err := spider.DialWebDAV(spider.WebDAVConfig{BaseURL: srv.URL}, "/Shared Documents")
+	if err != nil {
+		t.Fatalf("DialWebDAV: %v", err)
+	}
+	defer fsys.Close()
+
+	// Not listed yet: the size comes from the chunked GET
+	f, err := fsys.Open("/Shared Documents/IT/creds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := f.Stat(); fi.Size() < 0 {
+		t.Errorf("size %d from a chunked response", fi.Size())
+	}
+	f.Close()
+
+	var got []string
+	err = fsys.WalkDir("/Shared Documents", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if got = append(got, p); len(got) > 10 {
+			return errors.New("walking in circles")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/Shared Documents/IT", "/Shared Documents/IT/creds.txt"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}